@@ -5,7 +5,6 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/a-romash/grpc-calculator/agent/internal/app"
 	"github.com/a-romash/grpc-calculator/agent/internal/config"
@@ -23,13 +22,7 @@ func main() {
 
 	log := setupLogger(cfg.Env)
 
-	application := app.New(log, cfg.GRPCClient.Addr, cfg.GRPCClient.RetriesCount, cfg.CountCalcs, map[string]time.Duration{
-		"+": cfg.Durations.Plus,
-		"-": cfg.Durations.Minus,
-		"*": cfg.Durations.Mult,
-		"/": cfg.Durations.Del,
-		"^": cfg.Durations.Pow,
-	})
+	application := app.New(log, cfg.GRPCClient.Addr, cfg.GRPCClient.RetriesCount, cfg.GRPCClient.HeartbeatInterval, cfg.CountCalcs)
 
 	application.GRPCClient.MustRun()
 
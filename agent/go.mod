@@ -3,7 +3,6 @@ module github.com/a-romash/grpc-calculator/agent
 go 1.22.1
 
 require (
-	github.com/a-romash/go-shunting-yard v0.0.0-20240416170645-a0e20ad914c0
 	github.com/a-romash/protos v0.0.0-20240427235838-d22b5aa9dbe4
 	github.com/fatih/color v1.16.0
 	github.com/ilyakaznacheev/cleanenv v1.5.0
@@ -15,10 +15,10 @@ func New(
 	log *slog.Logger,
 	addr string,
 	retriesCount int,
+	heartbeatInterval time.Duration,
 	countCalcs int,
-	durations map[string]time.Duration,
 ) *App {
-	grpccApp := grpcc.New(log, addr, retriesCount, countCalcs, durations)
+	grpccApp := grpcc.New(log, addr, retriesCount, heartbeatInterval, countCalcs)
 
 	return &App{
 		GRPCClient: grpccApp,
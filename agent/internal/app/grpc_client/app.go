@@ -11,25 +11,26 @@ import (
 )
 
 type GRPCCApp struct {
-	log         *slog.Logger
-	orch_client *grpc.Client
-	agent       *agent.Agent
-	id          int
+	log               *slog.Logger
+	orch_client       *grpc.Client
+	agent             *agent.Agent
+	id                int
+	heartbeatInterval time.Duration
 }
 
 func New(
 	log *slog.Logger,
 	addr string, // address of orchestrator service
 	retriesCount int,
+	heartbeatInterval time.Duration, // how often agent reports to orchestrator that it's alive
 	countCalcs int,
-	durations map[string]time.Duration,
 ) *GRPCCApp {
 	orch_client, err := grpc.New(context.Background(), log, addr, 5*time.Minute, retriesCount)
 	if err != nil {
 		panic(err)
 	}
 
-	agent := agent.New(countCalcs, durations)
+	agent := agent.New(countCalcs)
 
 	var id int
 	id, err = orch_client.RegisterNewAgent(context.Background())
@@ -38,10 +39,11 @@ func New(
 	}
 
 	return &GRPCCApp{
-		log:         log,
-		orch_client: orch_client,
-		agent:       agent,
-		id:          id,
+		log:               log,
+		orch_client:       orch_client,
+		agent:             agent,
+		id:                id,
+		heartbeatInterval: heartbeatInterval,
 	}
 }
 
@@ -51,30 +53,101 @@ func (a *GRPCCApp) MustRun() {
 	}
 }
 
+// reconnectInterval is pause before reopening broken stream with orchestrator
+const reconnectInterval = 3 * time.Second
+
 func (a *GRPCCApp) Run() error {
 	// const op = "gtpccapp.Run"
 
 	go func() {
 		for {
-			idExpression, tokens, err := a.orch_client.GetExpressionToEvaluate(context.Background(), a.id)
-			if err != nil {
+			if err := a.serve(); err != nil {
 				a.log.Error(err.Error())
 			}
-			if len(tokens) > 0 {
-				a.log.Info("STARTED EVALUATING")
-				expression := models.Create("", tokens, idExpression)
-
-				a.agent.SolveExpression(&expression)
-
-				a.orch_client.GiveResultOfExpression(context.Background(), expression.IdExpression, float32(expression.Result), a.id)
-			}
-			time.Sleep(10 * time.Second)
+			time.Sleep(reconnectInterval)
 		}
 	}()
 
+	// Orchestrator takes tasks back from agents which stopped heartbeating
+	go a.heartbeat()
+
 	return nil
 }
 
+// serve gets tasks pushed by orchestrator while stream is alive.
+// Every calculator takes its own task, so independent operations
+// of expression are evaluated in parallel
+func (a *GRPCCApp) serve() error {
+	stream, err := a.orch_client.Connect(context.Background(), a.id, len(a.agent.Calculators))
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	// tasks which are being evaluated, orchestrator can abort them when expression is cancelled
+	running := newRunningTasks()
+	// orchestrator gives unfinished tasks to other agents, when stream is closed
+	defer running.cancelAll()
+
+	for {
+		task, abort, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if abort != 0 {
+			a.log.Info("task is aborted", slog.Int("id_task", abort))
+			running.cancel(abort)
+			continue
+		}
+		if err = stream.Ack(task.IdTask); err != nil {
+			return err
+		}
+
+		ctx := running.start(task.IdTask, task.Deadline)
+		go func() {
+			defer running.cancel(task.IdTask)
+
+			a.log.Info("STARTED EVALUATING")
+			// progress is only informational, evaluating goes on if it isn't sent
+			progress := func(step int, part *models.ExpressionPart, result *models.Token) {
+				if err := stream.GiveProgressOfTask(task.IdTask, step, part, result); err != nil {
+					a.log.Error(err.Error())
+				}
+			}
+
+			if task.Err == nil {
+				if err := a.agent.SolveTask(ctx, task, progress); err != nil {
+					// result of aborted task isn't needed anymore
+					a.log.Info("evaluating is stopped", slog.Int("id_task", task.IdTask), slog.String("reason", err.Error()))
+					return
+				}
+			}
+
+			var err error
+			if task.Err != nil {
+				// expression fails and user sees why, e.g. division by zero
+				err = stream.GiveErrorOfTask(task.IdTask, task.Err)
+			} else {
+				err = stream.GiveResultOfTask(task.IdTask, task.Result)
+			}
+			if err != nil {
+				a.log.Error(err.Error())
+			}
+		}()
+	}
+}
+
+func (a *GRPCCApp) heartbeat() {
+	ticker := time.NewTicker(a.heartbeatInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := a.orch_client.Heartbeat(context.Background(), a.id); err != nil {
+			a.log.Error(err.Error())
+		}
+	}
+}
+
 func (a *GRPCCApp) Stop() error {
 	return a.orch_client.RemoveAgent(context.Background(), a.id)
 }
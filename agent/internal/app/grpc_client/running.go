@@ -0,0 +1,59 @@
+package grpcclientapp
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// runningTasks keeps cancel functions of tasks which are being evaluated
+type runningTasks struct {
+	mu      sync.Mutex
+	cancels map[int]context.CancelFunc
+}
+
+func newRunningTasks() *runningTasks {
+	return &runningTasks{
+		cancels: make(map[int]context.CancelFunc),
+	}
+}
+
+// start returns context of task, which is cancelled by cancel or when deadline passes. Zero deadline is no deadline
+func (r *runningTasks) start(idTask int, deadline time.Time) context.Context {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if deadline.IsZero() {
+		ctx, cancel = context.WithCancel(context.Background())
+	} else {
+		ctx, cancel = context.WithDeadline(context.Background(), deadline)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cancels[idTask] = cancel
+	return ctx
+}
+
+// cancel stops evaluating of task. Nothing happens if task is already finished
+func (r *runningTasks) cancel(idTask int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cancel, ok := r.cancels[idTask]; ok {
+		cancel()
+		delete(r.cancels, idTask)
+	}
+}
+
+func (r *runningTasks) cancelAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for idTask, cancel := range r.cancels {
+		cancel()
+		delete(r.cancels, idTask)
+	}
+}
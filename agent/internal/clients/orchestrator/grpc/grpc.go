@@ -4,10 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"strconv"
+	"sync"
 	"time"
 
-	shuntingYard "github.com/a-romash/go-shunting-yard"
+	"github.com/a-romash/grpc-calculator/agent/internal/domain/models"
 	"github.com/a-romash/protos/gen/go/orchestrator"
 	grpclog "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
 	grpcretry "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/retry"
@@ -22,6 +22,13 @@ type Client struct {
 	log *slog.Logger
 }
 
+// TaskStream is opened stream with orchestrator, which pushes tasks to agent
+type TaskStream struct {
+	stream  orchestrator.Orchestrator_ConnectClient
+	mu      sync.Mutex // Send of grpc stream isn't safe for concurrent use
+	idAgent int
+}
+
 func New(
 	ctx context.Context,
 	log *slog.Logger,
@@ -86,6 +93,7 @@ func (c *Client) Heartbeat(
 
 	_, err := c.api.Heartbeat(ctx, &orchestrator.IsAlive{
 		IsAlive: true,
+		IdAgent: int32(id),
 	})
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
@@ -94,67 +102,29 @@ func (c *Client) Heartbeat(
 	return nil
 }
 
-func (c *Client) GiveResultOfExpression(
-	ctx context.Context,
-	id_expression string,
-	result float32,
-	idAgent int,
-) error {
-	const op = "grpc.GiveResultOfExpression"
-
-	_, err := c.api.GiveResultOfExpression(ctx, &orchestrator.ResultOfExpression{
-		IdExpression: id_expression,
-		Result:       result,
-		IdAgent:      int32(idAgent),
-	})
-	if err != nil {
-		c.log.Error(err.Error() + ". op: " + op)
-		return fmt.Errorf("%s: %w", op, err)
-	}
-
-	return nil
-}
-
-func fromPrototokensToRPNTokens(prototokens []*orchestrator.RPNToken) ([]*shuntingYard.RPNToken, error) {
-	var tokens []*shuntingYard.RPNToken
+func fromPrototokensToTokens(prototokens []*orchestrator.RPNToken) ([]*models.Token, error) {
+	var tokens []*models.Token
 
 	for _, el := range prototokens {
-		switch el.Type {
-		case 1:
-			float_value, err := strconv.ParseFloat(el.Value, 64)
-			if err != nil {
-				return nil, err
+		switch int(el.Type) {
+		case models.Operand:
+			// value is parsed by calculator: in exact mode it can be fraction like "1/3"
+			if el.Value == "" {
+				return nil, fmt.Errorf("operand without value")
 			}
-			tokens = append(tokens, shuntingYard.NewRPNOperandToken(float_value))
-		case 2:
-			tokens = append(tokens, shuntingYard.NewRPNOperatorToken(el.Value))
+			tokens = append(tokens, models.NewOperandToken(el.Value))
+		case models.Operation, models.Function:
+			tokens = append(tokens, &models.Token{
+				Type:  int(el.Type),
+				Name:  el.Value,
+				Arity: int(el.Arity),
+			})
 		}
 	}
 
 	return tokens, nil
 }
 
-func (c *Client) GetExpressionToEvaluate(
-	ctx context.Context,
-	id_agent int,
-) (string, []*shuntingYard.RPNToken, error) {
-	const op = "grpc.GetExpressionToEvaluate"
-
-	resp, err := c.api.GetExpressionToEvaluate(ctx, &orchestrator.IdAgent{
-		IdAgent: int32(id_agent),
-	})
-	if err != nil {
-		return "", nil, fmt.Errorf("%s: %w", op, err)
-	}
-
-	tokens, err := fromPrototokensToRPNTokens(resp.PostfixExpression)
-	if err != nil {
-		return "", nil, fmt.Errorf("%s: %w", op, err)
-	}
-
-	return resp.IdExpression, tokens, nil
-}
-
 func (c *Client) RegisterNewAgent(
 	ctx context.Context,
 ) (int, error) {
@@ -199,3 +169,150 @@ func (c *Client) RemoveAgent(
 	log.Info("removing was succesful!")
 	return nil
 }
+
+// Connect opens stream with orchestrator and advertises freeSlots calculators of agent
+func (c *Client) Connect(
+	ctx context.Context,
+	idAgent int,
+	freeSlots int,
+) (*TaskStream, error) {
+	const op = "grpc.Connect"
+
+	stream, err := c.api.Connect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s := &TaskStream{
+		stream:  stream,
+		idAgent: idAgent,
+	}
+
+	err = s.send(&orchestrator.AgentMessage{
+		Message: &orchestrator.AgentMessage_FreeSlots{FreeSlots: int32(freeSlots)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s, nil
+}
+
+func (s *TaskStream) send(msg *orchestrator.AgentMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg.IdAgent = int32(s.idAgent)
+	return s.stream.Send(msg)
+}
+
+// Recv blocks until orchestrator pushes next task or tells to abort one: then task is nil and abort is id of task.
+// Task which can't be parsed is returned with Err
+func (s *TaskStream) Recv() (task *models.Task, abort int, err error) {
+	const op = "grpc.TaskStream.Recv"
+
+	for {
+		msg, err := s.stream.Recv()
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if idTask := msg.GetAbort(); idTask != 0 {
+			return nil, int(idTask), nil
+		}
+		protoTask := msg.GetTask()
+		if protoTask == nil {
+			continue
+		}
+
+		tokens, err := fromPrototokensToTokens(protoTask.PostfixExpression)
+		parsed := models.Create(int(protoTask.IdTask), protoTask.IdExpression, tokens, time.Duration(protoTask.Duration)*time.Millisecond, protoTask.Exact)
+		if protoTask.Deadline != 0 {
+			parsed.Deadline = time.UnixMilli(protoTask.Deadline)
+		}
+		if err != nil {
+			// malformed task is reported back, otherwise it would be given to agents again and again
+			parsed.Err = models.NewEvaluationError(models.ErrInvalidTask, "%v", err)
+		}
+		return &parsed, 0, nil
+	}
+}
+
+// Ack confirms that task was received
+func (s *TaskStream) Ack(idTask int) error {
+	const op = "grpc.TaskStream.Ack"
+
+	err := s.send(&orchestrator.AgentMessage{
+		Message: &orchestrator.AgentMessage_Ack{Ack: int32(idTask)},
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GiveResultOfTask sends result of task back. Calculator which solved it becomes free for the next task
+func (s *TaskStream) GiveResultOfTask(idTask int, result string) error {
+	const op = "grpc.TaskStream.GiveResultOfTask"
+
+	err := s.send(&orchestrator.AgentMessage{
+		Message: &orchestrator.AgentMessage_Result{Result: &orchestrator.ResultOfTask{
+			IdTask:  int32(idTask),
+			Value:   result,
+			IdAgent: int32(s.idAgent),
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GiveProgressOfTask reports operation of task with index step, which calculator has evaluated.
+// Users watch expressions by these reports, and orchestrator keeps them as trace of expression
+func (s *TaskStream) GiveProgressOfTask(idTask int, step int, part *models.ExpressionPart, result *models.Token) error {
+	const op = "grpc.TaskStream.GiveProgressOfTask"
+
+	operands := make([]string, len(part.Operands))
+	for i, operand := range part.Operands {
+		operands[i] = operand.Value
+	}
+
+	err := s.send(&orchestrator.AgentMessage{
+		Message: &orchestrator.AgentMessage_Progress{Progress: &orchestrator.ProgressOfTask{
+			IdTask:     int32(idTask),
+			Operation:  part.Operation.Name,
+			Operands:   operands,
+			Value:      result.Value,
+			Calculator: int32(part.Calculator),
+			Duration:   part.Elapsed.Milliseconds(),
+			Step:       int32(step),
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GiveErrorOfTask tells that task can't be evaluated. Calculator becomes free as well
+func (s *TaskStream) GiveErrorOfTask(idTask int, evalErr *models.EvaluationError) error {
+	const op = "grpc.TaskStream.GiveErrorOfTask"
+
+	err := s.send(&orchestrator.AgentMessage{
+		Message: &orchestrator.AgentMessage_Error{Error: &orchestrator.ErrorOfTask{
+			IdTask:  int32(idTask),
+			IdAgent: int32(s.idAgent),
+			Code:    evalErr.Code,
+			Message: evalErr.Message,
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *TaskStream) Close() error {
+	return s.stream.CloseSend()
+}
@@ -12,20 +12,12 @@ type Config struct {
 	Env        string           `yaml:"env"`
 	CountCalcs int              `yaml:"count_calculators"`
 	GRPCClient GRPCClientConfig `yaml:"grpc_client"`
-	Durations  DurationsConfig  `yaml:"durations"`
 }
 
 type GRPCClientConfig struct {
-	Addr         string `yaml:"orch_addr"`
-	RetriesCount int    `yaml:"retries_count"`
-}
-
-type DurationsConfig struct {
-	Plus  time.Duration `yaml:"plus"`
-	Minus time.Duration `yaml:"minus"`
-	Mult  time.Duration `yaml:"mult"`
-	Del   time.Duration `yaml:"del"`
-	Pow   time.Duration `yaml:"pow"`
+	Addr              string        `yaml:"orch_addr"`
+	RetriesCount      int           `yaml:"retries_count"`
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval" env-default:"5s"`
 }
 
 func MustLoad() *Config {
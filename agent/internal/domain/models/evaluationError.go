@@ -0,0 +1,28 @@
+package models
+
+import "fmt"
+
+// Codes of evaluation errors, they are shown to user as reason why expression failed
+const (
+	ErrDivisionByZero   = "division_by_zero"
+	ErrDomain           = "domain_error" // function isn't defined for operand, e.g. sqrt(-1)
+	ErrOutOfRange       = "out_of_range" // result doesn't fit into float64 or is too big in exact mode
+	ErrInexact          = "inexact_operation"
+	ErrUnknownOperation = "unknown_operation"
+	ErrInvalidTask      = "invalid_task"      // task from orchestrator is malformed, e.g. operator has not enough operands
+	ErrDeadline         = "deadline_exceeded" // task can't be evaluated before deadline of expression
+)
+
+// EvaluationError is sent to orchestrator instead of result, when task can't be evaluated
+type EvaluationError struct {
+	Code    string
+	Message string
+}
+
+func NewEvaluationError(code string, format string, args ...any) *EvaluationError {
+	return &EvaluationError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *EvaluationError) Error() string {
+	return e.Message
+}
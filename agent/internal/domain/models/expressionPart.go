@@ -1,27 +1,32 @@
 package models
 
 import (
+	"context"
 	"time"
-
-	shuntingYard "github.com/a-romash/go-shunting-yard"
 )
 
+// ExpressionPart is one operator or function with its operands, which is evaluated by calculator
 type ExpressionPart struct {
-	FirstOperand  *shuntingYard.RPNToken      `json:"firstOperand"`
-	SecondOperand *shuntingYard.RPNToken      `json:"secondOperand"`
-	Operation     *shuntingYard.RPNToken      `json:"operation"`
-	Duration      time.Duration               `json:"duration"` // в секундах
-	IdExpression  string                      `json:"id"`
-	Result        chan *shuntingYard.RPNToken `json:"result"`
+	Ctx          context.Context `json:"-"`        // cancelled when result isn't needed anymore, then nothing is sent to Result
+	Operands     []*Token        `json:"operands"` // one for unary minus, any number for functions like max
+	Operation    *Token          `json:"operation"`
+	Duration     time.Duration   `json:"duration"` // в секундах
+	IdExpression string          `json:"id"`
+	Exact        bool            `json:"exact"`
+	Result       chan *Token     `json:"result"` // nil token is sent, if evaluation failed with Err
+	Err          *EvaluationError
+	Calculator   int           `json:"calculator"` // which calculator evaluated operation, set with result
+	Elapsed      time.Duration `json:"elapsed"`    // how long calculator evaluated operation, set with result
 }
 
-func NewExpressionPart(firstOperand, secondOperand, operation *shuntingYard.RPNToken, id string, duration time.Duration) *ExpressionPart {
+func NewExpressionPart(ctx context.Context, operands []*Token, operation *Token, id string, duration time.Duration, exact bool) *ExpressionPart {
 	return &ExpressionPart{
-		FirstOperand:  firstOperand,
-		SecondOperand: secondOperand,
-		Operation:     operation,
-		Duration:      duration,
-		IdExpression:  id,
-		Result:        make(chan *shuntingYard.RPNToken),
+		Ctx:          ctx,
+		Operands:     operands,
+		Operation:    operation,
+		Duration:     duration,
+		IdExpression: id,
+		Exact:        exact,
+		Result:       make(chan *Token),
 	}
 }
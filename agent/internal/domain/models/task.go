@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Task is one operation of expression given by orchestrator
+type Task struct {
+	IdTask            int              `json:"id"`
+	IdExpression      string           `json:"idExpression"`
+	PostfixExpression []*Token         `json:"postfix"`
+	Duration          time.Duration    `json:"duration"` // how long every operation of task takes
+	Exact             bool             `json:"exact"`    // evaluate in rationals instead of float64
+	Deadline          time.Time        `json:"deadline"` // when expression times out, zero if there is no deadline
+	Result            string           `json:"result"`
+	Err               *EvaluationError `json:"error"` // why task can't be evaluated, then Result is empty
+}
+
+func Create(idTask int, idExpression string, parsedExpression []*Token, duration time.Duration, exact bool) Task {
+	return Task{
+		IdTask:            idTask,
+		IdExpression:      idExpression,
+		PostfixExpression: parsedExpression,
+		Duration:          duration,
+		Exact:             exact,
+	}
+}
+
+// Types of tokens
+const (
+	Operation int = 2
+	Operand   int = 1
+	Function  int = 3
+)
@@ -0,0 +1,13 @@
+package models
+
+// Token is element of task in postfix notation: operand, operator or function
+type Token struct {
+	Type  int    `json:"type"`  // Operand, Operation or Function
+	Value string `json:"value"` // value of operand: float or exact rational like "1/3"
+	Name  string `json:"name"`  // name of operator or function, e.g. "+" or "max"
+	Arity int    `json:"arity"` // how many operands operator or function takes
+}
+
+func NewOperandToken(value string) *Token {
+	return &Token{Type: Operand, Value: value}
+}
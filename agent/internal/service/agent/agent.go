@@ -1,11 +1,12 @@
 package agent
 
 import (
+	"context"
+	"errors"
 	"log"
 	"sync"
 	"time"
 
-	shuntingYard "github.com/a-romash/go-shunting-yard"
 	"github.com/a-romash/grpc-calculator/agent/internal/domain/models"
 )
 
@@ -13,10 +14,9 @@ type Agent struct {
 	mu          sync.Mutex
 	Calculators []*Calculator
 	Queue       chan *models.ExpressionPart
-	Durations   map[string]time.Duration
 }
 
-func New(countCalculators int, durations map[string]time.Duration) *Agent {
+func New(countCalculators int) *Agent {
 	queue := make(chan *models.ExpressionPart)
 	miniCalcs := make([]*Calculator, countCalculators)
 
@@ -27,7 +27,6 @@ func New(countCalculators int, durations map[string]time.Duration) *Agent {
 	a := &Agent{
 		Calculators: miniCalcs,
 		Queue:       queue,
-		Durations:   durations,
 	}
 
 	go a.distributeTasks()
@@ -35,10 +34,17 @@ func New(countCalculators int, durations map[string]time.Duration) *Agent {
 	return a
 }
 
-func (a *Agent) AddTask(exp *models.ExpressionPart) {
+// AddTask waits until operation is taken to queue. It returns false, if context of operation is cancelled before
+func (a *Agent) AddTask(exp *models.ExpressionPart) bool {
 	a.mu.Lock()
-	a.Queue <- exp
-	a.mu.Unlock()
+	defer a.mu.Unlock()
+
+	select {
+	case a.Queue <- exp:
+		return true
+	case <-exp.Ctx.Done():
+		return false
+	}
 }
 
 func (a *Agent) distributeTasks() {
@@ -62,47 +68,83 @@ func (a *Agent) distributeTasks() {
 	}
 }
 
-func (a *Agent) SolveExpression(exp *models.Expression) {
-	stack := make([]*shuntingYard.RPNToken, 0)
-
-	// fmt.Println(exp.PostfixExpression)
+// SolveTask evaluates task by sending its operations to calculators.
+// If it can't be evaluated, task.Err is set instead of task.Result.
+// Task which can't be finished before deadline of ctx is stopped with models.ErrDeadline.
+// When ctx is cancelled, evaluating stops and ctx.Err() is returned.
+//
+// progress, if it isn't nil, is called with every operation evaluated successfully and its index in task
+func (a *Agent) SolveTask(ctx context.Context, task *models.Task, progress func(step int, part *models.ExpressionPart, result *models.Token)) error {
+	stack := make([]*models.Token, 0)
+
+	// every operation takes task.Duration, so it's known beforehand if deadline will be missed
+	left := 0
+	for _, token := range task.PostfixExpression {
+		if token.Type != models.Operand {
+			left++
+		}
+	}
+	step := 0
 
-	for _, token := range exp.PostfixExpression {
-		// fmt.Println(stack)
+	for _, token := range task.PostfixExpression {
 		if token.Type == models.Operand {
 			stack = append(stack, token)
 			continue
 		}
 
-		if len(stack) < 2 {
-			continue
+		// operator or function takes as many operands as its arity
+		if token.Arity < 1 || len(stack) < token.Arity {
+			task.Err = models.NewEvaluationError(models.ErrInvalidTask, "not enough operands for %s", token.Name)
+			return nil
 		}
-		num2 := stack[len(stack)-1]
-		stack = stack[:len(stack)-1]
-		num1 := stack[len(stack)-1]
-		stack = stack[:len(stack)-1]
+		operands := stack[len(stack)-token.Arity:]
+		stack = stack[:len(stack)-token.Arity]
 
-		duration := a.GetOperationDuration(token.Value.(string))
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < time.Duration(left)*task.Duration {
+			task.Err = models.NewEvaluationError(models.ErrDeadline, "task can't be finished before deadline")
+			return nil
+		}
+		left--
 
-		exprPart := models.NewExpressionPart(num1, num2, token, exp.IdExpression, duration)
-		a.AddTask(exprPart)
+		// duration of operation is set by orchestrator and can be changed at any time
+		exprPart := models.NewExpressionPart(ctx, operands, token, task.IdExpression, task.Duration, task.Exact)
+		if !a.AddTask(exprPart) {
+			return stopped(ctx, task)
+		}
 
-		stack = append(stack, <-exprPart.Result)
-		close(exprPart.Result)
+		var result *models.Token
+		select {
+		case result = <-exprPart.Result:
+			close(exprPart.Result)
+		case <-ctx.Done():
+			// calculator drops the operation itself
+			return stopped(ctx, task)
+		}
+		if result == nil {
+			task.Err = exprPart.Err
+			return nil
+		}
+		if progress != nil {
+			progress(step, exprPart, result)
+		}
+		step++
+		stack = append(stack, result)
+	}
+	if len(stack) != 1 {
+		task.Err = models.NewEvaluationError(models.ErrInvalidTask, "task has %d values instead of one", len(stack))
+		return nil
 	}
-	// fmt.Print("123")
-	// result, _ := shuntingYard.Evaluate(exp.PostfixExpression)
-
-	setResultsToExpression(exp, stack[0].Value.(float64))
-}
 
-func setResultsToExpression(exp *models.Expression, result float64) {
-	exp.Result = result
-	exp.Status = models.Solved
-	timeCompleted := time.Now()
-	exp.SolvedAt = timeCompleted
+	task.Result = stack[0].Value
+	return nil
 }
 
-func (a *Agent) GetOperationDuration(operation string) time.Duration {
-	return a.Durations[operation]
+// stopped is returned by SolveTask, when ctx is done. Task which missed deadline is failed,
+// and nobody waits for result of cancelled one
+func stopped(ctx context.Context, task *models.Task) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		task.Err = models.NewEvaluationError(models.ErrDeadline, "deadline of expression has passed")
+		return nil
+	}
+	return ctx.Err()
 }
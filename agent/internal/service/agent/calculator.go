@@ -2,9 +2,10 @@ package agent
 
 import (
 	"log"
+	"math/big"
+	"strconv"
 	"time"
 
-	shuntingYard "github.com/a-romash/go-shunting-yard"
 	"github.com/a-romash/grpc-calculator/agent/internal/domain/models"
 )
 
@@ -62,13 +63,84 @@ func (c *Calculator) Start() {
 	}()
 }
 
+// SolveExpression evaluates operation and sends result back. If operation is aborted, it's dropped without result
 func (c *Calculator) SolveExpression(expr *models.ExpressionPart) {
-	time.Sleep(expr.Duration)
+	started := time.Now()
+	timer := time.NewTimer(expr.Duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-expr.Ctx.Done():
+		log.Printf("Calculator[%v]: task is aborted", c.id)
+		return
+	}
 
-	if result, err := shuntingYard.Evaluate([]*shuntingYard.RPNToken{expr.FirstOperand, expr.SecondOperand, expr.Operation}); err == nil {
-		tokenizedResult := shuntingYard.NewRPNOperandToken(result)
-		expr.Result <- tokenizedResult
+	var (
+		result string
+		err    error
+	)
+	if expr.Exact {
+		result, err = solveExact(expr)
+	} else {
+		result, err = solveFloat(expr)
+	}
+	if err != nil {
+		log.Printf("Calculator[%v]: %v", c.id, err)
+		evalErr, ok := err.(*models.EvaluationError)
+		if !ok {
+			evalErr = models.NewEvaluationError(models.ErrInvalidTask, "%v", err)
+		}
+		// error is set before sending, so it's seen by receiver of nil token
+		expr.Err = evalErr
+		c.send(expr, nil, started)
 		return
 	}
-	expr.Result <- shuntingYard.NewRPNOperandToken(0)
+	c.send(expr, models.NewOperandToken(result), started)
+}
+
+// send gives result to agent, unless nobody waits for it because operation was aborted
+func (c *Calculator) send(expr *models.ExpressionPart, result *models.Token, started time.Time) {
+	expr.Calculator = c.id
+	expr.Elapsed = time.Since(started)
+
+	select {
+	case expr.Result <- result:
+	case <-expr.Ctx.Done():
+		log.Printf("Calculator[%v]: result of aborted task is dropped", c.id)
+	}
+}
+
+func solveFloat(expr *models.ExpressionPart) (string, error) {
+	args := make([]float64, len(expr.Operands))
+	for i, operand := range expr.Operands {
+		arg, err := strconv.ParseFloat(operand.Value, 64)
+		if err != nil {
+			return "", models.NewEvaluationError(models.ErrInvalidTask, "invalid operand %q", operand.Value)
+		}
+		args[i] = arg
+	}
+
+	result, err := evaluate(expr.Operation.Name, args)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(result, 'g', -1, 64), nil
+}
+
+func solveExact(expr *models.ExpressionPart) (string, error) {
+	args := make([]*big.Rat, len(expr.Operands))
+	for i, operand := range expr.Operands {
+		arg, ok := new(big.Rat).SetString(operand.Value)
+		if !ok {
+			return "", models.NewEvaluationError(models.ErrInvalidTask, "invalid operand %q", operand.Value)
+		}
+		args[i] = arg
+	}
+
+	result, err := evaluateExact(expr.Operation.Name, args)
+	if err != nil {
+		return "", err
+	}
+	return formatRat(result), nil
 }
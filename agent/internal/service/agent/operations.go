@@ -0,0 +1,206 @@
+package agent
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/a-romash/grpc-calculator/agent/internal/domain/models"
+)
+
+// evaluate applies operator or function to its operands.
+// Result which isn't finite float is an error, e.g. 1/0 or sqrt(-1)
+func evaluate(operation string, args []float64) (float64, error) {
+	result, err := evaluateFloat(operation, args)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(result) {
+		return 0, models.NewEvaluationError(models.ErrDomain, "%s isn't defined for %v", operation, args)
+	}
+	if math.IsInf(result, 0) {
+		return 0, models.NewEvaluationError(models.ErrOutOfRange, "result of %s is out of range of float64", operation)
+	}
+	return result, nil
+}
+
+func evaluateFloat(operation string, args []float64) (float64, error) {
+	if len(args) == 0 {
+		return 0, models.NewEvaluationError(models.ErrInvalidTask, "%s: no operands", operation)
+	}
+
+	switch operation {
+	case "neg":
+		return -args[0], nil
+	case "sqrt":
+		if args[0] < 0 {
+			return 0, models.NewEvaluationError(models.ErrDomain, "square root of negative number %v", args[0])
+		}
+		return math.Sqrt(args[0]), nil
+	case "abs":
+		return math.Abs(args[0]), nil
+	case "sin":
+		return math.Sin(args[0]), nil
+	case "cos":
+		return math.Cos(args[0]), nil
+	case "log":
+		if args[0] <= 0 {
+			return 0, models.NewEvaluationError(models.ErrDomain, "logarithm of non-positive number %v", args[0])
+		}
+		return math.Log(args[0]), nil
+	case "min":
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = math.Min(result, arg)
+		}
+		return result, nil
+	case "max":
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = math.Max(result, arg)
+		}
+		return result, nil
+	}
+
+	// the rest are binary operators
+	if len(args) != 2 {
+		return 0, models.NewEvaluationError(models.ErrInvalidTask, "%s: expected 2 operands, got %d", operation, len(args))
+	}
+	a, b := args[0], args[1]
+
+	switch operation {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		if b == 0 {
+			return 0, models.NewEvaluationError(models.ErrDivisionByZero, "division by zero")
+		}
+		return a / b, nil
+	case "^":
+		if a == 0 && b < 0 {
+			return 0, models.NewEvaluationError(models.ErrDivisionByZero, "zero can't be raised to negative power")
+		}
+		return math.Pow(a, b), nil
+	default:
+		return 0, models.NewEvaluationError(models.ErrUnknownOperation, "unknown operation: %s", operation)
+	}
+}
+
+// maxExactExponent limits power in exact mode, because size of rational grows linearly with exponent
+const maxExactExponent = 1024
+
+// evaluateExact applies operator or function to rational operands. Only operations
+// with exact result are supported, the orchestrator rejects the others while parsing
+func evaluateExact(operation string, args []*big.Rat) (*big.Rat, error) {
+	if len(args) == 0 {
+		return nil, models.NewEvaluationError(models.ErrInvalidTask, "%s: no operands", operation)
+	}
+
+	switch operation {
+	case "neg":
+		return new(big.Rat).Neg(args[0]), nil
+	case "abs":
+		return new(big.Rat).Abs(args[0]), nil
+	case "min":
+		result := args[0]
+		for _, arg := range args[1:] {
+			if arg.Cmp(result) < 0 {
+				result = arg
+			}
+		}
+		return result, nil
+	case "max":
+		result := args[0]
+		for _, arg := range args[1:] {
+			if arg.Cmp(result) > 0 {
+				result = arg
+			}
+		}
+		return result, nil
+	}
+
+	if len(args) != 2 {
+		return nil, models.NewEvaluationError(models.ErrInvalidTask, "%s: expected 2 operands, got %d", operation, len(args))
+	}
+	a, b := args[0], args[1]
+
+	switch operation {
+	case "+":
+		return new(big.Rat).Add(a, b), nil
+	case "-":
+		return new(big.Rat).Sub(a, b), nil
+	case "*":
+		return new(big.Rat).Mul(a, b), nil
+	case "/":
+		if b.Sign() == 0 {
+			return nil, models.NewEvaluationError(models.ErrDivisionByZero, "division by zero")
+		}
+		return new(big.Rat).Quo(a, b), nil
+	case "^":
+		return powRat(a, b)
+	default:
+		return nil, models.NewEvaluationError(models.ErrUnknownOperation, "unknown operation: %s", operation)
+	}
+}
+
+// powRat raises rational to integer power
+func powRat(base, exponent *big.Rat) (*big.Rat, error) {
+	if !exponent.IsInt() {
+		return nil, models.NewEvaluationError(models.ErrInexact, "exponent should be integer in exact mode")
+	}
+	if !exponent.Num().IsInt64() || abs64(exponent.Num().Int64()) > maxExactExponent {
+		return nil, models.NewEvaluationError(models.ErrOutOfRange, "exponent is too big, max is %d", maxExactExponent)
+	}
+
+	n := exponent.Num().Int64()
+	if n < 0 && base.Sign() == 0 {
+		return nil, models.NewEvaluationError(models.ErrDivisionByZero, "zero can't be raised to negative power")
+	}
+
+	num := new(big.Int).Exp(base.Num(), big.NewInt(abs64(n)), nil)
+	denom := new(big.Int).Exp(base.Denom(), big.NewInt(abs64(n)), nil)
+	if n < 0 {
+		num, denom = denom, num
+	}
+	return new(big.Rat).SetFrac(num, denom), nil
+}
+
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// formatRat formats rational exactly: as integer or decimal if it's finite, otherwise as fraction "p/q".
+// It's the same format as the orchestrator uses for operands
+func formatRat(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+
+	// Decimal is finite only if denominator has no prime factors except 2 and 5
+	denom := new(big.Int).Set(r.Denom())
+	digits := 0
+	for _, p := range []int64{2, 5} {
+		prime := big.NewInt(p)
+		count := 0
+		mod := new(big.Int)
+		for {
+			quo, m := new(big.Int).QuoRem(denom, prime, mod)
+			if m.Sign() != 0 {
+				break
+			}
+			denom = quo
+			count++
+		}
+		digits = max(digits, count)
+	}
+	if denom.Cmp(big.NewInt(1)) != 0 {
+		return r.RatString()
+	}
+	return r.FloatString(digits)
+}
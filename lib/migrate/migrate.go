@@ -0,0 +1,393 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Migration is versioned change of schema, Up applies it and Down reverts it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// State is migration and when it was applied, AppliedAt is nil for pending one
+type State struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrator applies migrations of one service. Services share database and table schema_migrations,
+// every one of them has its own versions there
+type Migrator struct {
+	log        *slog.Logger
+	pool       *pgxpool.Pool
+	service    string
+	migrations []Migration
+	requires   []requirement
+}
+
+// requirement is version of other service, which should be applied before migrations of this one
+type requirement struct {
+	service string
+	version int
+}
+
+// how often Up checks migrations of required services
+const waitInterval = 2 * time.Second
+
+// files of migrations are named like 0001_init.up.sql and 0001_init.down.sql
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var ErrUnknownMigration = errors.New("migration is applied, but it isn't known")
+
+func New(log *slog.Logger, pool *pgxpool.Pool, service string, fsys fs.FS) (*Migrator, error) {
+	const op = "migrate.New"
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Migrator{
+		log:        log,
+		pool:       pool,
+		service:    service,
+		migrations: migrations,
+	}, nil
+}
+
+// Load reads migrations from root of fsys and sorts them by version. Every migration should have both scripts
+func Load(fsys fs.FS) ([]Migration, error) {
+	const op = "migrate.Load"
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%s: file %q isn't named like 0001_name.up.sql", op, entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		script, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("%s: version %d is used by %s and %s", op, version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(script)
+		} else {
+			m.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("%s: migration %d_%s should have up and down scripts", op, m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Require makes Up wait until migrations of service are applied up to version,
+// e.g. when tables of this service reference tables of that one
+func (m *Migrator) Require(service string, version int) {
+	m.requires = append(m.requires, requirement{service: service, version: version})
+}
+
+// Version returns last applied version of migrations of service, it's 0 if none of them is applied
+func Version(ctx context.Context, pool *pgxpool.Pool, service string) (int, error) {
+	const op = "migrate.Version"
+
+	const sql = `
+	SELECT to_regclass('schema_migrations') IS NOT NULL;
+	`
+
+	var exists bool
+	if err := pool.QueryRow(ctx, sql).Scan(&exists); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	const sql2 = `
+	SELECT COALESCE(MAX(version), 0) FROM schema_migrations
+	WHERE service = $1;
+	`
+
+	var version int
+	if err := pool.QueryRow(ctx, sql2, service).Scan(&version); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return version, nil
+}
+
+// Up applies every pending migration and returns how many of them were applied.
+// It waits for required services first, until ctx is done
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	const op = "migrate.Up"
+
+	for _, required := range m.requires {
+		if err := m.wait(ctx, required); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	count := 0
+	err := m.locked(ctx, func(conn *pgxpool.Conn, applied map[int]State) error {
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration, true); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("%s: %w", op, err)
+	}
+	return count, nil
+}
+
+// Down reverts steps last applied migrations and returns how many of them were reverted
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	const op = "migrate.Down"
+
+	count := 0
+	err := m.locked(ctx, func(conn *pgxpool.Conn, applied map[int]State) error {
+		versions := make([]int, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+		for _, version := range versions[:min(steps, len(versions))] {
+			migration, ok := m.find(version)
+			if !ok {
+				// it's applied by newer version of service, this one doesn't know how to revert it
+				return fmt.Errorf("%w: %d_%s", ErrUnknownMigration, version, applied[version].Name)
+			}
+			if err := m.apply(ctx, conn, migration, false); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("%s: %w", op, err)
+	}
+	return count, nil
+}
+
+// Status returns known migrations and applied ones, which aren't known, sorted by version
+func (m *Migrator) Status(ctx context.Context) ([]State, error) {
+	const op = "migrate.Status"
+
+	var states []State
+	err := m.locked(ctx, func(conn *pgxpool.Conn, applied map[int]State) error {
+		for _, migration := range m.migrations {
+			state, ok := applied[migration.Version]
+			if !ok {
+				state = State{Version: migration.Version, Name: migration.Name}
+			}
+			states = append(states, state)
+			delete(applied, migration.Version)
+		}
+		for _, state := range applied {
+			states = append(states, state)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Version < states[j].Version
+	})
+	return states, nil
+}
+
+// wait polls schema_migrations until required version of other service is applied
+func (m *Migrator) wait(ctx context.Context, required requirement) error {
+	logged := false
+	for {
+		version, err := Version(ctx, m.pool, required.service)
+		if err != nil {
+			return err
+		}
+		if version >= required.version {
+			return nil
+		}
+		if !logged {
+			m.log.Info("waiting for migrations of other service",
+				slog.String("service", m.service),
+				slog.String("required", required.service),
+				slog.Int("version", required.version),
+			)
+			logged = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("migrations of %s aren't applied up to version %d: %w", required.service, required.version, ctx.Err())
+		case <-time.After(waitInterval):
+		}
+	}
+}
+
+func (m *Migrator) find(version int) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// locked runs f holding advisory lock, so migrations of services which are started at once are applied one by one.
+// f gets migrations of service which are already applied
+func (m *Migrator) locked(ctx context.Context, f func(conn *pgxpool.Conn, applied map[int]State) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	// lock is held by connection, so all migrations are run on it
+	const sql = `
+	SELECT pg_advisory_lock(hashtext('schema_migrations'));
+	`
+
+	if _, err = conn.Exec(ctx, sql); err != nil {
+		return err
+	}
+	defer func() {
+		const sql = `
+		SELECT pg_advisory_unlock(hashtext('schema_migrations'));
+		`
+
+		// lock is released with connection, if it can't be released otherwise
+		if _, err := conn.Exec(context.Background(), sql); err != nil {
+			m.log.Error("failed to release lock of migrations", slog.String("error", err.Error()))
+			conn.Conn().Close(context.Background())
+		}
+	}()
+
+	const sql2 = `
+	CREATE TABLE IF NOT EXISTS schema_migrations(
+		service VARCHAR(255) NOT NULL,
+		version INT NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (service, version)
+	);
+	`
+
+	if _, err = conn.Exec(ctx, sql2); err != nil {
+		return err
+	}
+
+	const sql3 = `
+	SELECT version, name, applied_at FROM schema_migrations
+	WHERE service = $1;
+	`
+
+	rows, _ := conn.Query(ctx, sql3, m.service)
+	states, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (State, error) {
+		var state State
+		err := row.Scan(&state.Version, &state.Name, &state.AppliedAt)
+		return state, err
+	})
+	if err != nil {
+		return err
+	}
+
+	applied := make(map[int]State, len(states))
+	for _, state := range states {
+		applied[state.Version] = state
+	}
+	return f(conn, applied)
+}
+
+// apply runs script of migration and records it in one transaction, so migration is applied entirely or not at all
+func (m *Migrator) apply(ctx context.Context, conn *pgxpool.Conn, migration Migration, up bool) error {
+	log := m.log.With(
+		slog.String("service", m.service),
+		slog.Int("version", migration.Version),
+		slog.String("name", migration.Name),
+	)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	script, record := migration.Up, `
+	INSERT INTO schema_migrations (service, version, name)
+	VALUES ($1, $2, $3);
+	`
+	if !up {
+		script, record = migration.Down, `
+		DELETE FROM schema_migrations
+		WHERE service = $1 AND version = $2 AND name = $3;
+		`
+	}
+
+	// script without arguments is sent as is, so it can have many statements
+	if _, err = tx.Exec(ctx, script); err != nil {
+		return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	if _, err = tx.Exec(ctx, record, m.service, migration.Version, migration.Name); err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	if up {
+		log.Info("migration is applied")
+	} else {
+		log.Info("migration is reverted")
+	}
+	return nil
+}
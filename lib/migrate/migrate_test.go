@@ -0,0 +1,225 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestLoadSortsByVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_column.up.sql":   {Data: []byte("ALTER TABLE t ADD COLUMN b INT;")},
+		"0002_add_column.down.sql": {Data: []byte("ALTER TABLE t DROP COLUMN b;")},
+		"0001_init.up.sql":         {Data: []byte("CREATE TABLE t(a INT);")},
+		"0001_init.down.sql":       {Data: []byte("DROP TABLE t;")},
+	}
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("got %d migrations; want 2", len(migrations))
+	}
+	if migrations[0].Version != 1 || migrations[0].Name != "init" || migrations[0].Down != "DROP TABLE t;" {
+		t.Errorf("first migration is %+v", migrations[0])
+	}
+	if migrations[1].Version != 2 || migrations[1].Name != "add_column" {
+		t.Errorf("second migration is %+v", migrations[1])
+	}
+}
+
+func TestLoadRejectsInvalidFiles(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"без down": {
+			"0001_init.up.sql": {Data: []byte("SELECT 1;")},
+		},
+		"неверное имя": {
+			"init.sql": {Data: []byte("SELECT 1;")},
+		},
+		"одна версия у двух миграций": {
+			"0001_init.up.sql":    {Data: []byte("SELECT 1;")},
+			"0001_init.down.sql":  {Data: []byte("SELECT 1;")},
+			"0001_other.up.sql":   {Data: []byte("SELECT 1;")},
+			"0001_other.down.sql": {Data: []byte("SELECT 1;")},
+		},
+	}
+
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(fsys); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+}
+
+// Тест ходит в настоящий postgres, адрес берётся из TEST_DATABASE_URL. Без него тест пропускается
+func TestConcurrentUpAndDown(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	// у теста своя таблица и свой сервис в schema_migrations
+	suffix := time.Now().UnixNano()
+	service := fmt.Sprintf("migrate-test-%d", suffix)
+	table := fmt.Sprintf("migrate_test_%d", suffix)
+	fsys := fstest.MapFS{
+		"0001_create.up.sql":   {Data: []byte(fmt.Sprintf("CREATE TABLE %s(a INT);", table))},
+		"0001_create.down.sql": {Data: []byte(fmt.Sprintf("DROP TABLE %s;", table))},
+		"0002_column.up.sql":   {Data: []byte(fmt.Sprintf("ALTER TABLE %s ADD COLUMN b INT;", table))},
+		"0002_column.down.sql": {Data: []byte(fmt.Sprintf("ALTER TABLE %s DROP COLUMN b;", table))},
+	}
+	t.Cleanup(func() {
+		pool.Exec(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s;", table))
+		pool.Exec(ctx, "DELETE FROM schema_migrations WHERE service = $1;", service)
+	})
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	migrator, err := New(log, pool, service, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// несколько экземпляров стартуют одновременно, миграции применяются ровно один раз
+	const starts = 5
+	applied := make([]int, starts)
+	errs := make([]error, starts)
+	var wg sync.WaitGroup
+	for i := 0; i < starts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			applied[i], errs[i] = migrator.Up(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range applied {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		total += applied[i]
+	}
+	if total != 2 {
+		t.Errorf("applied %d migrations; want 2", total)
+	}
+
+	states, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 2 || states[0].AppliedAt == nil || states[1].AppliedAt == nil {
+		t.Errorf("states are %+v; want both applied", states)
+	}
+
+	reverted, err := migrator.Down(ctx, 1)
+	if err != nil || reverted != 1 {
+		t.Fatalf("Down reverted %d: %v", reverted, err)
+	}
+	states, err = migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if states[0].AppliedAt == nil || states[1].AppliedAt != nil {
+		t.Errorf("states are %+v; want only the first applied", states)
+	}
+
+	// откатываются только применённые миграции
+	reverted, err = migrator.Down(ctx, 10)
+	if err != nil || reverted != 1 {
+		t.Fatalf("Down reverted %d: %v", reverted, err)
+	}
+	var exists bool
+	if err = pool.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL;", table).Scan(&exists); err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("table isn't dropped by down migration")
+	}
+}
+
+// Тест ходит в настоящий postgres, как и предыдущий
+func TestUpWaitsForRequiredService(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	suffix := time.Now().UnixNano()
+	owner := fmt.Sprintf("migrate-test-owner-%d", suffix)
+	service := fmt.Sprintf("migrate-test-%d", suffix)
+	table := fmt.Sprintf("migrate_test_owner_%d", suffix)
+	ownerFS := fstest.MapFS{
+		"0001_create.up.sql":   {Data: []byte(fmt.Sprintf("CREATE TABLE %s(id INT PRIMARY KEY);", table))},
+		"0001_create.down.sql": {Data: []byte(fmt.Sprintf("DROP TABLE %s;", table))},
+	}
+	// таблица сервиса ссылается на таблицу владельца
+	fsys := fstest.MapFS{
+		"0001_create.up.sql":   {Data: []byte(fmt.Sprintf("CREATE TABLE %s_ref(id INT REFERENCES %s(id));", table, table))},
+		"0001_create.down.sql": {Data: []byte(fmt.Sprintf("DROP TABLE %s_ref;", table))},
+	}
+	t.Cleanup(func() {
+		pool.Exec(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s_ref, %s;", table, table))
+		pool.Exec(ctx, "DELETE FROM schema_migrations WHERE service = $1 OR service = $2;", owner, service)
+	})
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	migrator, err := New(log, pool, service, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator.Require(owner, 1)
+
+	// без миграций владельца Up ждёт, пока не закончится контекст
+	timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if applied, err := migrator.Up(timeout); err == nil || applied != 0 {
+		t.Fatalf("Up applied %d: %v; want error", applied, err)
+	}
+
+	ownerMigrator, err := New(log, pool, owner, ownerFS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := migrator.Up(ctx)
+		done <- err
+	}()
+	if _, err = ownerMigrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := Version(ctx, pool, service)
+	if err != nil || version != 1 {
+		t.Errorf("version is %d: %v; want 1", version, err)
+	}
+}
@@ -1,17 +1,25 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/a-romash/grpc-calculator/orchestrator/internal/app"
 	"github.com/a-romash/grpc-calculator/orchestrator/internal/config"
 	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/logger/handlers/slogpretty"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/logger/sl"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/storage/memory"
 	"github.com/a-romash/grpc-calculator/orchestrator/internal/storage/postgres"
 )
 
+// hubRetryInterval is pause before listening for updates of expressions again, when connection to db is lost
+const hubRetryInterval = 3 * time.Second
+
 const (
 	envLocal = "local"
 	envDev   = "dev"
@@ -23,12 +31,18 @@ func main() {
 
 	log := setupLogger(cfg.Env)
 
-	storage, err := postgres.Connect(cfg.DatabaseUrl)
-	if err != nil {
-		panic(err)
+	// ./server --config ./config/config.yaml migrate [up | down [n] | status]
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(log, cfg.DatabaseUrl, flag.Args()[1:]); err != nil {
+			log.Error("failed to migrate", sl.Err(err))
+			os.Exit(1)
+		}
+		return
 	}
 
-	application := app.New(log, cfg.GRPC.Port, storage, cfg.TokenTTL, cfg.HTTP.Port, cfg.GRPCClient.Addr, cfg.GRPCClient.RetriesCount, cfg.Secret)
+	storage := mustSetupStorage(log, cfg.Storage.Type, cfg.DatabaseUrl)
+
+	application := app.New(log, cfg, storage)
 	if application.HTTPServer == nil {
 		panic("httpserver is nil!!1!")
 	}
@@ -39,6 +53,12 @@ func main() {
 		application.GRPCServer.MustRun()
 	}()
 
+	ctx, cancel := context.WithCancel(context.Background())
+	go application.OrchService.RunReaper(ctx, cfg.Reaper.Interval)
+	go application.OrchService.RunDeadlines(ctx, cfg.Reaper.DeadlineInterval)
+	go application.Webhooks.Run(ctx, cfg.Webhooks.Interval)
+	go application.Hub.Run(ctx, hubRetryInterval)
+
 	// Graceful stop
 
 	stop := make(chan os.Signal, 1)
@@ -46,9 +66,26 @@ func main() {
 
 	<-stop
 
+	cancel()
 	application.GRPCServer.Stop()
 }
 
+// mustSetupStorage connects to postgres or creates memory storage, which doesn't need database
+func mustSetupStorage(log *slog.Logger, storageType string, databaseUrl string) app.Storage {
+	switch storageType {
+	case config.StoragePostgres:
+		storage, err := postgres.Connect(databaseUrl)
+		if err != nil {
+			panic(err)
+		}
+		return storage
+	case config.StorageMemory:
+		log.Warn("storage is in memory, everything is lost on restart")
+		return memory.New()
+	}
+	panic("unknown type of storage: " + storageType)
+}
+
 func setupLogger(env string) *slog.Logger {
 	var log *slog.Logger
 
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/storage/postgres"
+)
+
+// runMigrate runs command "migrate": up applies pending migrations, down [n] reverts n last ones (1 by default)
+// and status lists them. Migrations are applied at start too, so up is needed only to apply them in advance
+func runMigrate(log *slog.Logger, databaseUrl string, args []string) error {
+	db, err := postgres.Open(databaseUrl)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := db.Migrator(log)
+	if err != nil {
+		return err
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		log.Info("migrations are applied", slog.Int("applied", applied))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("number of migrations to revert should be positive, got %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		log.Info("migrations are reverted", slog.Int("reverted", reverted))
+	case "status":
+		states, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, state := range states {
+			applied := "pending"
+			if state.AppliedAt != nil {
+				applied = state.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d %-30s %s\n", state.Version, state.Name, applied)
+		}
+	default:
+		return fmt.Errorf("unknown command %q, it should be up, down or status", command)
+	}
+	return nil
+}
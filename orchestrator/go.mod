@@ -4,6 +4,7 @@ go 1.22.1
 
 require (
 	github.com/a-romash/go-shunting-yard v0.0.0-20240416170645-a0e20ad914c0
+	github.com/a-romash/grpc-calculator/lib v0.0.0-00010101000000-000000000000
 	github.com/a-romash/protos v0.0.0-20240427235838-d22b5aa9dbe4
 	github.com/fatih/color v1.16.0
 	github.com/gorilla/mux v1.8.1
@@ -39,3 +40,5 @@ require (
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	golang.org/x/sys v0.19.0 // indirect
 )
+
+replace github.com/a-romash/grpc-calculator/lib => ../lib
@@ -2,27 +2,60 @@ package app
 
 import (
 	"log/slog"
-	"time"
 
 	grpcapp "github.com/a-romash/grpc-calculator/orchestrator/internal/app/grpc"
 	httpapp "github.com/a-romash/grpc-calculator/orchestrator/internal/app/http"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/config"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/notify"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/safeurl"
+	httpservice "github.com/a-romash/grpc-calculator/orchestrator/internal/service/http"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/service/hub"
 	orch "github.com/a-romash/grpc-calculator/orchestrator/internal/service/orchestrator"
-	"github.com/a-romash/grpc-calculator/orchestrator/internal/storage/postgres"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/service/webhook"
 )
 
 type App struct {
-	GRPCServer *grpcapp.App
-	HTTPServer *httpapp.HTTPApp
+	GRPCServer  *grpcapp.App
+	HTTPServer  *httpapp.HTTPApp
+	OrchService *orch.Orchestrator
+	Webhooks    *webhook.Sender
+	Hub         *hub.Hub
 }
 
-func New(log *slog.Logger, grpcPort int, storage *postgres.Postgresql, tokenTTL time.Duration, httpPort int, addr string, retriesCount int, secret string) *App {
-	orchService := orch.New(log, storage)
+// Storage is everything which services need from storage, see postgres.Postgresql and memory.Storage
+type Storage interface {
+	orch.ExpressionStorage
+	httpservice.ExpressionStorage
+	webhook.WebhookStorage
+	hub.UpdatesStorage
+}
+
+func New(log *slog.Logger, cfg *config.Config, storage Storage) *App {
+	// HTTP service saves new tasks and orchestrator pushes them to agents
+	tasksReady := notify.New()
+	// HTTP service cancels expressions and orchestrator tells agents to abort their tasks
+	cancelled := notify.New()
+	orchService := orch.New(log, storage, cfg.Reaper.AgentTimeout, cfg.Reaper.MaxRequeues, cfg.Scheduler.MaxRunningPerUser, tasksReady, cancelled)
+
+	// transitions and progress of expressions are streamed to users watching them
+	updates := hub.New(log, storage)
+
+	// users choose where webhooks are sent, so they can't be sent into our network
+	guard, err := safeurl.New(cfg.Webhooks.AllowedNetworks)
+	if err != nil {
+		panic(err)
+	}
+
+	grpcApp := grpcapp.New(log, orchService, cfg.GRPC.Port)
+	httpApp := httpapp.New(log, cfg.HTTP.Port, cfg.GRPCClient.Addr, cfg.GRPCClient.RetriesCount, storage, cfg.Secret, tasksReady, cancelled, cfg.HTTP.IdempotencyTTL, cfg.Cache.Size, updates, guard)
 
-	grpcApp := grpcapp.New(log, orchService, grpcPort)
-	httpApp := httpapp.New(log, httpPort, addr, retriesCount, storage, secret)
+	webhooks := webhook.New(log, storage, cfg.Webhooks, guard)
 
 	return &App{
-		GRPCServer: grpcApp,
-		HTTPServer: httpApp,
+		GRPCServer:  grpcApp,
+		HTTPServer:  httpApp,
+		OrchService: orchService,
+		Webhooks:    webhooks,
+		Hub:         updates,
 	}
 }
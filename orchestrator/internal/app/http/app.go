@@ -8,7 +8,10 @@ import (
 
 	"github.com/a-romash/grpc-calculator/orchestrator/internal/clients/sso/grpc"
 	"github.com/a-romash/grpc-calculator/orchestrator/internal/http-server/server"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/notify"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/safeurl"
 	httpservice "github.com/a-romash/grpc-calculator/orchestrator/internal/service/http"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/service/hub"
 )
 
 type HTTPApp struct {
@@ -23,6 +26,12 @@ func New(
 	retriesCount int,
 	storage httpservice.ExpressionStorage,
 	secret string,
+	tasksReady *notify.Notifier,
+	cancelled *notify.Notifier,
+	idempotencyTTL time.Duration,
+	cacheSize int, // how many results of expressions are kept in memory
+	hub *hub.Hub,
+	guard *safeurl.Guard, // checks callback urls of expressions
 ) *HTTPApp {
 	app_id, err := storage.RegisterApp(context.Background(), "Orchestrator", secret)
 	if err != nil {
@@ -38,7 +47,7 @@ func New(
 		return nil
 	}
 
-	server := server.New(log, storage, port, secret, client)
+	server := server.New(log, storage, port, secret, client, tasksReady, cancelled, idempotencyTTL, cacheSize, hub, guard)
 
 	return &HTTPApp{
 		log:    log,
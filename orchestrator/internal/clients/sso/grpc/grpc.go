@@ -2,6 +2,7 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
@@ -12,6 +13,12 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrUserExists         = errors.New("user already exists")
+	ErrInvalidCredentials = errors.New("invalid email or password")
 )
 
 type Client struct {
@@ -97,6 +104,9 @@ func (c *Client) Register(
 		Password: password,
 	})
 	if err != nil {
+		if status.Code(err) == codes.AlreadyExists {
+			return 0, ErrUserExists
+		}
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -118,6 +128,9 @@ func (c *Client) Login(
 		AppId:    int32(c.app_id),
 	})
 	if err != nil {
+		if status.Code(err) == codes.Unauthenticated {
+			return "", ErrInvalidCredentials
+		}
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
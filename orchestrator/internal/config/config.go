@@ -11,20 +11,66 @@ import (
 type Config struct {
 	Env         string           `yaml:"env"`
 	DatabaseUrl string           `yaml:"database_url"`
+	Storage     StorageConfig    `yaml:"storage"`
 	Secret      string           `yaml:"secret"`
 	GRPC        GRPCConfig       `yaml:"grpc"`
 	GRPCClient  GRPCClientConfig `yaml:"grpc_client"`
 	HTTP        HTTPConfig       `yaml:"http"`
+	Reaper      ReaperConfig     `yaml:"reaper"`
+	Scheduler   SchedulerConfig  `yaml:"scheduler"`
+	Cache       CacheConfig      `yaml:"cache"`
+	Webhooks    WebhooksConfig   `yaml:"webhooks"`
 	TokenTTL    time.Duration    `yaml:"token_ttl"`
 }
 
+// Types of storage
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory" // everything is lost on restart, database isn't needed
+)
+
+// StorageConfig chooses where expressions, tasks and agents are kept
+type StorageConfig struct {
+	Type string `yaml:"type" env-default:"postgres"`
+}
+
 type GRPCConfig struct {
 	Port    int           `yaml:"port"`
 	Timeout time.Duration `yaml:"timeout"`
 }
 
 type HTTPConfig struct {
-	Port int `yaml:"port"`
+	Port           int           `yaml:"port"`
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl" env-default:"24h"` // how long Idempotency-Key of user is kept
+}
+
+// ReaperConfig configures returning tasks of dead agents back to queue and timing out expressions
+type ReaperConfig struct {
+	Interval         time.Duration `yaml:"interval" env-default:"10s"`
+	AgentTimeout     time.Duration `yaml:"agent_timeout" env-default:"30s"`
+	MaxRequeues      int           `yaml:"max_requeues" env-default:"3"`
+	DeadlineInterval time.Duration `yaml:"deadline_interval" env-default:"1s"` // how often expressions are checked for passed deadline
+}
+
+// SchedulerConfig configures giving tasks to agents
+type SchedulerConfig struct {
+	MaxRunningPerUser int `yaml:"max_running_per_user" env-default:"0"` // 0 is no limit
+}
+
+// CacheConfig configures cache of results of expressions
+type CacheConfig struct {
+	Size int `yaml:"size" env-default:"10000"` // how many results are kept in memory, the rest are read from db
+}
+
+// WebhooksConfig configures sending of webhooks about finished expressions
+type WebhooksConfig struct {
+	Interval    time.Duration `yaml:"interval" env-default:"1s"` // how often webhooks which are due are looked for
+	Timeout     time.Duration `yaml:"timeout" env-default:"10s"` // timeout of one attempt
+	MaxAttempts int           `yaml:"max_attempts" env-default:"8"`
+	Backoff     time.Duration `yaml:"backoff" env-default:"1s"` // delay after the first failed attempt, it's doubled after every next one
+	MaxBackoff  time.Duration `yaml:"max_backoff" env-default:"10m"`
+	// networks like "10.0.0.0/8", to which webhooks are sent although they aren't public. By default only public addresses are allowed
+	AllowedNetworks []string `yaml:"allowed_networks"`
 }
 
 type GRPCClientConfig struct {
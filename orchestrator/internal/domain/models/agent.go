@@ -3,7 +3,7 @@ package models
 import "time"
 
 type Agent struct {
-	ID            int       `db:"id"`
-	LastHeartbeat time.Time `db:"last_heartbeat"`
-	Status        string    `db:"status"`
+	ID            int       `json:"id" db:"id"`
+	LastHeartbeat time.Time `json:"lastHeartbeat" db:"last_heartbeat"`
+	Status        string    `json:"status" db:"status"`
 }
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// ExpressionEvent is one transition of expression from status to status, see Status
+type ExpressionEvent struct {
+	ID           int       `json:"-" db:"id"`
+	IdExpression string    `json:"-" db:"id_expression"`
+	FromStatus   *Status   `json:"from" db:"from_status"` // nil for the first event, when expression is created
+	Status       Status    `json:"status" db:"status"`
+	IdAgent      *int      `json:"agentId,omitempty" db:"id_agent"`
+	CreatedAt    time.Time `json:"createdAt" db:"created"`
+}
+
+// ExpressionProgress is one operation of expression, which calculator of agent has evaluated
+type ExpressionProgress struct {
+	IdExpression string    `json:"expressionId"`
+	IdTask       int       `json:"taskId"`
+	Step         int       `json:"step"`      // index of operation in task
+	Operation    string    `json:"operation"` // operator or function
+	Operands     []string  `json:"operands"`
+	Value        string    `json:"value"` // intermediate result
+	IdAgent      int       `json:"agentId"`
+	Calculator   int       `json:"calculator"` // which calculator of agent evaluated operation
+	DurationMs   int64     `json:"durationMs"` // how long calculator evaluated operation
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// ExpressionStep is one operation, which agent performed evaluating expression. Trace of expression is its steps
+type ExpressionStep struct {
+	ID         int       `json:"-" db:"id"`
+	IdTask     int       `json:"taskId" db:"id_task"`
+	Step       int       `json:"step" db:"step"` // index of operation in task
+	Operation  string    `json:"operation" db:"operation"`
+	Operands   []string  `json:"operands" db:"operands"`
+	Result     string    `json:"result" db:"result"`
+	IdAgent    int       `json:"agentId" db:"id_agent"`
+	Calculator int       `json:"calculator" db:"calculator"`
+	DurationMs int64     `json:"durationMs" db:"duration_ms"`
+	FinishedAt time.Time `json:"finishedAt" db:"finished_at"` // when orchestrator got the step
+}
+
+// ExpressionUpdate is what watchers of expression get: either its transition or progress
+type ExpressionUpdate struct {
+	IdExpression string
+	Event        *ExpressionEvent
+	Progress     *ExpressionProgress
+}
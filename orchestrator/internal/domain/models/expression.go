@@ -1,40 +1,82 @@
 package models
 
-import (
-	"time"
-
-	shuntingYard "github.com/a-romash/go-shunting-yard"
-)
+import "time"
 
 type Expression struct {
-	InfinixExpression string                   `json:"infinix" db:"expression"`
-	PostfixExpression []*shuntingYard.RPNToken `json:"postfix" db:"-"`
-	Result            float64                  `json:"result" db:"result"`
-	UserId            int                      `json:"uid" db:"uid"`
-	CreatedAt         time.Time                `json:"createdAt" db:"created"`
-	SolvedAt          time.Time                `json:"solvedAt" db:"solved_at"`
-	Status            Status                   `json:"status" db:"status"`
-	IdExpression      string                   `json:"id" db:"id"`
+	InfinixExpression string             `json:"infinix" db:"expression"`
+	PostfixExpression []*Token           `json:"-" db:"-"`                                // isn't stored, tasks are built from it
+	Variables         map[string]float64 `json:"variables,omitempty" db:"variables"`      // values of named variables used in expression
+	Result            *float64           `json:"result" db:"result"`                      // nil until expression is solved
+	ExactResult       *string            `json:"exactResult,omitempty" db:"exact_result"` // result without rounding, only for PrecisionExact
+	Precision         Precision          `json:"precision" db:"precision"`
+	Priority          int                `json:"priority" db:"priority"`           // tasks of expressions with higher priority are given to agents first
+	Timeout           time.Duration      `json:"-" db:"-"`                         // how long expression may be evaluated, 0 is no limit
+	Deadline          *time.Time         `json:"deadline,omitempty" db:"deadline"` // when expression times out, set by storage from Timeout
+	UserId            int                `json:"uid" db:"uid"`
+	CreatedAt         time.Time          `json:"createdAt" db:"created"`
+	SolvedAt          *time.Time         `json:"solvedAt" db:"solved_at"`
+	Status            Status             `json:"status" db:"status"`
+	IdAgent           *int               `json:"agentId,omitempty" db:"id_agent"` // agent which got the last task of expression
+	IdExpression      string             `json:"id" db:"id"`
+	Requeues          int                `json:"requeues" db:"requeues"`                  // how many times tasks of expression were taken back from dead agents
+	Error             *ExpressionError   `json:"error,omitempty" db:"error"`              // why expression failed, nil for others
+	CacheKey          *string            `json:"-" db:"cache_key"`                        // hash of normalized expression, the same for expressions with the same result
+	IdOrigin          *string            `json:"-" db:"id_origin"`                        // identical expression, which is evaluated instead of this one
+	CallbackUrl       *string            `json:"callbackUrl,omitempty" db:"callback_url"` // webhook is sent there, when expression is finished
+	Tasks             []*Task            `json:"-" db:"-"`                                // operations of expression, see taskgraph.Build
 }
 
-func Create(infinixExpression string, parsedExpression []*shuntingYard.RPNToken, id string) Expression {
+// Create makes expression which isn't saved yet. Its id is generated by storage
+func Create(infinixExpression string, parsedExpression []*Token) Expression {
 	return Expression{
 		InfinixExpression: infinixExpression,
 		PostfixExpression: parsedExpression,
-		IdExpression:      id,
-		Status:            Solving,
+		Status:            Pending,
+		Precision:         PrecisionFloat,
+		Priority:          DefaultPriority,
 	}
 }
 
-type Status string
+// Range of priority of expression
+const (
+	MinPriority     = -10
+	DefaultPriority = 0
+	MaxPriority     = 10
+)
+
+// CachedResult is result of completed expression, which is given to identical expressions at once
+type CachedResult struct {
+	Result      float64 `db:"result"`
+	ExactResult *string `db:"exact_result"`
+}
+
+// ExpressionError is reason why expression failed
+type ExpressionError struct {
+	Code    string `json:"code"` // e.g. division_by_zero or ErrorRequeueLimit
+	Message string `json:"message"`
+}
+
+const (
+	// Code of error of expression, which tasks were taken back from dead agents too many times
+	ErrorRequeueLimit = "requeue_limit"
+	// Code of error, which agent sends when it can't evaluate task before deadline. Expression is timed out then
+	ErrorDeadlineExceeded = "deadline_exceeded"
+)
+
+// MaxTimeout is the longest timeout of expression
+const MaxTimeout = 24 * time.Hour
+
+// Precision is how expression is evaluated
+type Precision string
 
 const (
-	Solved  Status = "completed"
-	Solving Status = "solving"
-	Invalid Status = "invalid"
+	PrecisionFloat Precision = "float" // float64, the default one
+	PrecisionExact Precision = "exact" // exact rationals, e.g. 0.1+0.2 is exactly 0.3 and 1/3 is "1/3"
 )
 
+// Types of tokens
 const (
 	Operation int = 2
 	Operand   int = 1
+	Function  int = 3
 )
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// IdempotencyKey is key sent by client in Idempotency-Key header.
+// Repeated submission with the same key returns expression created by the first one
+type IdempotencyKey struct {
+	Key         string
+	RequestHash string        // hash of submitted expression, reusing key for another expression is conflict
+	TTL         time.Duration // after it key is forgotten and can be used again
+}
@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// OperationDuration is arithmetic operator and how long agents evaluate it
+type OperationDuration struct {
+	Operation string        `json:"operation" db:"operation"`
+	Duration  time.Duration `json:"duration" db:"-"`
+}
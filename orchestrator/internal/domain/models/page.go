@@ -0,0 +1,65 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Size of page of expressions
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// SortField is time, which expressions are sorted by
+type SortField string
+
+const (
+	SortByCreated SortField = "created"
+	SortBySolved  SortField = "solved" // only solved expressions are listed then
+)
+
+// ExpressionFilter selects page of expressions of user
+type ExpressionFilter struct {
+	Statuses    []Status   // any status, if empty
+	CreatedFrom *time.Time // inclusive
+	CreatedTo   *time.Time // exclusive
+	SolvedFrom  *time.Time // inclusive
+	SolvedTo    *time.Time // exclusive
+	Contains    string     // substring of expression, case insensitive
+	SortBy      SortField
+	Ascending   bool // the newest first by default
+	Limit       int
+	Cursor      *Cursor // page starts after it, the first page if nil
+}
+
+// Cursor is position of the last expression of page, the next page starts after it
+type Cursor struct {
+	SortBy    SortField `json:"s"`
+	Ascending bool      `json:"a,omitempty"`
+	Time      time.Time `json:"t"` // time of expression, which is sorted by
+	Id        string    `json:"id"`
+}
+
+var ErrInvalidCursor = errors.New("cursor is invalid")
+
+// String encodes cursor, client sends it back as is
+func (c Cursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseCursor decodes cursor made by Cursor.String
+func ParseCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err = json.Unmarshal(data, &c); err != nil || c.Id == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
@@ -0,0 +1,7 @@
+package models
+
+// Queue is ready tasks of one user with one priority. Agents get tasks from queues by turns, see orch.Orchestrator.GetTask
+type Queue struct {
+	Priority int `db:"priority"`
+	Uid      int `db:"uid"`
+}
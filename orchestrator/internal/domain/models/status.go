@@ -0,0 +1,60 @@
+package models
+
+// Status of expression. It's changed only by transitions allowed by CanBecome:
+//
+//	pending -> scheduled -> running -> completed
+//
+// Expression goes back to pending, when its tasks are taken back from dead agent,
+// and any expression which isn't finished can be failed, cancelled or timed out
+type Status string
+
+const (
+	Pending   Status = "pending"   // waits for agent
+	Scheduled Status = "scheduled" // task of expression was given to agent
+	Running   Status = "running"   // agent started evaluating
+	Completed Status = "completed"
+	Failed    Status = "failed"
+	Cancelled Status = "cancelled"
+	TimedOut  Status = "timed_out" // wasn't completed before deadline
+)
+
+var transitions = map[Status][]Status{
+	Pending:   {Scheduled, Failed, Cancelled, TimedOut},
+	Scheduled: {Running, Completed, Pending, Failed, Cancelled, TimedOut}, // agent may send result without acknowledging task
+	Running:   {Completed, Pending, Failed, Cancelled, TimedOut},
+}
+
+// CanBecome reports whether expression with status s can get status next
+func (s Status) CanBecome(next Status) bool {
+	for _, status := range transitions[s] {
+		if status == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsKnown reports whether s is one of statuses above
+func (s Status) IsKnown() bool {
+	switch s {
+	case Pending, Scheduled, Running, Completed, Failed, Cancelled, TimedOut:
+		return true
+	}
+	return false
+}
+
+// IsFinal reports whether status can't be changed anymore
+func (s Status) IsFinal() bool {
+	return len(transitions[s]) == 0
+}
+
+// StatusesBefore returns statuses from which expression can get status next
+func StatusesBefore(next Status) []Status {
+	var statuses []Status
+	for status := range transitions {
+		if status.CanBecome(next) {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Task is one operation of expression which can be evaluated by agent independently of others.
+// Operands of task are either numbers from expression or results of other tasks
+type Task struct {
+	ID           int           `db:"id"`
+	IdExpression string        `db:"id_expression"`
+	Operation    string        `db:"operation"`
+	Args         []*string     `db:"args"` // nil means that operand is result of task, which isn't solved yet
+	IdAgent      int           `db:"id_agent"`
+	Status       TaskStatus    `db:"status"`
+	Duration     time.Duration `db:"-"` // how long agent should evaluate operation, see OperationDuration
+	Exact        bool          `db:"-"` // operands are exact rationals, see PrecisionExact
+	Deadline     *time.Time    `db:"-"` // deadline of expression, nil if there is no one
+
+	// Parent is task which takes result of this task as operand with index ParentSlot.
+	// Parent of root task (the last operation of expression) is nil
+	Parent     *Task `db:"-"`
+	ParentSlot int   `db:"-"`
+}
+
+type TaskStatus string
+
+const (
+	TaskNew     TaskStatus = "new"
+	TaskSolving TaskStatus = "solving"
+	TaskSolved  TaskStatus = "solved"
+	TaskFailed  TaskStatus = "failed"
+	// Task of cancelled expression. Agent which was evaluating it is kept until it's told to abort the task
+	TaskCancelled TaskStatus = "cancelled"
+)
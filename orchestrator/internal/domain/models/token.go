@@ -0,0 +1,21 @@
+package models
+
+// Token is element of expression in postfix notation: operand, operator or function
+type Token struct {
+	Type  int    `json:"type"`  // Operand, Operation or Function
+	Value string `json:"value"` // value of operand: float or exact rational, see Precision
+	Name  string `json:"name"`  // name of operator or function, e.g. "+" or "max"
+	Arity int    `json:"arity"` // how many operands operator or function takes
+}
+
+func NewOperandToken(value string) *Token {
+	return &Token{Type: Operand, Value: value}
+}
+
+func NewOperationToken(name string, arity int) *Token {
+	return &Token{Type: Operation, Name: name, Arity: arity}
+}
+
+func NewFunctionToken(name string, arity int) *Token {
+	return &Token{Type: Function, Name: name, Arity: arity}
+}
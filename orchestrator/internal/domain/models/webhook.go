@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// MaxCallbackUrlLength limits callback_url of expression
+const MaxCallbackUrlLength = 2048
+
+// Webhook is callback about finished expression, which is sent to callback url of expression
+type Webhook struct {
+	ID       int            // id of delivery, it's the same for all attempts
+	Url      string         // callback url of expression
+	Attempts int            // how many attempts already failed
+	Secret   string         // secret of user, payload is signed with it
+	Payload  WebhookPayload // body of request
+}
+
+// WebhookPayload is what user gets about expression, when it reaches final status
+type WebhookPayload struct {
+	ID          string           `json:"id"`
+	Status      Status           `json:"status"`
+	Result      *float64         `json:"result"`
+	ExactResult *string          `json:"exactResult,omitempty"`
+	Error       *ExpressionError `json:"error,omitempty"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	SolvedAt    *time.Time       `json:"solvedAt"`
+	FinishedAt  time.Time        `json:"finishedAt"` // when expression got final status, solvedAt is nil for cancelled ones
+}
@@ -2,10 +2,11 @@ package orchgrpc
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
 
-	shuntingYard "github.com/a-romash/go-shunting-yard"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+	expressionparser "github.com/a-romash/grpc-calculator/orchestrator/internal/lib/expressionParser"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/number"
 	"github.com/a-romash/protos/gen/go/orchestrator"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -24,16 +25,32 @@ type Orchestrator interface {
 		is_alive bool,
 		id_agent int,
 	) error
-	GetExpressionToEvaluate(
+	GetTask(
 		ctx context.Context,
 		id_agent int,
-	) (string, []*shuntingYard.RPNToken, error)
-	SaveResultOfExpression(
+	) (*models.Task, error)
+	SaveResultOfTask(
 		ctx context.Context,
-		id_expression string,
-		result float32,
+		idTask int,
+		result string,
 		idAgent int,
 	) error
+	StartTask(
+		ctx context.Context,
+		idTask int,
+		idAgent int,
+	) error
+	SaveErrorOfTask(
+		ctx context.Context,
+		idTask int,
+		code string,
+		message string,
+		idAgent int,
+	) error
+	SaveProgressOfTask(
+		ctx context.Context,
+		progress models.ExpressionProgress,
+	) error
 	RegisterNewAgent(
 		ctx context.Context,
 	) (int, error)
@@ -41,6 +58,16 @@ type Orchestrator interface {
 		ctx context.Context,
 		idAgent int,
 	) error
+	ReleaseAgentTasks(
+		ctx context.Context,
+		idAgent int,
+	) error
+	TakeCancelledTasks(
+		ctx context.Context,
+		idAgent int,
+	) ([]int, error)
+	TasksReady() <-chan struct{}
+	Cancelled() <-chan struct{}
 }
 
 func Register(gRPCServer *grpc.Server, orch Orchestrator) {
@@ -62,22 +89,17 @@ func (s *serverAPI) Heartbeat(
 	return &emptypb.Empty{}, nil
 }
 
-// Converting shuntingYard.RPNToken -> orchestrator.RPNToken (proto)
-func tokensToPrototokens(tokens []*shuntingYard.RPNToken) []*orchestrator.RPNToken {
+// Converting models.Token -> orchestrator.RPNToken (proto)
+func tokensToPrototokens(tokens []*models.Token) []*orchestrator.RPNToken {
 	var prototokens []*orchestrator.RPNToken
 	for _, el := range tokens {
-		var token *orchestrator.RPNToken
-		switch el.Value.(type) {
-		case string:
-			token = &orchestrator.RPNToken{
-				Type:  int32(el.Type),
-				Value: el.Value.(string),
-			}
-		case float64:
-			token = &orchestrator.RPNToken{
-				Type:  int32(el.Type),
-				Value: fmt.Sprintf("%.6f", el.Value.(float64)),
-			}
+		token := &orchestrator.RPNToken{
+			Type:  int32(el.Type),
+			Value: el.Name,
+			Arity: int32(el.Arity),
+		}
+		if el.Type == models.Operand {
+			token.Value = el.Value
 		}
 		prototokens = append(prototokens, token)
 	}
@@ -85,35 +107,87 @@ func tokensToPrototokens(tokens []*shuntingYard.RPNToken) []*orchestrator.RPNTok
 	return prototokens
 }
 
-func (s *serverAPI) GetExpressionToEvaluate(
+// Converting models.Task -> orchestrator.Task (proto): operands and operation in postfix notation
+func taskToProtoTask(task *models.Task) *orchestrator.Task {
+	tokens := make([]*models.Token, 0, len(task.Args)+1)
+	for _, arg := range task.Args {
+		tokens = append(tokens, models.NewOperandToken(*arg))
+	}
+	if expressionparser.IsFunction(task.Operation) {
+		tokens = append(tokens, models.NewFunctionToken(task.Operation, len(task.Args)))
+	} else {
+		tokens = append(tokens, models.NewOperationToken(task.Operation, len(task.Args)))
+	}
+
+	protoTask := &orchestrator.Task{
+		IdTask:            int32(task.ID),
+		IdExpression:      task.IdExpression,
+		PostfixExpression: tokensToPrototokens(tokens),
+		Duration:          task.Duration.Milliseconds(),
+		Exact:             task.Exact,
+	}
+	if task.Deadline != nil {
+		protoTask.Deadline = task.Deadline.UnixMilli()
+	}
+	return protoTask
+}
+
+// resultValue returns result of task as string. Agents which don't fill value send only float result
+func resultValue(result *orchestrator.ResultOfTask) string {
+	if result.Value != "" {
+		return result.Value
+	}
+	return number.FormatFloat(result.Result)
+}
+
+func (s *serverAPI) GetTask(
 	ctx context.Context,
 	in *orchestrator.IdAgent,
-) (*orchestrator.Expression, error) {
+) (*orchestrator.Task, error) {
 	if in.IdAgent == 0 {
 		return nil, status.Error(codes.InvalidArgument, "id_agent is required")
 	}
 
-	id_expression, tokens, err := s.orch.GetExpressionToEvaluate(ctx, int(in.IdAgent))
+	task, err := s.orch.GetTask(ctx, int(in.IdAgent))
 	if err != nil {
-		fmt.Print(err.Error())
-		return nil, status.Error(codes.Internal, "some problems with getting expressions")
+		return nil, status.Error(codes.Internal, "some problems with getting task")
+	}
+	if task == nil {
+		// there is nothing to evaluate now
+		return &orchestrator.Task{}, nil
 	}
 
-	return &orchestrator.Expression{
-		IdExpression:      id_expression,
-		PostfixExpression: tokensToPrototokens(tokens),
-	}, nil
+	return taskToProtoTask(task), nil
 }
 
-func (s *serverAPI) GiveResultOfExpression(
+func (s *serverAPI) GiveResultOfTask(
 	ctx context.Context,
-	in *orchestrator.ResultOfExpression,
+	in *orchestrator.ResultOfTask,
 ) (*emptypb.Empty, error) {
-	if in.GetIdExpression() == "" {
-		return nil, status.Error(codes.InvalidArgument, "id_expression is required")
+	if in.GetIdTask() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id_task is required")
+	}
+
+	err := s.orch.SaveResultOfTask(ctx, int(in.IdTask), resultValue(in), int(in.IdAgent))
+	if err != nil {
+		return nil, status.Error(codes.Internal, "some problem with saving")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *serverAPI) GiveErrorOfTask(
+	ctx context.Context,
+	in *orchestrator.ErrorOfTask,
+) (*emptypb.Empty, error) {
+	if in.GetIdTask() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id_task is required")
+	}
+	if in.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
 	}
 
-	err := s.orch.SaveResultOfExpression(ctx, in.IdExpression, in.Result, int(in.IdAgent))
+	err := s.orch.SaveErrorOfTask(ctx, int(in.IdTask), in.Code, in.Message, int(in.IdAgent))
 	if err != nil {
 		return nil, status.Error(codes.Internal, "some problem with saving")
 	}
@@ -125,7 +199,6 @@ func (s *serverAPI) RegisterNewAgent(
 	ctx context.Context,
 	in *emptypb.Empty,
 ) (*orchestrator.IdAgent, error) {
-	fmt.Println("it works")
 	id, err := s.orch.RegisterNewAgent(ctx)
 
 	return &orchestrator.IdAgent{
@@ -0,0 +1,238 @@
+package orchgrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+	"github.com/a-romash/protos/gen/go/orchestrator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// ackTimeout is how long agent has to acknowledge received task before stream is closed
+	ackTimeout = 10 * time.Second
+	// pollInterval is how often ready tasks are looked for without notification,
+	// e.g. when they were saved by another instance of orchestrator
+	pollInterval = 5 * time.Second
+)
+
+// agentConn is state of stream with one agent
+type agentConn struct {
+	idAgent int
+	slots   int               // how many tasks agent can take right now
+	unacked map[int]time.Time // tasks which agent hasn't acknowledged yet and when they were sent
+	running map[int]struct{}  // tasks which occupy calculators of agent
+}
+
+// Connect pushes ready tasks to agent while it has free calculators.
+// Unfinished tasks of agent are returned to queue when stream is closed
+func (s *serverAPI) Connect(stream orchestrator.Orchestrator_ConnectServer) error {
+	ctx := stream.Context()
+
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if first.IdAgent == 0 {
+		return status.Error(codes.InvalidArgument, "id_agent is required")
+	}
+
+	conn := &agentConn{
+		idAgent: int(first.IdAgent),
+		unacked: make(map[int]time.Time),
+		running: make(map[int]struct{}),
+	}
+
+	defer func() {
+		if err := s.orch.ReleaseAgentTasks(context.Background(), conn.idAgent); err != nil {
+			slog.Error(err.Error())
+		}
+	}()
+
+	if err = s.handleAgentMessage(ctx, conn, first); err != nil {
+		return err
+	}
+
+	messages := make(chan *orchestrator.AgentMessage)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case messages <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// channels are taken before looking for tasks, so tasks saved or cancelled meanwhile aren't missed
+		tasksReady := s.orch.TasksReady()
+		cancelled := s.orch.Cancelled()
+
+		if err = s.pushAborts(ctx, stream, conn); err != nil {
+			return err
+		}
+		if err = s.pushTasks(ctx, stream, conn); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case err = <-recvErr:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case msg := <-messages:
+			if err = s.handleAgentMessage(ctx, conn, msg); err != nil {
+				return err
+			}
+		case <-tasksReady:
+		case <-cancelled:
+		case <-ticker.C:
+			for idTask, sentAt := range conn.unacked {
+				if time.Since(sentAt) > ackTimeout {
+					slog.Warn("agent didn't acknowledge task", slog.Int("id_agent", conn.idAgent), slog.Int("id_task", idTask))
+					return status.Error(codes.DeadlineExceeded, "task wasn't acknowledged")
+				}
+			}
+		}
+	}
+}
+
+// pushTasks sends ready tasks to agent until it has free calculators
+func (s *serverAPI) pushTasks(
+	ctx context.Context,
+	stream orchestrator.Orchestrator_ConnectServer,
+	conn *agentConn,
+) error {
+	for conn.slots > 0 {
+		task, err := s.orch.GetTask(ctx, conn.idAgent)
+		if err != nil {
+			return status.Error(codes.Internal, "some problems with getting task")
+		}
+		if task == nil {
+			return nil
+		}
+
+		err = stream.Send(&orchestrator.OrchestratorMessage{
+			Message: &orchestrator.OrchestratorMessage_Task{Task: taskToProtoTask(task)},
+		})
+		if err != nil {
+			return err
+		}
+
+		conn.slots--
+		conn.unacked[task.ID] = time.Now()
+		conn.running[task.ID] = struct{}{}
+	}
+	return nil
+}
+
+// pushAborts tells agent to stop evaluating tasks of cancelled expressions. Their calculators become free
+func (s *serverAPI) pushAborts(
+	ctx context.Context,
+	stream orchestrator.Orchestrator_ConnectServer,
+	conn *agentConn,
+) error {
+	cancelled, err := s.orch.TakeCancelledTasks(ctx, conn.idAgent)
+	if err != nil {
+		return status.Error(codes.Internal, "some problems with cancelling tasks")
+	}
+
+	for _, idTask := range cancelled {
+		if _, ok := conn.running[idTask]; !ok {
+			// agent has already finished task or it was given in previous stream
+			continue
+		}
+
+		err = stream.Send(&orchestrator.OrchestratorMessage{
+			Message: &orchestrator.OrchestratorMessage_Abort{Abort: int32(idTask)},
+		})
+		if err != nil {
+			return err
+		}
+
+		conn.release(idTask)
+	}
+	return nil
+}
+
+// release frees calculator which evaluated task. Calculator is freed once,
+// e.g. result of task, which agent sent before it got abort, doesn't free it again
+func (conn *agentConn) release(idTask int) {
+	delete(conn.unacked, idTask)
+	if _, ok := conn.running[idTask]; !ok {
+		return
+	}
+	delete(conn.running, idTask)
+	conn.slots++
+}
+
+func (s *serverAPI) handleAgentMessage(
+	ctx context.Context,
+	conn *agentConn,
+	msg *orchestrator.AgentMessage,
+) error {
+	switch m := msg.Message.(type) {
+	case *orchestrator.AgentMessage_FreeSlots:
+		conn.slots += int(m.FreeSlots)
+	case *orchestrator.AgentMessage_Ack:
+		delete(conn.unacked, int(m.Ack))
+		if err := s.orch.StartTask(ctx, int(m.Ack), conn.idAgent); err != nil {
+			return status.Error(codes.Internal, "some problem with starting task")
+		}
+	case *orchestrator.AgentMessage_Result:
+		if m.Result.GetIdTask() == 0 {
+			return status.Error(codes.InvalidArgument, "id_task is required")
+		}
+		conn.release(int(m.Result.IdTask))
+
+		err := s.orch.SaveResultOfTask(ctx, int(m.Result.IdTask), resultValue(m.Result), conn.idAgent)
+		if err != nil {
+			return status.Error(codes.Internal, "some problem with saving")
+		}
+	case *orchestrator.AgentMessage_Error:
+		if m.Error.GetIdTask() == 0 {
+			return status.Error(codes.InvalidArgument, "id_task is required")
+		}
+		conn.release(int(m.Error.IdTask))
+
+		err := s.orch.SaveErrorOfTask(ctx, int(m.Error.IdTask), m.Error.Code, m.Error.Message, conn.idAgent)
+		if err != nil {
+			return status.Error(codes.Internal, "some problem with saving")
+		}
+	case *orchestrator.AgentMessage_Progress:
+		// progress only informs watchers, so stream isn't closed, if it's lost
+		err := s.orch.SaveProgressOfTask(ctx, models.ExpressionProgress{
+			IdTask:     int(m.Progress.IdTask),
+			Step:       int(m.Progress.Step),
+			Operation:  m.Progress.Operation,
+			Operands:   m.Progress.Operands,
+			Value:      m.Progress.Value,
+			IdAgent:    conn.idAgent,
+			Calculator: int(m.Progress.Calculator),
+			DurationMs: m.Progress.Duration,
+			CreatedAt:  time.Now(),
+		})
+		if err != nil {
+			slog.Error(err.Error())
+		}
+	}
+	return nil
+}
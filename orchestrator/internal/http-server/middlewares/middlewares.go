@@ -4,67 +4,245 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	model "github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/api/response"
 	expressionparser "github.com/a-romash/grpc-calculator/orchestrator/internal/lib/expressionParser"
 	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/jwt"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/safeurl"
 )
 
 type myRequest struct {
-	Expression string `json:"expression"`
-	Id         string `json:"-"`
+	Expression  string             `json:"expression"`
+	Variables   map[string]float64 `json:"variables"`    // значения переменных из выражения, например {"x": 2}
+	Precision   model.Precision    `json:"precision"`    // "float" (по умолчанию) или "exact"
+	Priority    *int               `json:"priority"`     // от -10 до 10, по умолчанию 0
+	Timeout     string             `json:"timeout"`      // сколько выражение может считаться, например "30s" (по умолчанию без ограничения)
+	CallbackUrl string             `json:"callback_url"` // куда отправить вебхук, когда выражение закончится
+	Id          string             `json:"-"`
 }
 
+// Функция, которой мидлвари пишут ошибку: http.Error для текстовых хэндлеров и response.Error для API
+type errorWriter func(w http.ResponseWriter, message string, status int)
+
+// Функция, которой пишется ошибка парсинга выражения
+type parseErrorWriter func(w http.ResponseWriter, err *expressionparser.ParseError)
+
 // Проверяем на валидность наше выражение и передаём уже паршенное (парсенное?) в Handler
-func ValidateExpressionMiddleware(next http.Handler) http.Handler {
+func ValidateExpressionMiddleware(next http.Handler, guard *safeurl.Guard) http.Handler {
+	return validateExpression(next, guard, http.Error, writeParseErrorText)
+}
+
+// То же самое, но ошибки отдаются в JSON для /api/v1
+func ValidateExpressionMiddlewareAPI(next http.Handler, guard *safeurl.Guard) http.Handler {
+	return validateExpression(next, guard, response.Error, writeParseErrorJSON)
+}
+
+// Текстом отдаём выражение с указателем ^ на ошибку, чтобы её было видно в консоли
+func writeParseErrorText(w http.ResponseWriter, err *expressionparser.ParseError) {
+	http.Error(w, err.Caret(), http.StatusBadRequest)
+}
+
+// В API вид ошибки, позиция и токен лежат в details
+func writeParseErrorJSON(w http.ResponseWriter, err *expressionparser.ParseError) {
+	response.ErrorWithDetails(w, err.Error(), http.StatusBadRequest, err)
+}
+
+func validateExpression(next http.Handler, guard *safeurl.Guard, httpError errorWriter, parseError parseErrorWriter) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Парсим JSON
 		var request myRequest
 		err := json.NewDecoder(r.Body).Decode(&request)
 		if err != nil {
-			http.Error(w, "Error while parsing JSON", http.StatusBadRequest)
+			httpError(w, "Error while parsing JSON", http.StatusBadRequest)
 			return
 		}
 
-		// Парсим полученное выражение
-		postfixExpression, err := expressionparser.ParseExpression(request.Expression)
+		expression, err := newExpression(r.Context(), request, guard)
 		if err != nil {
-			http.Error(w, "Error while parsing expression", http.StatusBadRequest)
+			var perr *expressionparser.ParseError
+			if errors.As(err, &perr) {
+				parseError(w, perr)
+				return
+			}
+			httpError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		expression := model.Create(request.Expression, postfixExpression, expressionparser.CreateImpodenceKey(request.Expression))
-
 		// Передаём в реквест контекст с выражением для дальнейшей работы с ним в хэндлере
 		rWithContext := r.WithContext(context.WithValue(r.Context(), "expression", expression))
-		// Пишем в хэдер статус код, что всё хорошо
-		w.WriteHeader(http.StatusAccepted)
+		next.ServeHTTP(w, rWithContext)
+	})
+}
+
+// Выражение хранится в VARCHAR(255), более длинное не сохранится
+const maxExpressionLength = 255
+
+// newExpression проверяет поля запроса и парсит выражение.
+// Если выражение не парсится, возвращается *expressionparser.ParseError
+func newExpression(ctx context.Context, request myRequest, guard *safeurl.Guard) (model.Expression, error) {
+	if utf8.RuneCountInString(request.Expression) > maxExpressionLength {
+		return model.Expression{}, fmt.Errorf("expression is longer than %d characters", maxExpressionLength)
+	}
+	if request.Precision == "" {
+		request.Precision = model.PrecisionFloat
+	}
+	if request.Precision != model.PrecisionFloat && request.Precision != model.PrecisionExact {
+		return model.Expression{}, errors.New("precision should be float or exact")
+	}
+
+	priority := model.DefaultPriority
+	if request.Priority != nil {
+		priority = *request.Priority
+	}
+	if priority < model.MinPriority || priority > model.MaxPriority {
+		return model.Expression{}, fmt.Errorf("priority should be from %d to %d", model.MinPriority, model.MaxPriority)
+	}
+
+	var timeout time.Duration
+	if request.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(request.Timeout)
+		if err != nil || timeout <= 0 || timeout > model.MaxTimeout {
+			return model.Expression{}, fmt.Errorf("timeout should be duration like 30s, not longer than %s", model.MaxTimeout)
+		}
+	}
+
+	var callbackUrl *string
+	if request.CallbackUrl != "" {
+		if err := validateCallbackUrl(ctx, request.CallbackUrl, guard); err != nil {
+			return model.Expression{}, err
+		}
+		callbackUrl = &request.CallbackUrl
+	}
+
+	// Парсим полученное выражение
+	postfixExpression, err := expressionparser.ParseExpression(request.Expression, request.Variables, request.Precision)
+	if err != nil {
+		var perr *expressionparser.ParseError
+		if errors.As(err, &perr) {
+			return model.Expression{}, perr
+		}
+		return model.Expression{}, errors.New("Error while parsing expression")
+	}
+
+	expression := model.Create(request.Expression, postfixExpression)
+	expression.Variables = request.Variables
+	expression.Precision = request.Precision
+	expression.Priority = priority
+	expression.Timeout = timeout
+	expression.CallbackUrl = callbackUrl
+	return expression, nil
+}
+
+// Вебхук отправляется только на абсолютный http(s) адрес в интернете или в сетях из allowed_networks
+func validateCallbackUrl(ctx context.Context, callbackUrl string, guard *safeurl.Guard) error {
+	if len(callbackUrl) > model.MaxCallbackUrlLength {
+		return fmt.Errorf("callback_url is longer than %d characters", model.MaxCallbackUrlLength)
+	}
+	u, err := url.Parse(callbackUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("callback_url should be absolute http or https url")
+	}
+	// Адреса проверяются ещё раз при отправке, а здесь пользователь сразу узнаёт об ошибке
+	if err := guard.CheckHost(ctx, u.Hostname()); err != nil {
+		if errors.Is(err, safeurl.ErrNotPublic) {
+			return errors.New("callback_url should point to public address")
+		}
+		return errors.New("host of callback_url can't be resolved")
+	}
+	return nil
+}
+
+// Сколько выражений можно отправить одним запросом
+const MaxBatchSize = 1000
+
+type batchRequest struct {
+	Expressions []myRequest `json:"expressions"`
+}
+
+// BatchItem - одно выражение из пачки: либо выражение, либо ошибка, почему оно невалидно
+type BatchItem struct {
+	Expression model.Expression
+	Err        *response.ErrorBody
+}
+
+// ValidateBatchMiddlewareAPI проверяет каждое выражение пачки отдельно и передаёт в Handler
+// все выражения с их ошибками. Целиком запрос отклоняется, только если он сам невалиден
+func ValidateBatchMiddlewareAPI(next http.Handler, guard *safeurl.Guard) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request batchRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			response.Error(w, "Error while parsing JSON", http.StatusBadRequest)
+			return
+		}
+		if len(request.Expressions) == 0 {
+			response.Error(w, "expressions are required", http.StatusBadRequest)
+			return
+		}
+		if len(request.Expressions) > MaxBatchSize {
+			response.Error(w, fmt.Sprintf("batch can't have more than %d expressions", MaxBatchSize), http.StatusBadRequest)
+			return
+		}
+
+		items := make([]BatchItem, len(request.Expressions))
+		for i, item := range request.Expressions {
+			expression, err := newExpression(r.Context(), item, guard)
+			if err != nil {
+				// как и в writeParseErrorJSON, вид ошибки и позиция лежат в details
+				var details any
+				var perr *expressionparser.ParseError
+				if errors.As(err, &perr) {
+					details = perr
+				}
+				body := response.NewErrorBody(err.Error(), http.StatusBadRequest, details)
+				items[i].Err = &body
+				continue
+			}
+			items[i].Expression = expression
+		}
+
+		rWithContext := r.WithContext(context.WithValue(r.Context(), "batch", items))
 		next.ServeHTTP(w, rWithContext)
 	})
 }
 
 // Проверка и валидация токена
 func ValidateToken(next http.Handler, secret string) http.Handler {
+	return validateToken(next, secret, http.Error)
+}
+
+// То же самое, но ошибки отдаются в JSON для /api/v1
+func ValidateTokenAPI(next http.Handler, secret string) http.Handler {
+	return validateToken(next, secret, response.Error)
+}
+
+func validateToken(next http.Handler, secret string, httpError errorWriter) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		bearer := r.Header.Get("Authorization")
 		token := strings.Split(bearer, " ")
 		if len(token) != 2 {
-			http.Error(w, "token is invalid! "+strings.Join(token, " "), http.StatusUnauthorized)
+			httpError(w, "token is invalid! "+strings.Join(token, " "), http.StatusUnauthorized)
 			return
 		}
 		if token[1] == "" {
-			http.Error(w, "token is required!", http.StatusUnauthorized)
+			httpError(w, "token is required!", http.StatusUnauthorized)
 			return
 		}
 		id, err := jwt.ValidateToken(token[1], secret)
 		if err != nil {
 			if errors.Is(err, jwt.ErrTokenExpired) {
-				http.Error(w, jwt.ErrTokenExpired.Error(), http.StatusUnauthorized)
+				httpError(w, jwt.ErrTokenExpired.Error(), http.StatusUnauthorized)
 				return
 			}
-			http.Error(w, jwt.ErrUnexpectedSigningMethod.Error(), http.StatusInternalServerError)
+			// Токен подписан не нами или испорчен - это ошибка клиента
+			httpError(w, "token is invalid!", http.StatusUnauthorized)
 			return
 		}
 		rWithCtx := r.WithContext(context.WithValue(r.Context(), "uid", id))
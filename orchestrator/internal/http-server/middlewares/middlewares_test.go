@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/api/response"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/safeurl"
+)
+
+func TestBatchRejectsOnlyLongExpression(t *testing.T) {
+	guard, err := safeurl.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var items []BatchItem
+	handler := ValidateBatchMiddlewareAPI(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		items = r.Context().Value("batch").([]BatchItem)
+	}), guard)
+
+	// второе выражение не влезает в колонку, но первое и третье должны сохраниться
+	long := strings.Repeat("1+", maxExpressionLength/2+1) + "1"
+	body := `{"expressions": [{"expression": "1+2"}, {"expression": "` + long + `"}, {"expression": "3*4"}]}`
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/expressions:batch", strings.NewReader(body)))
+
+	if len(items) != 3 {
+		t.Fatalf("got %d items; want 3", len(items))
+	}
+	if items[0].Err != nil || items[2].Err != nil {
+		t.Errorf("valid expressions got errors %+v, %+v", items[0].Err, items[2].Err)
+	}
+	if items[1].Err == nil || items[1].Err.Code != response.CodeBadRequest {
+		t.Errorf("long expression got error %+v; want bad_request", items[1].Err)
+	}
+}
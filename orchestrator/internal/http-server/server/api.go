@@ -0,0 +1,371 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/clients/sso/grpc"
+	model "github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+	middleware "github.com/a-romash/grpc-calculator/orchestrator/internal/http-server/middlewares"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/api/response"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/logger/sl"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/taskgraph"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/storage"
+	"github.com/gorilla/mux"
+)
+
+// Хэндлеры /api/v1: в отличие от хэндлеров для сайта всегда отвечают JSON,
+// ошибки отдаются в виде response.ErrorResponse
+
+type credentialsRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type evaluateResponse struct {
+	ID     string       `json:"id"`
+	Status model.Status `json:"status"`
+}
+
+// Ответ на пачку выражений: для каждого выражения в том же порядке либо id и статус, либо ошибка
+type batchItemResponse struct {
+	ID     string              `json:"id,omitempty"`
+	Status model.Status        `json:"status,omitempty"`
+	Error  *response.ErrorBody `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Items []batchItemResponse `json:"items"`
+}
+
+type batchExpressionResponse struct {
+	ID         string              `json:"id"`
+	Expression *model.Expression   `json:"expression,omitempty"`
+	Error      *response.ErrorBody `json:"error,omitempty"`
+}
+
+type batchExpressionsResponse struct {
+	Items []batchExpressionResponse `json:"items"`
+}
+
+type expressionsResponse struct {
+	Expressions []model.Expression `json:"expressions"`
+	NextCursor  string             `json:"nextCursor,omitempty"` // нет у последней страницы
+}
+
+type eventsResponse struct {
+	Events []model.ExpressionEvent `json:"events"`
+}
+
+type webhookSecretResponse struct {
+	Secret string `json:"secret"`
+}
+
+type traceResponse struct {
+	Steps []model.ExpressionStep `json:"steps"`
+}
+
+type agentsResponse struct {
+	Agents []model.Agent `json:"agents"`
+}
+
+type loginResponse struct {
+	Token string `json:"token"`
+}
+
+type registerResponse struct {
+	ID int `json:"id"`
+}
+
+func (s *Server) ApiEvaluateExpression(w http.ResponseWriter, r *http.Request) {
+	expression := r.Context().Value("expression").(model.Expression)
+
+	key, ok := idempotencyKey(r)
+	if !ok {
+		response.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+		return
+	}
+
+	saved, replayed, err := s.httpService.EvaluateExpression(context.Background(), &expression, r.Context().Value("uid").(int), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, taskgraph.ErrInvalidExpression):
+			response.Error(w, "expression is invalid", http.StatusBadRequest)
+		case errors.Is(err, storage.ErrIdempotencyKeyUsed):
+			response.Error(w, "Idempotency-Key is already used for another expression", http.StatusConflict)
+		default:
+			s.internalError(w, r, err)
+		}
+		return
+	}
+
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+	w.Header().Set("Location", "/api/v1/expressions/"+url.PathEscape(saved.IdExpression))
+	response.JSON(w, http.StatusAccepted, evaluateResponse{ID: saved.IdExpression, Status: saved.Status})
+}
+
+// ApiEvaluateBatch сохраняет все валидные выражения пачки одной транзакцией,
+// ошибки невалидных отдаются на их местах в items
+func (s *Server) ApiEvaluateBatch(w http.ResponseWriter, r *http.Request) {
+	batch := r.Context().Value("batch").([]middleware.BatchItem)
+
+	items := make([]batchItemResponse, len(batch))
+	expressions := make([]*model.Expression, 0, len(batch))
+	indexes := make([]int, 0, len(batch)) // индекс в пачке для каждого выражения из expressions
+	for i := range batch {
+		if batch[i].Err != nil {
+			items[i].Error = batch[i].Err
+			continue
+		}
+		expressions = append(expressions, &batch[i].Expression)
+		indexes = append(indexes, i)
+	}
+
+	errs, err := s.httpService.EvaluateExpressions(context.Background(), expressions, r.Context().Value("uid").(int))
+	if err != nil {
+		s.internalError(w, r, err)
+		return
+	}
+
+	for j, expression := range expressions {
+		i := indexes[j]
+		if errs[j] != nil {
+			body := response.NewErrorBody("expression is invalid", http.StatusBadRequest, nil)
+			items[i].Error = &body
+			continue
+		}
+		items[i].ID = expression.IdExpression
+		items[i].Status = expression.Status
+	}
+	response.JSON(w, http.StatusOK, batchResponse{Items: items})
+}
+
+// ApiGetExpressionsBatch отдаёт выражения по списку id (?id=...&id=...) в том же порядке,
+// для ненайденных - ошибка not_found
+func (s *Server) ApiGetExpressionsBatch(w http.ResponseWriter, r *http.Request) {
+	ids := r.URL.Query()["id"]
+	if len(ids) == 0 {
+		response.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+	if len(ids) > middleware.MaxBatchSize {
+		response.Error(w, fmt.Sprintf("batch can't have more than %d expressions", middleware.MaxBatchSize), http.StatusBadRequest)
+		return
+	}
+
+	found, err := s.httpService.GetExpressionsByIds(context.Background(), ids, r.Context().Value("uid").(int))
+	if err != nil {
+		s.internalError(w, r, err)
+		return
+	}
+
+	items := make([]batchExpressionResponse, len(ids))
+	for i, id := range ids {
+		items[i].ID = id
+		if expression, ok := found[id]; ok {
+			items[i].Expression = expression
+			continue
+		}
+		body := response.NewErrorBody("expression not found", http.StatusNotFound, nil)
+		items[i].Error = &body
+	}
+	response.JSON(w, http.StatusOK, batchExpressionsResponse{Items: items})
+}
+
+func (s *Server) ApiGetExpressionById(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	expression, err := s.httpService.GetExpressionById(context.Background(), id, r.Context().Value("uid").(int))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			response.Error(w, "expression not found", http.StatusNotFound)
+			return
+		}
+		s.internalError(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, expression)
+}
+
+// ApiCancelExpression отменяет выражение пользователя, агенты бросают его задачи
+func (s *Server) ApiCancelExpression(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	expression, err := s.httpService.CancelExpression(context.Background(), id, r.Context().Value("uid").(int))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			response.Error(w, "expression not found", http.StatusNotFound)
+		case errors.Is(err, storage.ErrExpressionFinished):
+			response.Error(w, "expression is already finished", http.StatusConflict)
+		default:
+			s.internalError(w, r, err)
+		}
+		return
+	}
+	response.JSON(w, http.StatusOK, evaluateResponse{ID: expression.IdExpression, Status: expression.Status})
+}
+
+// ApiGetExpressionEvents отдаёт историю статусов выражения,
+// а с Accept: text/event-stream продолжает отдавать переходы и ход вычисления, см. streamExpressionEvents
+func (s *Server) ApiGetExpressionEvents(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		s.streamExpressionEvents(w, r, id)
+		return
+	}
+
+	events, err := s.httpService.GetExpressionEvents(context.Background(), id, r.Context().Value("uid").(int))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			response.Error(w, "expression not found", http.StatusNotFound)
+			return
+		}
+		s.internalError(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, eventsResponse{Events: events})
+}
+
+// ApiGetExpressionTrace отдаёт операции, которые агенты выполнили, чтобы посчитать выражение
+func (s *Server) ApiGetExpressionTrace(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	steps, err := s.httpService.GetExpressionTrace(context.Background(), id, r.Context().Value("uid").(int))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			response.Error(w, "expression not found", http.StatusNotFound)
+			return
+		}
+		s.internalError(w, r, err)
+		return
+	}
+
+	// Пустой список, а не null
+	if steps == nil {
+		steps = []model.ExpressionStep{}
+	}
+	response.JSON(w, http.StatusOK, traceResponse{Steps: steps})
+}
+
+func (s *Server) ApiGetExpressionsForUser(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseExpressionFilter(r)
+	if err != nil {
+		response.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	expressions, next, err := s.httpService.GetExpressionsForUser(context.Background(), r.Context().Value("uid").(int), filter)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		s.internalError(w, r, err)
+		return
+	}
+
+	// Пустой список, а не null
+	if expressions == nil {
+		expressions = []model.Expression{}
+	}
+	resp := expressionsResponse{Expressions: expressions}
+	if next != nil {
+		resp.NextCursor = next.String()
+	}
+	response.JSON(w, http.StatusOK, resp)
+}
+
+// ApiGetWebhookSecret отдаёт секрет, которым подписываются вебхуки пользователя
+func (s *Server) ApiGetWebhookSecret(w http.ResponseWriter, r *http.Request) {
+	secret, err := s.httpService.GetWebhookSecret(context.Background(), r.Context().Value("uid").(int))
+	if err != nil {
+		s.internalError(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, webhookSecretResponse{Secret: secret})
+}
+
+// ApiRotateWebhookSecret заменяет секрет новым, неотправленные вебхуки подписываются уже им
+func (s *Server) ApiRotateWebhookSecret(w http.ResponseWriter, r *http.Request) {
+	secret, err := s.httpService.RotateWebhookSecret(context.Background(), r.Context().Value("uid").(int))
+	if err != nil {
+		s.internalError(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, webhookSecretResponse{Secret: secret})
+}
+
+func (s *Server) ApiGetAgentStates(w http.ResponseWriter, r *http.Request) {
+	agents, err := s.httpService.GetAgentStates(context.Background())
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		s.internalError(w, r, err)
+		return
+	}
+
+	if agents == nil {
+		agents = []model.Agent{}
+	}
+	response.JSON(w, http.StatusOK, agentsResponse{Agents: agents})
+}
+
+func (s *Server) ApiLogin(w http.ResponseWriter, r *http.Request) {
+	request, ok := decodeCredentials(w, r)
+	if !ok {
+		return
+	}
+
+	token, err := s.httpService.Login(context.Background(), request.Email, request.Password)
+	if err != nil {
+		if errors.Is(err, grpc.ErrInvalidCredentials) {
+			response.Error(w, "invalid email or password", http.StatusUnauthorized)
+			return
+		}
+		s.internalError(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, loginResponse{Token: token})
+}
+
+func (s *Server) ApiRegister(w http.ResponseWriter, r *http.Request) {
+	request, ok := decodeCredentials(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := s.httpService.Register(context.Background(), request.Email, request.Password)
+	if err != nil {
+		if errors.Is(err, grpc.ErrUserExists) {
+			response.Error(w, "user with this email already exists", http.StatusConflict)
+			return
+		}
+		s.internalError(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusCreated, registerResponse{ID: id})
+}
+
+// internalError пишет ошибку в лог, а клиенту отдаёт только код internal:
+// в тексте ошибок хранилища есть SQL и внутренние имена
+func (s *Server) internalError(w http.ResponseWriter, r *http.Request, err error) {
+	s.log.Error("failed to handle request", slog.String("path", r.URL.Path), sl.Err(err))
+	response.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+// decodeCredentials parses body of login and register requests. If it's invalid, error is already written
+func decodeCredentials(w http.ResponseWriter, r *http.Request) (credentialsRequest, bool) {
+	var request credentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		response.Error(w, "Error while parsing JSON", http.StatusBadRequest)
+		return request, false
+	}
+	if request.Email == "" || request.Password == "" {
+		response.Error(w, "email and password are required", http.StatusBadRequest)
+		return request, false
+	}
+	return request, true
+}
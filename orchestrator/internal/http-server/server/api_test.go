@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/api/response"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/storage"
+	"github.com/gorilla/mux"
+)
+
+// fakeService отвечает заданными ошибками, остальные методы HttpService не нужны
+type fakeService struct {
+	HttpService
+	err error
+}
+
+func (f *fakeService) GetExpressionById(ctx context.Context, id string, uid int) (*models.Expression, error) {
+	return nil, f.err
+}
+
+func (f *fakeService) CancelExpression(ctx context.Context, id string, uid int) (*models.Expression, error) {
+	return nil, f.err
+}
+
+func TestApiErrorsDontShowInternals(t *testing.T) {
+	internal := fmt.Errorf("storage.postgres.GetExpressionById: %w", errors.New(`ERROR: relation "expressions" does not exist (SQLSTATE 42P01)`))
+
+	tests := []struct {
+		name    string
+		handler func(s *Server) http.HandlerFunc
+		err     error
+		status  int
+		message string
+	}{
+		{"storage error", func(s *Server) http.HandlerFunc { return s.ApiGetExpressionById }, internal, http.StatusInternalServerError, "internal error"},
+		{"not found", func(s *Server) http.HandlerFunc { return s.ApiGetExpressionById }, fmt.Errorf("op: %w", storage.ErrNotFound), http.StatusNotFound, "expression not found"},
+		{"finished", func(s *Server) http.HandlerFunc { return s.ApiCancelExpression },
+			fmt.Errorf("storage.postgres.CancelExpression: %w", storage.ErrExpressionFinished), http.StatusConflict, "expression is already finished"},
+	}
+	for _, tt := range tests {
+		s := &Server{
+			log:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+			httpService: &fakeService{err: tt.err},
+		}
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/expressions/1", nil)
+		r = mux.SetURLVars(r.WithContext(context.WithValue(r.Context(), "uid", 1)), map[string]string{"id": "1"})
+		w := httptest.NewRecorder()
+		tt.handler(s)(w, r)
+
+		var body response.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if w.Code != tt.status || body.Error.Message != tt.message {
+			t.Errorf("%s: %d %q; want %d %q", tt.name, w.Code, body.Error.Message, tt.status, tt.message)
+		}
+		if strings.Contains(body.Error.Message, "storage.") {
+			t.Errorf("%s: message shows internal error %q", tt.name, body.Error.Message)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+)
+
+// Самая длинная подстрока для поиска, выражение длиннее всё равно не сохранить
+const maxSearchLength = 255
+
+// parseExpressionFilter читает из query фильтры, сортировку и курсор страницы выражений:
+// status (можно несколько раз или через запятую), created_from, created_to, solved_from, solved_to (RFC3339),
+// q (подстрока выражения), sort (created или solved), order (asc или desc), limit и cursor
+func parseExpressionFilter(r *http.Request) (model.ExpressionFilter, error) {
+	query := r.URL.Query()
+	filter := model.ExpressionFilter{
+		SortBy: model.SortByCreated,
+		Limit:  model.DefaultPageSize,
+	}
+
+	for _, value := range query["status"] {
+		for _, status := range strings.Split(value, ",") {
+			if !model.Status(status).IsKnown() {
+				return filter, fmt.Errorf("unknown status %q", status)
+			}
+			filter.Statuses = append(filter.Statuses, model.Status(status))
+		}
+	}
+
+	times := []struct {
+		name string
+		dest **time.Time
+	}{
+		{"created_from", &filter.CreatedFrom},
+		{"created_to", &filter.CreatedTo},
+		{"solved_from", &filter.SolvedFrom},
+		{"solved_to", &filter.SolvedTo},
+	}
+	for _, param := range times {
+		value := query.Get(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, fmt.Errorf("%s should be time like 2024-01-02T15:04:05Z", param.name)
+		}
+		*param.dest = &t
+	}
+
+	filter.Contains = query.Get("q")
+	if len(filter.Contains) > maxSearchLength {
+		return filter, fmt.Errorf("q is longer than %d characters", maxSearchLength)
+	}
+
+	switch sort := query.Get("sort"); sort {
+	case "", string(model.SortByCreated):
+	case string(model.SortBySolved):
+		filter.SortBy = model.SortBySolved
+	default:
+		return filter, errors.New("sort should be created or solved")
+	}
+
+	switch order := query.Get("order"); order {
+	case "", "desc":
+	case "asc":
+		filter.Ascending = true
+	default:
+		return filter, errors.New("order should be asc or desc")
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > model.MaxPageSize {
+			return filter, fmt.Errorf("limit should be from 1 to %d", model.MaxPageSize)
+		}
+		filter.Limit = limit
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := model.ParseCursor(value)
+		if err != nil {
+			return filter, err
+		}
+		// курсор указывает место в том порядке, в котором он получен
+		if cursor.SortBy != filter.SortBy || cursor.Ascending != filter.Ascending {
+			return filter, errors.New("cursor was made for another sort or order")
+		}
+		filter.Cursor = cursor
+	}
+	return filter, nil
+}
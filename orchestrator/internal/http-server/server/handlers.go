@@ -2,22 +2,20 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
-	"strings"
+	"net/url"
+	"time"
 
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/clients/sso/grpc"
 	model "github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
-	expressionparser "github.com/a-romash/grpc-calculator/orchestrator/internal/lib/expressionParser"
-	"github.com/jackc/pgx/v5"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/storage"
 )
 
-type myRequest struct {
-	Expression string `json:"expression"`
-	Id         string `json:"-"`
-}
-
 func (s *Server) MainPage(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, "Hello, use curl :)")
 }
@@ -27,8 +25,8 @@ func (s *Server) GetExpressionById(w http.ResponseWriter, r *http.Request) {
 
 	expression, err := s.httpService.GetExpressionById(context.Background(), id, r.Context().Value("uid").(int))
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			w.Write([]byte("expression not found!"))
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "expression not found!", http.StatusNotFound)
 			return
 		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -36,35 +34,69 @@ func (s *Server) GetExpressionById(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Write(
 		[]byte(
-			fmt.Sprintf("expression: %s\nstatus: %s\nresult: %f\ncreated_at: %v\nsolved_at: %v\n\n", expression.InfinixExpression, expression.Status, expression.Result, expression.CreatedAt, expression.SolvedAt),
+			fmt.Sprintf("expression: %s\nstatus: %s\nresult: %s\n%screated_at: %v\nsolved_at: %s\n\n", expression.InfinixExpression, expression.Status, formatResult(expression.Result, expression.ExactResult), formatError(expression.Error), expression.CreatedAt, formatTime(expression.SolvedAt)),
 		),
 	)
+}
+
+// GetExpressionStatus is resource for polling: returns status of expression and its result once it's solved
+func (s *Server) GetExpressionStatus(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
 
-	fmt.Fprintln(w, "Expression doesn't exist")
+	expression, err := s.httpService.GetExpressionById(context.Background(), id, r.Context().Value("uid").(int))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "expression not found!", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if expression.Result == nil {
+		w.Write([]byte(fmt.Sprintf("id: %s\nstatus: %s\n%s", expression.IdExpression, expression.Status, formatError(expression.Error))))
+		return
+	}
+	w.Write([]byte(fmt.Sprintf("id: %s\nstatus: %s\nresult: %s\n", expression.IdExpression, expression.Status, formatResult(expression.Result, expression.ExactResult))))
 }
 
 func (s *Server) EvaluateExpression(w http.ResponseWriter, r *http.Request) {
 	expression := r.Context().Value("expression").(model.Expression)
 
-	// // Проверяем выражение на наличие результата в базе данных (в ином случае отправляем агенту на вычисление)
-	// // if el, ok := cache.Get(expression.IdExpression); ok {
-	// // 	fmt.Fprintln(w, el.Result)
-	// // 	return
-	// // }
-
-	// expression, err := orchestrator.SolveExpression(&expression)
-	// if err != nil {
-	// 	log.Println(err.Error())
-	// }
-	// // записываем в бд
+	key, ok := idempotencyKey(r)
+	if !ok {
+		http.Error(w, "Idempotency-Key is too long!", http.StatusBadRequest)
+		return
+	}
 
-	result, err := s.httpService.EvaluateExpression(context.Background(), &expression, r.Context().Value("uid").(int))
+	// Выражение только сохраняется, считать его будут агенты. Клиент опрашивает статус по полученному id.
+	// Если такое же выражение уже посчитано, результат берётся из кэша и выражение сразу completed
+	saved, replayed, err := s.httpService.EvaluateExpression(context.Background(), &expression, r.Context().Value("uid").(int), key)
 	if err != nil {
+		if errors.Is(err, storage.ErrIdempotencyKeyUsed) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.Write([]byte(fmt.Sprintf("Result is: %v", result)))
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+	w.Header().Set("Location", "/expression/status?id="+url.QueryEscape(saved.IdExpression))
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte(fmt.Sprintf("Expression accepted, id: %s", saved.IdExpression)))
+}
+
+// Максимальная длина ключа, больше не влезет в базу
+const maxIdempotencyKeyLen = 255
+
+// Ключ идемпотентности передаёт клиент, повторный запрос с тем же ключом вернёт то же выражение.
+// Без ключа каждый запрос создаёт новое выражение
+func idempotencyKey(r *http.Request) (string, bool) {
+	key := r.Header.Get("Idempotency-Key")
+	return key, len(key) <= maxIdempotencyKeyLen
 }
 
 func (s *Server) Login(w http.ResponseWriter, r *http.Request) {
@@ -80,6 +112,10 @@ func (s *Server) Login(w http.ResponseWriter, r *http.Request) {
 
 	token, err := s.httpService.Login(context.Background(), request.Email, request.Password)
 	if err != nil {
+		if errors.Is(err, grpc.ErrInvalidCredentials) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -100,6 +136,10 @@ func (s *Server) Register(w http.ResponseWriter, r *http.Request) {
 
 	id, err := s.httpService.Register(context.Background(), request.Email, request.Password)
 	if err != nil {
+		if errors.Is(err, grpc.ErrUserExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -119,9 +159,15 @@ func (s *Server) GetAgentStates(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) GetExpressionsForUser(w http.ResponseWriter, r *http.Request) {
-	expressions, err := s.httpService.GetExpressionsForUser(context.Background(), r.Context().Value("uid").(int))
+	filter, err := parseExpressionFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	expressions, next, err := s.httpService.GetExpressionsForUser(context.Background(), r.Context().Value("uid").(int), filter)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
+		if errors.Is(err, storage.ErrNotFound) {
 			w.Write([]byte("No expressions for you"))
 			return
 		}
@@ -136,26 +182,93 @@ func (s *Server) GetExpressionsForUser(w http.ResponseWriter, r *http.Request) {
 	for _, e := range expressions {
 		w.Write(
 			[]byte(
-				fmt.Sprintf("expression: %s\nstatus: %s\nresult: %f\ncreated_at: %v\nsolved_at: %v\nid: %s\n\n", e.InfinixExpression, e.Status, e.Result, e.CreatedAt, e.SolvedAt, e.IdExpression),
+				fmt.Sprintf("expression: %s\nstatus: %s\nresult: %s\n%screated_at: %v\nsolved_at: %s\nid: %s\n\n", e.InfinixExpression, e.Status, formatResult(e.Result, e.ExactResult), formatError(e.Error), e.CreatedAt, formatTime(e.SolvedAt), e.IdExpression),
 			),
 		)
 	}
+	// Следующая страница запрашивается с ?cursor=...
+	if next != nil {
+		w.Write([]byte(fmt.Sprintf("next_cursor: %s\n", next)))
+	}
 }
 
-// вообще по идее оно должно создаваться на фронтэнде, но т.к пока нет фронта - создаём на бэкенде (по запросу с фронта)
-func (s *Server) GetImpodenceKeyHandler(w http.ResponseWriter, r *http.Request) {
-	// Парсим JSON
-	var request myRequest
+func (s *Server) GetOperations(w http.ResponseWriter, r *http.Request) {
+	operations, err := s.httpService.GetOperations(context.Background())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, o := range operations {
+		w.Write([]byte(fmt.Sprintf("operation: %s\nduration: %v\n\n", o.Operation, o.Duration)))
+	}
+}
+
+// Меняем время выполнения операции, агенты получат его вместе со следующими задачами
+func (s *Server) SetOperationDuration(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Operation string `json:"operation"`
+		Duration  string `json:"duration"` // например "5s" или "500ms"
+	}
 	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		http.Error(w, "Error while parsing JSON", http.StatusBadRequest)
 		return
 	}
 
-	request.Expression = strings.ReplaceAll(request.Expression, " ", "")
+	duration, err := time.ParseDuration(request.Duration)
+	if err != nil || duration < 0 {
+		http.Error(w, "duration is invalid", http.StatusBadRequest)
+		return
+	}
+
+	err = s.httpService.SetOperationDuration(context.Background(), request.Operation, duration)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "operation not found!", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte(fmt.Sprintf("operation: %s\nduration: %v\n", request.Operation, duration)))
+}
+
+// Результата и времени решения нет, пока выражение не посчитано.
+// Для точного режима показываем точный результат (дробь или десятичное число)
+func formatResult(result *float64, exact *string) string {
+	if exact != nil {
+		return *exact
+	}
+	if result == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%f", *result)
+}
 
-	// и получаем ключ
-	key := expressionparser.CreateImpodenceKey(request.Expression)
+// Строка с причиной ошибки, только для упавших выражений
+func formatError(err *model.ExpressionError) string {
+	if err == nil {
+		return ""
+	}
+	return fmt.Sprintf("error: %s (%s)\n", err.Message, err.Code)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.String()
+}
+
+// вообще по идее оно должно создаваться на фронтэнде, но т.к пока нет фронта - создаём на бэкенде (по запросу с фронта).
+// Ключ случайный, его нужно передать в заголовке Idempotency-Key при отправке выражения
+func (s *Server) GetImpodenceKeyHandler(w http.ResponseWriter, r *http.Request) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	w.Write([]byte(key))
+	w.Write([]byte(hex.EncodeToString(key)))
 }
@@ -6,11 +6,15 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"time"
 
 	"github.com/a-romash/grpc-calculator/orchestrator/internal/clients/sso/grpc"
 	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
 	middleware "github.com/a-romash/grpc-calculator/orchestrator/internal/http-server/middlewares"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/notify"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/safeurl"
 	httpservice "github.com/a-romash/grpc-calculator/orchestrator/internal/service/http"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/service/hub"
 	"github.com/gorilla/mux"
 )
 
@@ -28,18 +32,58 @@ type HttpService interface {
 		id string,
 		uid int,
 	) (*models.Expression, error)
+	CancelExpression(
+		ctx context.Context,
+		id string,
+		uid int,
+	) (*models.Expression, error)
+	GetExpressionEvents(
+		ctx context.Context,
+		id string,
+		uid int,
+	) ([]models.ExpressionEvent, error)
+	GetExpressionTrace(
+		ctx context.Context,
+		id string,
+		uid int,
+	) ([]models.ExpressionStep, error)
+	WatchExpression(
+		ctx context.Context,
+		id string,
+		uid int,
+	) ([]models.ExpressionEvent, *hub.Subscription, error)
 	EvaluateExpression(
 		ctx context.Context,
 		expression *models.Expression,
 		uid int,
-	) (float32, error)
+		idempotencyKey string,
+	) (*models.Expression, bool, error)
+	EvaluateExpressions(
+		ctx context.Context,
+		expressions []*models.Expression,
+		uid int,
+	) ([]error, error)
+	GetExpressionsByIds(
+		ctx context.Context,
+		ids []string,
+		uid int,
+	) (map[string]*models.Expression, error)
+	GetWebhookSecret(
+		ctx context.Context,
+		uid int,
+	) (string, error)
+	RotateWebhookSecret(
+		ctx context.Context,
+		uid int,
+	) (string, error)
 	GetAgentStates(
 		ctx context.Context,
 	) ([]models.Agent, error)
 	GetExpressionsForUser(
 		ctx context.Context,
 		uid int,
-	) ([]models.Expression, error)
+		filter models.ExpressionFilter,
+	) ([]models.Expression, *models.Cursor, error)
 	Login(
 		ctx context.Context,
 		email string,
@@ -50,10 +94,18 @@ type HttpService interface {
 		email string,
 		password string,
 	) (int, error)
+	GetOperations(
+		ctx context.Context,
+	) ([]models.OperationDuration, error)
+	SetOperationDuration(
+		ctx context.Context,
+		operation string,
+		duration time.Duration,
+	) error
 }
 
-func New(log *slog.Logger, storage httpservice.ExpressionStorage, port int, secret string, client *grpc.Client) *Server {
-	httpService := httpservice.New(log, storage, client)
+func New(log *slog.Logger, storage httpservice.ExpressionStorage, port int, secret string, client *grpc.Client, tasksReady *notify.Notifier, cancelled *notify.Notifier, idempotencyTTL time.Duration, cacheSize int, hub *hub.Hub, guard *safeurl.Guard) *Server {
+	httpService := httpservice.New(log, storage, client, tasksReady, cancelled, idempotencyTTL, cacheSize, hub)
 
 	server := &Server{
 		log:         log,
@@ -65,15 +117,31 @@ func New(log *slog.Logger, storage httpservice.ExpressionStorage, port int, secr
 
 	// Хэндлеры для запросов с сайта
 	serveMux.HandleFunc("/", server.MainPage)
-	serveMux.Handle("/expression", middleware.ValidateToken(middleware.ValidateExpressionMiddleware(http.HandlerFunc(server.EvaluateExpression)), server.secret)).Methods("POST")
+	serveMux.Handle("/expression", middleware.ValidateToken(middleware.ValidateExpressionMiddleware(http.HandlerFunc(server.EvaluateExpression), guard), server.secret)).Methods("POST")
 	serveMux.Handle("/expression", middleware.ValidateToken(http.HandlerFunc(server.GetExpressionById), server.secret)).Methods("GET")
+	serveMux.Handle("/expression/status", middleware.ValidateToken(http.HandlerFunc(server.GetExpressionStatus), server.secret)).Methods("GET")
 	serveMux.Handle("/all_expressions", middleware.ValidateToken(http.HandlerFunc(server.GetExpressionsForUser), server.secret)).Methods("GET")
 	serveMux.HandleFunc("/login", server.Login).Methods("POST")
 	serveMux.HandleFunc("/register", server.Register).Methods("POST")
 	serveMux.HandleFunc("/agents_state", server.GetAgentStates).Methods("GET")
+	serveMux.Handle("/operations", middleware.ValidateToken(http.HandlerFunc(server.GetOperations), server.secret)).Methods("GET")
+	serveMux.Handle("/operations", middleware.ValidateToken(http.HandlerFunc(server.SetOperationDuration), server.secret)).Methods("PUT")
 
 	// Хэндлеры для API
 	serveMux.HandleFunc("/api/v1/getimpodencekey", server.GetImpodenceKeyHandler).Methods("POST")
+	serveMux.Handle("/api/v1/expressions", middleware.ValidateTokenAPI(middleware.ValidateExpressionMiddlewareAPI(http.HandlerFunc(server.ApiEvaluateExpression), guard), server.secret)).Methods("POST")
+	serveMux.Handle("/api/v1/expressions", middleware.ValidateTokenAPI(http.HandlerFunc(server.ApiGetExpressionsForUser), server.secret)).Methods("GET")
+	serveMux.Handle("/api/v1/expressions:batch", middleware.ValidateTokenAPI(middleware.ValidateBatchMiddlewareAPI(http.HandlerFunc(server.ApiEvaluateBatch), guard), server.secret)).Methods("POST")
+	serveMux.Handle("/api/v1/expressions:batch", middleware.ValidateTokenAPI(http.HandlerFunc(server.ApiGetExpressionsBatch), server.secret)).Methods("GET")
+	serveMux.Handle("/api/v1/expressions/{id}", middleware.ValidateTokenAPI(http.HandlerFunc(server.ApiGetExpressionById), server.secret)).Methods("GET")
+	serveMux.Handle("/api/v1/expressions/{id}", middleware.ValidateTokenAPI(http.HandlerFunc(server.ApiCancelExpression), server.secret)).Methods("DELETE")
+	serveMux.Handle("/api/v1/expressions/{id}/events", middleware.ValidateTokenAPI(http.HandlerFunc(server.ApiGetExpressionEvents), server.secret)).Methods("GET")
+	serveMux.Handle("/api/v1/expressions/{id}/trace", middleware.ValidateTokenAPI(http.HandlerFunc(server.ApiGetExpressionTrace), server.secret)).Methods("GET")
+	serveMux.Handle("/api/v1/webhooks/secret", middleware.ValidateTokenAPI(http.HandlerFunc(server.ApiGetWebhookSecret), server.secret)).Methods("GET")
+	serveMux.Handle("/api/v1/webhooks/secret", middleware.ValidateTokenAPI(http.HandlerFunc(server.ApiRotateWebhookSecret), server.secret)).Methods("POST")
+	serveMux.HandleFunc("/api/v1/agents", server.ApiGetAgentStates).Methods("GET")
+	serveMux.HandleFunc("/api/v1/login", server.ApiLogin).Methods("POST")
+	serveMux.HandleFunc("/api/v1/register", server.ApiRegister).Methods("POST")
 
 	http.Handle("/", serveMux)
 
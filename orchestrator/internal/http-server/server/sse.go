@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	model "github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/api/response"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/storage"
+)
+
+// Как часто в поток пишется комментарий, чтобы прокси не закрывали соединение.
+// Заодно из истории дочитываются переходы, которые могли потеряться, пока не было соединения с БД
+const sseHeartbeat = 15 * time.Second
+
+// streamExpressionEvents отдаёт Server-Sent Events: сначала историю статусов, потом новые переходы (event: status)
+// и вычисленные агентами операции (event: progress). Поток закрывается после конечного статуса.
+// У переходов есть id, поэтому при переподключении с Last-Event-ID приходят только пропущенные
+func (s *Server) streamExpressionEvents(w http.ResponseWriter, r *http.Request, id string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		response.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	lastId := 0
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		var err error
+		if lastId, err = strconv.Atoi(header); err != nil {
+			response.Error(w, "Last-Event-ID should be id of event", http.StatusBadRequest)
+			return
+		}
+	}
+
+	uid := r.Context().Value("uid").(int)
+	events, subscription, err := s.httpService.WatchExpression(r.Context(), id, uid)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			response.Error(w, "expression not found", http.StatusNotFound)
+			return
+		}
+		s.internalError(w, r, err)
+		return
+	}
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// sendEvents пишет переходы, которых клиент ещё не видел, и сообщает, закончилось ли выражение
+	sendEvents := func(events ...model.ExpressionEvent) bool {
+		for _, event := range events {
+			if event.ID <= lastId {
+				continue
+			}
+			lastId = event.ID
+			writeSSE(w, strconv.Itoa(event.ID), "status", event)
+			if event.Status.IsFinal() {
+				return true
+			}
+		}
+		return false
+	}
+
+	finished := sendEvents(events...)
+	flusher.Flush()
+	if finished {
+		return
+	}
+
+	ticker := time.NewTicker(sseHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case update, ok := <-subscription.Updates:
+			if !ok {
+				// клиент не успевал читать, он переподключится с Last-Event-ID
+				return
+			}
+			if update.Event != nil {
+				finished = sendEvents(*update.Event)
+			} else if update.Progress != nil {
+				writeSSE(w, "", "progress", update.Progress)
+			}
+		case <-ticker.C:
+			events, err = s.httpService.GetExpressionEvents(r.Context(), id, uid)
+			if err == nil {
+				finished = sendEvents(events...)
+			}
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		flusher.Flush()
+		if finished {
+			return
+		}
+	}
+}
+
+// writeSSE пишет одно событие, id можно не указывать
+func writeSSE(w http.ResponseWriter, id string, event string, data any) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, body)
+}
@@ -0,0 +1,75 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Codes of errors in ErrorResponse, client can rely on them instead of messages
+const (
+	CodeBadRequest   = "bad_request"
+	CodeUnauthorized = "unauthorized"
+	CodeNotFound     = "not_found"
+	CodeConflict     = "conflict"
+	CodeInternal     = "internal"
+)
+
+// ErrorResponse is envelope of every error returned by API
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"` // e.g. kind and position of error in expression
+}
+
+// JSON writes v as body of response with given status
+func JSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Error writes error envelope. Its signature is the same as of http.Error,
+// so it can be used by middlewares instead of it
+func Error(w http.ResponseWriter, message string, status int) {
+	JSON(w, status, ErrorResponse{
+		Error: ErrorBody{
+			Code:    codeOf(status),
+			Message: message,
+		},
+	})
+}
+
+// ErrorWithDetails writes error envelope with additional information about error
+func ErrorWithDetails(w http.ResponseWriter, message string, status int, details any) {
+	JSON(w, status, ErrorResponse{
+		Error: NewErrorBody(message, status, details),
+	})
+}
+
+// NewErrorBody makes error, which isn't written at once, e.g. error of one item of batch
+func NewErrorBody(message string, status int, details any) ErrorBody {
+	return ErrorBody{
+		Code:    codeOf(status),
+		Message: message,
+		Details: details,
+	}
+}
+
+func codeOf(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	default:
+		return CodeInternal
+	}
+}
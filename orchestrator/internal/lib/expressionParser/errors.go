@@ -0,0 +1,64 @@
+package expressionparser
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrorKind says what is wrong with expression, clients can rely on it instead of message
+type ErrorKind string
+
+const (
+	KindEmptyExpression       ErrorKind = "empty_expression"
+	KindUnbalancedParenthesis ErrorKind = "unbalanced_parenthesis"
+	KindUnknownToken          ErrorKind = "unknown_token"
+	KindUnexpectedToken       ErrorKind = "unexpected_token"
+	KindMissingOperand        ErrorKind = "missing_operand"
+	KindMissingOperator       ErrorKind = "missing_operator"
+	KindInvalidArguments      ErrorKind = "invalid_arguments"
+	KindDivisionByZero        ErrorKind = "division_by_zero"
+	KindDomainError           ErrorKind = "domain_error"      // function isn't defined for argument, e.g. sqrt(-1)
+	KindOutOfRange            ErrorKind = "out_of_range"      // number is too big
+	KindInexact               ErrorKind = "inexact_operation" // irrational function or constant in exact mode
+	KindReservedName          ErrorKind = "reserved_name"     // variable is named like function or constant
+)
+
+// ParseError is returned by ParseExpression for every invalid expression
+type ParseError struct {
+	Kind    ErrorKind `json:"kind"`
+	Message string    `json:"message"`
+	Offset  int       `json:"offset"` // byte offset of Token in expression, -1 if error isn't bound to position
+	Token   string    `json:"token"`
+
+	expression string
+}
+
+func newError(kind ErrorKind, lex lexeme, format string, args ...any) *ParseError {
+	return &ParseError{
+		Kind:    kind,
+		Message: fmt.Sprintf(format, args...),
+		Offset:  lex.pos,
+		Token:   lex.text,
+	}
+}
+
+func (e *ParseError) Error() string {
+	if e.Offset < 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%s at %d", e.Message, e.Offset)
+}
+
+// Caret returns expression with caret under the wrong token, e.g.
+//
+//	1 + (2 * 3
+//	    ^ unbalanced parenthesis
+func (e *ParseError) Caret() string {
+	if e.Offset < 0 || e.Offset > len(e.expression) {
+		return e.Message
+	}
+	// caret is placed by characters, not bytes
+	column := utf8.RuneCountInString(e.expression[:e.Offset])
+	return fmt.Sprintf("%s\n%s^ %s", e.expression, strings.Repeat(" ", column), e.Message)
+}
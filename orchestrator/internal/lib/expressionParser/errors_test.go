@@ -0,0 +1,75 @@
+package expressionparser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+)
+
+func TestParseErrorOffset(t *testing.T) {
+	tests := []struct {
+		expression string
+		kind       ErrorKind
+		offset     int
+		token      string
+	}{
+		{"", KindEmptyExpression, 0, ""},
+		{"   ", KindEmptyExpression, 3, ""},
+		{"+", KindMissingOperand, 0, "+"},
+		{"2*+", KindMissingOperand, 2, "+"},
+		{"+)", KindMissingOperand, 0, "+"},
+		{"-", KindMissingOperand, 0, "-"},
+		{"1 + (2 * 3", KindUnbalancedParenthesis, 4, "("},
+		{"1 + 2)", KindUnbalancedParenthesis, 5, ")"},
+		{"2 $ 3", KindUnknownToken, 2, "$"},
+		{"1 + foo(2)", KindUnknownToken, 4, "foo"},
+		{"1 2", KindMissingOperator, 2, "2"},
+		{"(1+2) (3)", KindMissingOperator, 7, "3"},
+		{"1 / 0", KindDivisionByZero, 4, "0"},
+		{"sqrt(-4)", KindDomainError, 0, "sqrt"},
+		{"max(1, 2) + sqrt(1, 2)", KindInvalidArguments, 12, "sqrt"},
+		// смещение в байтах, а не в символах
+		{"1 + ё", KindUnknownToken, 4, "ё"},
+		{"ёж + 1", KindUnknownToken, 0, "ёж"},
+		{"1 + ёж", KindUnknownToken, 4, "ёж"},
+	}
+	for _, tt := range tests {
+		_, err := ParseExpression(tt.expression, nil, models.PrecisionFloat)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("%q: err %v; want %s", tt.expression, err, tt.kind)
+			continue
+		}
+		if perr.Kind != tt.kind || perr.Offset != tt.offset || perr.Token != tt.token {
+			t.Errorf("%q: %s at %d on %q; want %s at %d on %q", tt.expression, perr.Kind, perr.Offset, perr.Token, tt.kind, tt.offset, tt.token)
+		}
+	}
+}
+
+func TestParseErrorCaret(t *testing.T) {
+	tests := []struct {
+		expression string
+		variables  map[string]float64
+		want       string
+	}{
+		{"1 + (2 * 3", nil, "1 + (2 * 3\n    ^ parenthesis is not closed"},
+		{"+", nil, "+\n^ missing operand of +"},
+		{"", nil, "\n^ expression is empty"},
+		// каретка ставится по символам: перед x две кириллические буквы по 2 байта
+		{"ёё + x", map[string]float64{"ёё": 1}, "ёё + x\n     ^ unknown variable \"x\""},
+		// ошибка без позиции
+		{"pi", map[string]float64{"pi": 1}, "variable \"pi\" has reserved name"},
+	}
+	for _, tt := range tests {
+		_, err := ParseExpression(tt.expression, tt.variables, models.PrecisionFloat)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("%q: err %v; want *ParseError", tt.expression, err)
+			continue
+		}
+		if got := perr.Caret(); got != tt.want {
+			t.Errorf("%q: caret is\n%s\nwant\n%s", tt.expression, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package expressionparser
+
+import "math"
+
+// Unary minus is operator with its own name, so its duration is configured separately from subtraction
+const Negation = "neg"
+
+type operator struct {
+	precedence      int
+	rightAssociated bool
+	arity           int
+	commutative     bool // order of operands doesn't change result, see Normalize
+}
+
+var operators = map[string]operator{
+	"+":      {precedence: 1, arity: 2, commutative: true},
+	"-":      {precedence: 1, arity: 2},
+	"*":      {precedence: 2, arity: 2, commutative: true},
+	"/":      {precedence: 2, arity: 2},
+	Negation: {precedence: 3, rightAssociated: true, arity: 1}, // -2^2 = -(2^2), but -2*3 = (-2)*3
+	"^":      {precedence: 4, rightAssociated: true, arity: 2},
+}
+
+// function takes from minArgs to maxArgs arguments, maxArgs < 0 means any number of them.
+// Only exact functions can be used with models.PrecisionExact
+type function struct {
+	minArgs     int
+	maxArgs     int
+	exact       bool
+	commutative bool
+}
+
+var functions = map[string]function{
+	"sqrt": {minArgs: 1, maxArgs: 1},
+	"abs":  {minArgs: 1, maxArgs: 1, exact: true},
+	"sin":  {minArgs: 1, maxArgs: 1},
+	"cos":  {minArgs: 1, maxArgs: 1},
+	"log":  {minArgs: 1, maxArgs: 1}, // natural logarithm
+	"min":  {minArgs: 1, maxArgs: -1, exact: true, commutative: true},
+	"max":  {minArgs: 1, maxArgs: -1, exact: true, commutative: true},
+}
+
+var constants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+// IsFunction reports whether name is function of expression language, not operator
+func IsFunction(name string) bool {
+	_, ok := functions[name]
+	return ok
+}
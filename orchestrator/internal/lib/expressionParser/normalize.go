@@ -0,0 +1,74 @@
+package expressionparser
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/number"
+)
+
+var ErrInvalidTokens = errors.New("invalid tokens")
+
+// Normalize returns canonical postfix form of parsed expression. Expressions which differ only in spaces,
+// redundant parentheses, notation of numbers ("2.0" and "2") or order of operands of commutative operations
+// ("1+2" and "2+1") get the same form, so they surely have the same result.
+//
+// Operations aren't regrouped: (1+2)+3 and 1+(2+3) may differ in float64
+func Normalize(tokens []*models.Token, precision models.Precision) (string, error) {
+	var stack []string // canonical forms of operands
+	for _, token := range tokens {
+		if token.Type == models.Operand {
+			value, err := normalizeNumber(token.Value, precision)
+			if err != nil {
+				return "", err
+			}
+			stack = append(stack, value)
+			continue
+		}
+
+		if token.Arity < 1 || len(stack) < token.Arity {
+			return "", ErrInvalidTokens
+		}
+		operands := append([]string{}, stack[len(stack)-token.Arity:]...)
+		stack = stack[:len(stack)-token.Arity]
+
+		if isCommutative(token) {
+			sort.Strings(operands)
+		}
+		// arity is kept, so max(1, 2) and max(max(1), 2) don't get the same form
+		form := strings.Join(append(operands, token.Name+"/"+strconv.Itoa(token.Arity)), " ")
+		stack = append(stack, form)
+	}
+
+	if len(stack) != 1 {
+		return "", ErrInvalidTokens
+	}
+	return stack[0], nil
+}
+
+func normalizeNumber(value string, precision models.Precision) (string, error) {
+	if precision == models.PrecisionExact {
+		r, ok := new(big.Rat).SetString(value)
+		if !ok {
+			return "", ErrInvalidTokens
+		}
+		return number.FormatRat(r), nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return "", ErrInvalidTokens
+	}
+	return number.FormatFloat(f), nil
+}
+
+func isCommutative(token *models.Token) bool {
+	if token.Type == models.Function {
+		return functions[token.Name].commutative
+	}
+	return operators[token.Name].commutative && operators[token.Name].arity == token.Arity
+}
@@ -0,0 +1,76 @@
+package expressionparser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+)
+
+func normalized(t *testing.T, expression string, variables map[string]float64, precision models.Precision) string {
+	t.Helper()
+
+	tokens, err := ParseExpression(expression, variables, precision)
+	if err != nil {
+		t.Fatalf("%q: %v", expression, err)
+	}
+	form, err := Normalize(tokens, precision)
+	if err != nil {
+		t.Fatalf("%q: %v", expression, err)
+	}
+	return form
+}
+
+func TestNormalize(t *testing.T) {
+	variables := map[string]float64{"x": 2}
+
+	tests := []struct {
+		a, b      string
+		precision models.Precision
+		same      bool
+	}{
+		// одно и то же выражение
+		{"1+2", " 1 +  2 ", models.PrecisionFloat, true},
+		{"(1+2)*3", "((1+2))*(3)", models.PrecisionFloat, true},
+		{"2.0*3", "2*3.000", models.PrecisionFloat, true},
+		{"2*3", "3*2", models.PrecisionFloat, true},
+		{"1+2*3", "3*2+1", models.PrecisionFloat, true},
+		{"max(1, 2, 3)", "max(3, 1, 2)", models.PrecisionFloat, true},
+		{"-(2)", "-2", models.PrecisionFloat, true},
+		{"x+1", "1+2", models.PrecisionFloat, true}, // переменная подставляется значением
+		{"0.5+1", "1+0.50", models.PrecisionExact, true},
+		// разные выражения
+		{"1-2", "2-1", models.PrecisionFloat, false},
+		{"1/2", "2/1", models.PrecisionFloat, false},
+		{"2^3", "3^2", models.PrecisionFloat, false},
+		{"(1+2)*3", "1+2*3", models.PrecisionFloat, false},
+		{"-2^2", "(-2)^2", models.PrecisionFloat, false},
+		{"1-2", "1+-2", models.PrecisionFloat, false},
+		{"sqrt(4)", "abs(4)", models.PrecisionFloat, false},
+		{"max(1, 2)", "max(max(1), 2)", models.PrecisionFloat, false},
+		{"min(1, 2)", "max(1, 2)", models.PrecisionFloat, false},
+		{"0.1+0.2", "0.3", models.PrecisionFloat, false},
+		// группировка не меняется: в float64 результат может отличаться
+		{"(1+2)+3", "1+(2+3)", models.PrecisionFloat, false},
+	}
+	for _, tt := range tests {
+		a, b := normalized(t, tt.a, variables, tt.precision), normalized(t, tt.b, variables, tt.precision)
+		if (a == b) != tt.same {
+			t.Errorf("%q is %q, %q is %q; want same %v", tt.a, a, tt.b, b, tt.same)
+		}
+	}
+}
+
+func TestNormalizeInvalidTokens(t *testing.T) {
+	tests := [][]*models.Token{
+		nil,
+		{models.NewOperationToken("+", 2)},
+		{models.NewOperandToken("1"), models.NewOperandToken("2")},
+		{models.NewOperandToken("abc")},
+	}
+	for _, tokens := range tests {
+		if _, err := Normalize(tokens, models.PrecisionFloat); !errors.Is(err, ErrInvalidTokens) {
+			t.Errorf("%s: err %v; want %v", postfix(tokens), err, ErrInvalidTokens)
+		}
+	}
+}
@@ -1,45 +1,220 @@
 package expressionparser
 
 import (
-	"strings"
+	"errors"
+	"math/big"
+	"strconv"
 
-	shuntingYard "github.com/a-romash/go-shunting-yard"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/number"
 )
 
-// Парсим наше выражение и заодно проверяем на валидность
-func ParseExpression(expression string) ([]*shuntingYard.RPNToken, error) {
-	// parse input expression to infix notation
-	infixTokens, err := shuntingYard.Scan(expression)
+// stackItem is element of operators stack: operator, function or left parenthesis
+type stackItem struct {
+	kind int // lexOperator, lexIdent for function or lexLeftParen
+	name string
+	lex  lexeme
+}
+
+// Парсим наше выражение и заодно проверяем на валидность.
+// Кроме операторов + - * / ^ поддерживаются унарный минус, функции (sqrt, abs, sin, cos, log, min, max),
+// константы pi и e, а также переменные, значения которых передаются в variables.
+//
+// Значения операндов в токенах - строки: float64 для models.PrecisionFloat и точные дроби для models.PrecisionExact.
+// Иррациональные константы и функции (sqrt, sin, cos, log) точно посчитать нельзя, в этом режиме они запрещены.
+//
+// Ошибка всегда *ParseError: с видом ошибки, позицией и токеном, на котором она найдена
+func ParseExpression(expression string, variables map[string]float64, precision models.Precision) ([]*models.Token, error) {
+	tokens, err := parse(expression, variables, precision)
 	if err != nil {
+		err.expression = expression
 		return nil, err
 	}
+	return tokens, nil
+}
 
-	// convert infix notation to postfix notation(RPN)
-	postfixTokens, err := shuntingYard.Parse(infixTokens)
-	if err != nil {
-		return nil, err
+func parse(expression string, variables map[string]float64, precision models.Precision) ([]*models.Token, *ParseError) {
+	for name := range variables {
+		if _, ok := constants[name]; ok || IsFunction(name) {
+			return nil, newError(KindReservedName, lexeme{text: name, pos: -1}, "variable %q has reserved name", name)
+		}
+	}
+	exact := precision == models.PrecisionExact
+
+	// operand converts number to value of token
+	operand := func(lex lexeme, text string) (*models.Token, *ParseError) {
+		if !exact {
+			// too big number becomes +Inf, validate reports it as out of range
+			f, err := strconv.ParseFloat(text, 64)
+			if err != nil && !errors.Is(err, strconv.ErrRange) {
+				return nil, newError(KindUnknownToken, lex, "invalid number %q", text)
+			}
+			return models.NewOperandToken(number.FormatFloat(f)), nil
+		}
+		r, ok := new(big.Rat).SetString(text)
+		if !ok {
+			return nil, newError(KindUnknownToken, lex, "invalid number %q", text)
+		}
+		return models.NewOperandToken(number.FormatRat(r)), nil
 	}
 
-	// Костыль :) (если вы это видите - значит я не исправил этот костыль или просто не увидел большой смысл)
-	// Здесь мы проверяем на валидность наше выражение (да, по факту мы его считаем, но чисто чтобы проверить на валидность)
-	_, err = shuntingYard.Evaluate(postfixTokens)
+	lexemes, err := scan(expression)
 	if err != nil {
 		return nil, err
 	}
 
-	return postfixTokens, nil
+	// convert infix notation to postfix notation(RPN) using shunting-yard algorithm
+	var (
+		output    []*models.Token
+		sources   []lexeme // lexeme of every token of output, errors of validation point to it
+		stack     []stackItem
+		argCounts []int // number of arguments of every function call which is being parsed
+	)
+
+	emit := func(token *models.Token, lex lexeme) {
+		output = append(output, token)
+		sources = append(sources, lex)
+	}
+
+	// pop moves top of stack to output
+	pop := func() {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if top.kind == lexIdent {
+			emit(models.NewFunctionToken(top.name, argCounts[len(argCounts)-1]), top.lex)
+			argCounts = argCounts[:len(argCounts)-1]
+			return
+		}
+		emit(models.NewOperationToken(top.name, operators[top.name].arity), top.lex)
+	}
+
+	// popUntilParen moves operators to output until left parenthesis is on top of stack
+	popUntilParen := func(lex lexeme) *ParseError {
+		for len(stack) > 0 && stack[len(stack)-1].kind != lexLeftParen {
+			pop()
+		}
+		if len(stack) == 0 {
+			return newError(KindUnbalancedParenthesis, lex, "unexpected %s without opening parenthesis", lex.text)
+		}
+		return nil
+	}
+
+	for i, lex := range lexemes {
+		// Minus is unary if there is no operand before it
+		unary := i == 0 || lexemes[i-1].kind == lexOperator || lexemes[i-1].kind == lexLeftParen || lexemes[i-1].kind == lexComma
+
+		switch lex.kind {
+		case lexNumber:
+			token, err := operand(lex, lex.text)
+			if err != nil {
+				return nil, err
+			}
+			emit(token, lex)
+
+		case lexIdent:
+			if i+1 < len(lexemes) && lexemes[i+1].kind == lexLeftParen {
+				if !IsFunction(lex.text) {
+					return nil, newError(KindUnknownToken, lex, "unknown function %q", lex.text)
+				}
+				if exact && !functions[lex.text].exact {
+					return nil, newError(KindInexact, lex, "function %s can't be evaluated exactly", lex.text)
+				}
+				stack = append(stack, stackItem{kind: lexIdent, name: lex.text, lex: lex})
+				argCounts = append(argCounts, 1)
+				continue
+			}
+			value, ok := constants[lex.text]
+			if ok && exact {
+				return nil, newError(KindInexact, lex, "constant %s can't be evaluated exactly", lex.text)
+			}
+			if !ok {
+				value, ok = variables[lex.text]
+			}
+			if !ok {
+				return nil, newError(KindUnknownToken, lex, "unknown variable %q", lex.text)
+			}
+			// Variable 0.1 is exactly 1/10 and not the closest float to it
+			token, err := operand(lex, number.FormatFloat(value))
+			if err != nil {
+				return nil, err
+			}
+			emit(token, lex)
+
+		case lexOperator:
+			name := lex.text
+			if unary {
+				if name == "+" {
+					// unary plus changes nothing, but it still needs operand
+					if i+1 == len(lexemes) || !startsOperand(lexemes[i+1]) {
+						return nil, newError(KindMissingOperand, lex, "missing operand of %s", lex.text)
+					}
+					continue
+				}
+				if name == "-" {
+					// prefix operator is applied after operand, so nothing is popped here
+					stack = append(stack, stackItem{kind: lexOperator, name: Negation, lex: lex})
+					continue
+				}
+			}
+
+			current := operators[name]
+			for len(stack) > 0 {
+				top := stack[len(stack)-1]
+				if top.kind != lexOperator {
+					break
+				}
+				prev := operators[top.name]
+				if prev.precedence < current.precedence || (prev.precedence == current.precedence && current.rightAssociated) {
+					break
+				}
+				pop()
+			}
+			stack = append(stack, stackItem{kind: lexOperator, name: name, lex: lex})
+
+		case lexLeftParen:
+			stack = append(stack, stackItem{kind: lexLeftParen, lex: lex})
+
+		case lexComma:
+			// comma separates arguments only inside of function call
+			if popUntilParen(lex) != nil || len(stack) < 2 || stack[len(stack)-2].kind != lexIdent {
+				return nil, newError(KindUnexpectedToken, lex, "unexpected comma outside of function call")
+			}
+			argCounts[len(argCounts)-1]++
+
+		case lexRightParen:
+			if err := popUntilParen(lex); err != nil {
+				return nil, err
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 && stack[len(stack)-1].kind == lexIdent {
+				pop()
+			}
+		}
+	}
+
+	for len(stack) > 0 {
+		if top := stack[len(stack)-1]; top.kind == lexLeftParen {
+			return nil, newError(KindUnbalancedParenthesis, top.lex, "parenthesis is not closed")
+		}
+		pop()
+	}
+
+	// the expression isn't evaluated here, agents do it. Only what is known before evaluation is checked
+	if err := validate(output, sources, len(expression), exact); err != nil {
+		return nil, err
+	}
+	return output, nil
 }
 
-// Создаём ключ имподентности для выражения
-func CreateImpodenceKey(expression string) string {
-	// Да, костыль, да, можно было по другому, но как есть
-	expression = strings.ReplaceAll(expression, "+", "_p_")
-	expression = strings.ReplaceAll(expression, "-", "_min_")
-	expression = strings.ReplaceAll(expression, "*", "_mul_")
-	expression = strings.ReplaceAll(expression, "/", "_d_")
-	expression = strings.ReplaceAll(expression, "(", "_o_")
-	expression = strings.ReplaceAll(expression, ")", "_c_")
-	return expression
+// startsOperand reports whether operand can start with lex: number, name, parenthesis or unary operator
+func startsOperand(lex lexeme) bool {
+	switch lex.kind {
+	case lexNumber, lexIdent, lexLeftParen:
+		return true
+	case lexOperator:
+		return lex.text == "+" || lex.text == "-"
+	}
+	return false
 }
 
 // type Node struct {
@@ -0,0 +1,118 @@
+package expressionparser
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/number"
+)
+
+// postfix записывает токены через пробел: операнды значениями, функции с числом аргументов, например max/3
+func postfix(tokens []*models.Token) string {
+	parts := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		switch token.Type {
+		case models.Operand:
+			parts = append(parts, token.Value)
+		case models.Function:
+			parts = append(parts, token.Name+"/"+strconv.Itoa(token.Arity))
+		default:
+			parts = append(parts, token.Name)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParseExpression(t *testing.T) {
+	tests := []struct {
+		expression string
+		variables  map[string]float64
+		want       string
+	}{
+		// приоритет и ассоциативность
+		{"1+2*3", nil, "1 2 3 * +"},
+		{"(1+2)*3", nil, "1 2 + 3 *"},
+		{"1-2-3", nil, "1 2 - 3 -"},
+		{"8/4/2", nil, "8 4 / 2 /"},
+		{"2^3^2", nil, "2 3 2 ^ ^"},
+		{"2*3^2", nil, "2 3 2 ^ *"},
+		{" ( ( 1 ) ) ", nil, "1"},
+		// унарный минус
+		{"-2^2", nil, "2 2 ^ neg"},
+		{"-2*3", nil, "2 neg 3 *"},
+		{"2*-3", nil, "2 3 neg *"},
+		{"2^-1", nil, "2 1 neg ^"},
+		{"--2", nil, "2 neg neg"},
+		{"-(1+2)", nil, "1 2 + neg"},
+		{"2*-(1+2)", nil, "2 1 2 + neg *"},
+		{"-sqrt(4)", nil, "4 sqrt/1 neg"},
+		{"1-max(2,3)", nil, "1 2 3 max/2 -"},
+		{"+2", nil, "2"},
+		// функции
+		{"sqrt(16)", nil, "16 sqrt/1"},
+		{"max(1, 2+3, -4)", nil, "1 2 3 + 4 neg max/3"},
+		{"min(max(1,2),3)", nil, "1 2 max/2 3 min/2"},
+		{"abs(-1)*2", nil, "1 neg abs/1 2 *"},
+		// константы и переменные
+		{"2*pi", nil, "2 " + number.FormatFloat(math.Pi) + " *"},
+		{"e", nil, number.FormatFloat(math.E)},
+		{"x^2 + y", map[string]float64{"x": 3, "y": 1}, "3 2 ^ 1 +"},
+		{"-x", map[string]float64{"x": 0.5}, "0.5 neg"},
+	}
+	for _, tt := range tests {
+		tokens, err := ParseExpression(tt.expression, tt.variables, models.PrecisionFloat)
+		if err != nil {
+			t.Errorf("%q: %v", tt.expression, err)
+			continue
+		}
+		if got := postfix(tokens); got != tt.want {
+			t.Errorf("%q is %q; want %q", tt.expression, got, tt.want)
+		}
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		variables  map[string]float64
+		precision  models.Precision
+		kind       ErrorKind
+	}{
+		// число аргументов функции
+		{"sqrt(1, 2)", nil, models.PrecisionFloat, KindInvalidArguments},
+		{"abs(1, 2, 3)", nil, models.PrecisionFloat, KindInvalidArguments},
+		{"max()", nil, models.PrecisionFloat, KindMissingOperand},
+		{"max(1,)", nil, models.PrecisionFloat, KindMissingOperand},
+		{"1, 2", nil, models.PrecisionFloat, KindUnexpectedToken},
+		// неизвестные имена
+		{"foo(1)", nil, models.PrecisionFloat, KindUnknownToken},
+		{"x+1", nil, models.PrecisionFloat, KindUnknownToken},
+		{"x+y", map[string]float64{"x": 1}, models.PrecisionFloat, KindUnknownToken},
+		{"sqrt", nil, models.PrecisionFloat, KindUnknownToken},
+		{"pi+1", map[string]float64{"pi": 3}, models.PrecisionFloat, KindReservedName},
+		{"max+1", map[string]float64{"max": 3}, models.PrecisionFloat, KindReservedName},
+		// в точном режиме нельзя иррациональное
+		{"sqrt(4)", nil, models.PrecisionExact, KindInexact},
+		{"2*pi", nil, models.PrecisionExact, KindInexact},
+		// операнды и операторы
+		{"-", nil, models.PrecisionFloat, KindMissingOperand},
+		{"2*", nil, models.PrecisionFloat, KindMissingOperand},
+		{"2 3", nil, models.PrecisionFloat, KindMissingOperator},
+		{"2(3)", nil, models.PrecisionFloat, KindMissingOperator},
+	}
+	for _, tt := range tests {
+		_, err := ParseExpression(tt.expression, tt.variables, tt.precision)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("%q: err %v; want %s", tt.expression, err, tt.kind)
+			continue
+		}
+		if perr.Kind != tt.kind {
+			t.Errorf("%q: kind %s (%v); want %s", tt.expression, perr.Kind, perr, tt.kind)
+		}
+	}
+}
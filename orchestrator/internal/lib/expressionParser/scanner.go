@@ -0,0 +1,66 @@
+package expressionparser
+
+import "unicode"
+
+// Kinds of lexemes
+const (
+	lexNumber = iota
+	lexIdent
+	lexOperator
+	lexLeftParen
+	lexRightParen
+	lexComma
+)
+
+// lexeme is piece of infix expression: number, name, operator, parenthesis or comma
+type lexeme struct {
+	kind int
+	text string
+	pos  int // byte offset in expression
+}
+
+// scan splits infix expression into lexemes
+func scan(expression string) ([]lexeme, *ParseError) {
+	var lexemes []lexeme
+
+	runes := []rune(expression)
+	offset := 0 // byte offset of runes[i]
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start, startOffset := i, offset
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			// number is checked by parser, it depends on precision
+			lexemes = append(lexemes, lexeme{kind: lexNumber, text: string(runes[start:i]), pos: startOffset})
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			lexemes = append(lexemes, lexeme{kind: lexIdent, text: string(runes[start:i]), pos: startOffset})
+		case r == '+' || r == '-' || r == '*' || r == '/' || r == '^':
+			i++
+			lexemes = append(lexemes, lexeme{kind: lexOperator, text: string(r), pos: startOffset})
+		case r == '(':
+			i++
+			lexemes = append(lexemes, lexeme{kind: lexLeftParen, text: "(", pos: startOffset})
+		case r == ')':
+			i++
+			lexemes = append(lexemes, lexeme{kind: lexRightParen, text: ")", pos: startOffset})
+		case r == ',':
+			i++
+			lexemes = append(lexemes, lexeme{kind: lexComma, text: ",", pos: startOffset})
+		default:
+			return nil, newError(KindUnknownToken, lexeme{text: string(r), pos: startOffset}, "unknown token %q", string(r))
+		}
+
+		offset += len(string(runes[start:i]))
+	}
+
+	return lexemes, nil
+}
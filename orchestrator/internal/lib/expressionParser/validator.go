@@ -0,0 +1,148 @@
+package expressionparser
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+)
+
+const (
+	// MaxExactExponent is max absolute value of exponent in exact mode, agents refuse bigger ones
+	MaxExactExponent = 1024
+	// maxExactLength limits length of exact operand, e.g. "1/3" has length 3
+	maxExactLength = 1000
+)
+
+// value is element of stack of operands in validate
+type value struct {
+	start lexeme // first lexeme of operand, used to point where operator is missing
+
+	// literal is known, if operand is number, variable or negated one of them, and not result of other operation
+	literal bool
+	num     float64
+}
+
+// validate checks postfix expression statically, without evaluating it:
+//   - every token is known operand, operator or function with right arity,
+//   - every operator and function has enough operands and the whole expression is one value,
+//   - literals are in range of float64 (or not too long in exact mode),
+//   - there are no hazards which are obvious from literals, e.g. division by zero or sqrt(-1).
+//
+// sources are lexemes of tokens, errors point to them. end is length of expression, empty expression error points to it
+func validate(tokens []*models.Token, sources []lexeme, end int, exact bool) *ParseError {
+	if len(tokens) == 0 {
+		return newError(KindEmptyExpression, lexeme{pos: end}, "expression is empty")
+	}
+
+	var stack []value // operands, if expression was evaluated
+	for i, token := range tokens {
+		lex := sources[i]
+
+		switch token.Type {
+		case models.Operand:
+			literal, err := checkLiteral(token, lex, exact)
+			if err != nil {
+				return err
+			}
+			stack = append(stack, literal)
+			continue
+		case models.Operation:
+			if op, ok := operators[token.Name]; !ok || op.arity != token.Arity {
+				return newError(KindUnknownToken, lex, "unknown operator %s with %d operands", token.Name, token.Arity)
+			}
+		case models.Function:
+			f, ok := functions[token.Name]
+			if !ok {
+				return newError(KindUnknownToken, lex, "unknown function %q", token.Name)
+			}
+			if token.Arity < f.minArgs || (f.maxArgs >= 0 && token.Arity > f.maxArgs) {
+				return newError(KindInvalidArguments, lex, "function %s can't take %d arguments", token.Name, token.Arity)
+			}
+		default:
+			return newError(KindUnknownToken, lex, "unknown type of token %d", token.Type)
+		}
+
+		if len(stack) < token.Arity {
+			return newError(KindMissingOperand, lex, "missing operand of %s", lex.text)
+		}
+		operands := stack[len(stack)-token.Arity:]
+		if err := checkHazards(token.Name, operands, lex, exact); err != nil {
+			return err
+		}
+
+		// result of operation starts where its first operand or the operation itself starts
+		result := value{start: lex}
+		if len(operands) > 0 && operands[0].start.pos < result.start.pos {
+			result.start = operands[0].start
+		}
+		// sign of negated literal is still known: it's needed to see sqrt(-1)
+		if token.Name == Negation && operands[0].literal {
+			result.literal, result.num = true, -operands[0].num
+		}
+		stack = append(stack[:len(stack)-token.Arity], result)
+	}
+
+	if len(stack) != 1 {
+		// nothing joins the second operand with the first one
+		return newError(KindMissingOperator, stack[1].start, "missing operator before %s", stack[1].start.text)
+	}
+	return nil
+}
+
+// checkLiteral checks that operand is valid number in range
+func checkLiteral(token *models.Token, lex lexeme, exact bool) (value, *ParseError) {
+	if exact {
+		r, ok := new(big.Rat).SetString(token.Value)
+		if !ok {
+			return value{}, newError(KindUnknownToken, lex, "invalid number %q", token.Value)
+		}
+		if len(token.Value) > maxExactLength {
+			return value{}, newError(KindOutOfRange, lex, "number is longer than %d characters", maxExactLength)
+		}
+		f, _ := r.Float64()
+		return value{start: lex, literal: true, num: f}, nil
+	}
+
+	f, err := strconv.ParseFloat(token.Value, 64)
+	if err != nil {
+		return value{}, newError(KindUnknownToken, lex, "invalid number %q", token.Value)
+	}
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return value{}, newError(KindOutOfRange, lex, "number is out of range of float64")
+	}
+	return value{start: lex, literal: true, num: f}, nil
+}
+
+// checkHazards reports operations which will surely fail because of their literal operands
+func checkHazards(operation string, operands []value, lex lexeme, exact bool) *ParseError {
+	switch operation {
+	case "/":
+		if divisor := operands[1]; divisor.literal && divisor.num == 0 {
+			return newError(KindDivisionByZero, divisor.start, "division by zero")
+		}
+	case "^":
+		base, exponent := operands[0], operands[1]
+		if base.literal && base.num == 0 && exponent.literal && exponent.num < 0 {
+			return newError(KindDivisionByZero, lex, "zero can't be raised to negative power")
+		}
+		if exact && exponent.literal {
+			if exponent.num != math.Trunc(exponent.num) {
+				return newError(KindInexact, exponent.start, "exponent should be integer in exact mode")
+			}
+			if math.Abs(exponent.num) > MaxExactExponent {
+				return newError(KindOutOfRange, exponent.start, "exponent is bigger than %d", MaxExactExponent)
+			}
+		}
+	case "sqrt":
+		if arg := operands[0]; arg.literal && arg.num < 0 {
+			return newError(KindDomainError, lex, "square root of negative number")
+		}
+	case "log":
+		if arg := operands[0]; arg.literal && arg.num <= 0 {
+			return newError(KindDomainError, lex, "logarithm of non-positive number")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package notify
+
+import "sync"
+
+// Notifier wakes up everyone who waits for some event, e.g. for new tasks ready for evaluating
+type Notifier struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+func New() *Notifier {
+	return &Notifier{
+		ch: make(chan struct{}),
+	}
+}
+
+// Wait returns channel which is closed on the next Notify.
+// Take channel before checking state you wait for, otherwise notification can be missed
+func (n *Notifier) Wait() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.ch
+}
+
+// Notify wakes up all waiters
+func (n *Notifier) Notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	close(n.ch)
+	n.ch = make(chan struct{})
+}
@@ -0,0 +1,54 @@
+package number
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// Float converts operand or result to float64. Value is either float, e.g. "0.30000000000000004",
+// or exact rational, e.g. "1/3". For invalid value NaN is returned
+func Float(value string) float64 {
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
+	}
+	if r, ok := new(big.Rat).SetString(value); ok {
+		f, _ := r.Float64()
+		return f
+	}
+	return math.NaN()
+}
+
+// FormatFloat formats float without losing precision, so it can be parsed back
+func FormatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// FormatRat formats rational exactly: as integer or decimal if it's finite, otherwise as fraction "p/q"
+func FormatRat(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+
+	// Decimal is finite only if denominator has no prime factors except 2 and 5
+	denom := new(big.Int).Set(r.Denom())
+	digits := 0
+	for _, p := range []int64{2, 5} {
+		prime := big.NewInt(p)
+		count := 0
+		mod := new(big.Int)
+		for {
+			quo, m := new(big.Int).QuoRem(denom, prime, mod)
+			if m.Sign() != 0 {
+				break
+			}
+			denom = quo
+			count++
+		}
+		digits = max(digits, count)
+	}
+	if denom.Cmp(big.NewInt(1)) != 0 {
+		return r.RatString()
+	}
+	return r.FloatString(digits)
+}
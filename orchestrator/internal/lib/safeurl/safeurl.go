@@ -0,0 +1,121 @@
+// Package safeurl keeps requests, which users ask orchestrator to make, away from its own network.
+// Only public addresses are allowed, so callback url can't point to loopback, private network or
+// cloud metadata. Networks from allowlist are allowed too, e.g. for local receiver of webhooks
+package safeurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+var ErrNotPublic = errors.New("address isn't public")
+
+// networks which are global unicast for net/netip, but aren't reachable in internet
+var reserved = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64, it can lead to any IPv4 address
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+type Guard struct {
+	allowed  []netip.Prefix
+	resolver *net.Resolver
+}
+
+// New creates guard, which also allows addresses from allowed. They are networks like "10.0.0.0/8" or single addresses
+func New(allowed []string) (*Guard, error) {
+	const op = "safeurl.New"
+
+	g := &Guard{resolver: net.DefaultResolver}
+	for _, network := range allowed {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(network)
+			if addrErr != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		g.allowed = append(g.allowed, prefix.Masked())
+	}
+	return g, nil
+}
+
+// CheckAddr returns ErrNotPublic, if addr isn't public and isn't allowed
+func (g *Guard) CheckAddr(addr netip.Addr) error {
+	addr = addr.Unmap()
+	for _, prefix := range g.allowed {
+		if prefix.Contains(addr) {
+			return nil
+		}
+	}
+
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return fmt.Errorf("%w: %s", ErrNotPublic, addr)
+	}
+	for _, prefix := range reserved {
+		if prefix.Contains(addr) {
+			return fmt.Errorf("%w: %s", ErrNotPublic, addr)
+		}
+	}
+	return nil
+}
+
+// CheckHost resolves host and checks all its addresses. It's only early check for users,
+// addresses are checked again when connection is made, because host can be resolved differently then
+func (g *Guard) CheckHost(ctx context.Context, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return g.CheckAddr(addr)
+	}
+
+	addrs, err := g.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if err := g.CheckAddr(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Client returns http client, which connects only to allowed addresses and doesn't follow redirects:
+// response with redirect is returned as it is
+func (g *Guard) Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		// address is checked after it's resolved, right before connecting, so DNS rebinding doesn't help
+		Control: func(network, address string, c syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			return g.CheckAddr(addrPort.Addr())
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// proxy would connect instead of us to any address
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,68 @@
+package safeurl
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestCheckAddr(t *testing.T) {
+	guard, err := New([]string{"10.1.0.0/16", "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.2", false},
+		{"::1", false},
+		{"10.2.0.1", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false}, // метаданные облака
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"100.64.0.1", false},
+		{"::ffff:192.168.1.1", false}, // IPv4 внутри IPv6
+		{"64:ff9b::a00:1", false},
+		{"224.0.0.1", false},
+		// из allowlist
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+	}
+	for _, tt := range tests {
+		err := guard.CheckAddr(netip.MustParseAddr(tt.addr))
+		if tt.public && err != nil {
+			t.Errorf("%s: %v", tt.addr, err)
+		}
+		if !tt.public && !errors.Is(err, ErrNotPublic) {
+			t.Errorf("%s: err %v, want %v", tt.addr, err, ErrNotPublic)
+		}
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	guard, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// localhost резолвится без сети
+	if err = guard.CheckHost(context.Background(), "localhost"); !errors.Is(err, ErrNotPublic) {
+		t.Errorf("localhost: err %v, want %v", err, ErrNotPublic)
+	}
+	if err = guard.CheckHost(context.Background(), "169.254.169.254"); !errors.Is(err, ErrNotPublic) {
+		t.Errorf("metadata: err %v, want %v", err, ErrNotPublic)
+	}
+}
+
+func TestNewRejectsInvalidNetwork(t *testing.T) {
+	if _, err := New([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("invalid network is accepted")
+	}
+}
@@ -0,0 +1,68 @@
+package taskgraph
+
+import (
+	"errors"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+)
+
+var ErrInvalidExpression = errors.New("invalid expression")
+
+// node is element of stack while building: either known number or task which result will be operand
+type node struct {
+	value string
+	task  *models.Task
+}
+
+// Build splits expression in postfix notation into dependency graph of tasks: one task for every operator or function.
+// Tasks are returned in postfix order, so every task goes after tasks which results it uses.
+// Tasks with all operands known may be evaluated in parallel, e.g. both additions of (1+2)*(3+4).
+//
+// Id of expression is set to tasks by storage, when expression is saved.
+//
+// If expression has no operators, there is nothing to evaluate: no tasks and value of expression are returned
+func Build(tokens []*models.Token) ([]*models.Task, string, error) {
+	var (
+		tasks []*models.Task
+		stack []node
+	)
+
+	for _, token := range tokens {
+		if token.Type == models.Operand {
+			stack = append(stack, node{value: token.Value})
+			continue
+		}
+
+		if token.Arity < 1 || len(stack) < token.Arity {
+			return nil, "", ErrInvalidExpression
+		}
+		operands := stack[len(stack)-token.Arity:]
+		stack = stack[:len(stack)-token.Arity]
+
+		task := &models.Task{
+			Operation: token.Name,
+			Args:      make([]*string, len(operands)),
+			Status:    models.TaskNew,
+		}
+		for i, operand := range operands {
+			if operand.task != nil {
+				operand.task.Parent = task
+				operand.task.ParentSlot = i
+				continue
+			}
+			value := operand.value
+			task.Args[i] = &value
+		}
+
+		tasks = append(tasks, task)
+		stack = append(stack, node{task: task})
+	}
+
+	if len(stack) != 1 {
+		return nil, "", ErrInvalidExpression
+	}
+	if len(tasks) == 0 {
+		return nil, stack[0].value, nil
+	}
+	return tasks, "", nil
+}
@@ -2,6 +2,9 @@ package httpservice
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -9,13 +12,24 @@ import (
 
 	"github.com/a-romash/grpc-calculator/orchestrator/internal/clients/sso/grpc"
 	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
-	"github.com/jackc/pgx/v5"
+	expressionparser "github.com/a-romash/grpc-calculator/orchestrator/internal/lib/expressionParser"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/lru"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/notify"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/number"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/taskgraph"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/service/hub"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/storage"
 )
 
 type HttpService struct {
-	log     *slog.Logger
-	storage ExpressionStorage
-	client  *grpc.Client
+	log            *slog.Logger
+	storage        ExpressionStorage
+	client         *grpc.Client
+	tasksReady     *notify.Notifier
+	cancelled      *notify.Notifier
+	idempotencyTTL time.Duration
+	results        *lru.Cache[string, models.CachedResult] // recently used results from results cache of storage
+	hub            *hub.Hub
 }
 
 type ExpressionStorage interface {
@@ -30,32 +44,85 @@ type ExpressionStorage interface {
 	GetExpressionsForUser(
 		ctx context.Context,
 		uid int,
-	) ([]models.Expression, error)
+		filter models.ExpressionFilter,
+	) ([]models.Expression, *models.Cursor, error)
 	GetExpressionById(
 		ctx context.Context,
 		id string,
 		uid int,
 	) (*models.Expression, error)
+	CancelExpression(
+		ctx context.Context,
+		id string,
+		uid int,
+	) error
+	GetExpressionEvents(
+		ctx context.Context,
+		id string,
+		uid int,
+	) ([]models.ExpressionEvent, error)
+	GetExpressionSteps(
+		ctx context.Context,
+		id string,
+		uid int,
+	) ([]models.ExpressionStep, error)
 	SaveExpression(
 		ctx context.Context,
 		expression *models.Expression,
 		uid int,
+		key *models.IdempotencyKey,
+	) (string, bool, error)
+	SaveExpressions(
+		ctx context.Context,
+		expressions []*models.Expression,
+		uid int,
+	) error
+	GetExpressionsByIds(
+		ctx context.Context,
+		ids []string,
+		uid int,
+	) ([]models.Expression, error)
+	GetCachedResult(
+		ctx context.Context,
+		key string,
+	) (*models.CachedResult, error)
+	GetWebhookSecret(
+		ctx context.Context,
+		uid int,
 	) (string, error)
-	GetResultOfExpression(
+	RotateWebhookSecret(
 		ctx context.Context,
-		id string,
-	) (float32, error)
+		uid int,
+	) (string, error)
+	GetOperations(
+		ctx context.Context,
+	) ([]models.OperationDuration, error)
+	SetOperationDuration(
+		ctx context.Context,
+		operation string,
+		duration time.Duration,
+	) error
 }
 
 func New(
 	log *slog.Logger,
 	storage ExpressionStorage,
 	client *grpc.Client,
+	tasksReady *notify.Notifier, // notified when new tasks are saved
+	cancelled *notify.Notifier, // notified when expression is cancelled
+	idempotencyTTL time.Duration, // how long idempotency keys of user are kept
+	cacheSize int, // how many results are kept in memory
+	hub *hub.Hub, // updates of expressions for their watchers
 ) *HttpService {
 	return &HttpService{
-		log:     log,
-		storage: storage,
-		client:  client,
+		log:            log,
+		storage:        storage,
+		client:         client,
+		tasksReady:     tasksReady,
+		cancelled:      cancelled,
+		idempotencyTTL: idempotencyTTL,
+		results:        lru.New[string, models.CachedResult](cacheSize),
+		hub:            hub,
 	}
 }
 
@@ -76,7 +143,7 @@ func (s *HttpService) GetExpressionById(
 
 	expression, err := s.storage.GetExpressionById(ctx, id, uid)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
+		if errors.Is(err, storage.ErrNotFound) {
 			return nil, err
 		}
 		log.Error(err.Error())
@@ -88,36 +155,330 @@ func (s *HttpService) GetExpressionById(
 	return expression, err
 }
 
+// CancelExpression stops evaluating of expression of user. Agents which are evaluating its tasks are told to abort them.
+// Returns storage.ErrExpressionFinished, if expression is already completed, failed or cancelled
+func (s *HttpService) CancelExpression(
+	ctx context.Context,
+	id string,
+	uid int,
+) (*models.Expression, error) {
+	const op = "httpservice.CancelExpression"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("id_expression", id),
+		slog.Int("uid", uid),
+	)
+
+	expression, err := s.storage.GetExpressionById(ctx, id, uid)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, err
+		}
+		log.Error(err.Error())
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !expression.Status.CanBecome(models.Cancelled) {
+		return nil, storage.ErrExpressionFinished
+	}
+
+	// expression could finish after it was read, then storage doesn't cancel it either
+	if err = s.storage.CancelExpression(ctx, id, uid); err != nil {
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrExpressionFinished) {
+			return nil, err
+		}
+		log.Error(err.Error())
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	s.cancelled.Notify()
+
+	log.Info("expression was cancelled")
+	expression.Status = models.Cancelled
+	return expression, nil
+}
+
+// GetExpressionEvents returns history of statuses of expression
+func (s *HttpService) GetExpressionEvents(
+	ctx context.Context,
+	id string,
+	uid int,
+) ([]models.ExpressionEvent, error) {
+	const op = "httpservice.GetExpressionEvents"
+
+	events, err := s.storage.GetExpressionEvents(ctx, id, uid)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, err
+		}
+		s.log.Error(err.Error(), slog.String("op", op))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return events, nil
+}
+
+// GetExpressionTrace returns operations which agents performed to evaluate expression, in order they were finished
+func (s *HttpService) GetExpressionTrace(
+	ctx context.Context,
+	id string,
+	uid int,
+) ([]models.ExpressionStep, error) {
+	const op = "httpservice.GetExpressionTrace"
+
+	steps, err := s.storage.GetExpressionSteps(ctx, id, uid)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, err
+		}
+		s.log.Error(err.Error(), slog.String("op", op))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return steps, nil
+}
+
+// WatchExpression subscribes to updates of expression of user and returns its history of statuses.
+// Subscription is made before history is read, so transitions made meanwhile may be in both
+func (s *HttpService) WatchExpression(
+	ctx context.Context,
+	id string,
+	uid int,
+) ([]models.ExpressionEvent, *hub.Subscription, error) {
+	const op = "httpservice.WatchExpression"
+
+	subscription := s.hub.Subscribe(id)
+	events, err := s.GetExpressionEvents(ctx, id, uid)
+	if err != nil {
+		subscription.Close()
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return events, subscription, nil
+}
+
+// EvaluateExpression saves expression for further evaluation by agents and returns it with generated id.
+// It doesn't wait for result: caller should poll status of expression by returned id.
+//
+// If idempotencyKey isn't empty and user already submitted the same expression with it,
+// that expression is returned with replayed = true. Submitting another expression with used key
+// returns storage.ErrIdempotencyKeyUsed
 func (s *HttpService) EvaluateExpression(
 	ctx context.Context,
 	expression *models.Expression,
 	uid int,
-) (float32, error) {
+	idempotencyKey string,
+) (*models.Expression, bool, error) {
 	const op = "httpservice.EvaluateExpression"
 
 	log := s.log.With(
 		slog.String("op", op),
-		slog.String("id_expression", expression.IdExpression),
 		slog.Int("uid", uid),
 	)
 
-	log.Info("start evaluating expression")
-	id, err := s.storage.SaveExpression(ctx, expression, uid)
+	log.Info("start saving expression")
+
+	if err := s.prepare(ctx, expression); err != nil {
+		log.Error(err.Error())
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var key *models.IdempotencyKey
+	if idempotencyKey != "" {
+		// The same expression with other values of variables is another request
+		request, err := json.Marshal(struct {
+			Expression string             `json:"expression"`
+			Variables  map[string]float64 `json:"variables"`
+			Precision  models.Precision   `json:"precision"`
+			Priority   int                `json:"priority"`
+			Timeout    time.Duration      `json:"timeout"`
+			Callback   *string            `json:"callback"`
+		}{expression.InfinixExpression, expression.Variables, expression.Precision, expression.Priority, expression.Timeout, expression.CallbackUrl})
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", op, err)
+		}
+		hash := sha256.Sum256(request)
+		key = &models.IdempotencyKey{
+			Key:         idempotencyKey,
+			RequestHash: hex.EncodeToString(hash[:]),
+			TTL:         s.idempotencyTTL,
+		}
+	}
+
+	id, replayed, err := s.storage.SaveExpression(ctx, expression, uid, key)
 	if err != nil {
+		if errors.Is(err, storage.ErrIdempotencyKeyUsed) {
+			log.Warn(err.Error())
+			return nil, false, err
+		}
 		log.Error(err.Error())
-		return 0, err
+		return nil, false, fmt.Errorf("%s: %w", op, err)
 	}
-	var result float32
-	for {
-		result, err = s.storage.GetResultOfExpression(ctx, id)
-		if err == nil {
-			break
+	log = log.With(slog.String("id_expression", id))
+
+	if replayed {
+		// Client repeats request, so it gets the same expression in its current state
+		log.Info("expression was already submitted with this idempotency key")
+		expression, err = s.storage.GetExpressionById(ctx, id, uid)
+		if err != nil {
+			log.Error(err.Error())
+			return nil, false, fmt.Errorf("%s: %w", op, err)
 		}
+		return expression, true, nil
+	}
 
-		time.Sleep(10 * time.Second)
+	if len(expression.Tasks) > 0 {
+		s.tasksReady.Notify()
 	}
-	log.Info("expression evaluated!")
-	return result, nil
+
+	log.Info("expression accepted for evaluation")
+	return expression, false, nil
+}
+
+// EvaluateExpressions saves expressions of batch for further evaluation, like EvaluateExpression does.
+// Expressions which can't be split into tasks get their error in errs with the same index,
+// all other ones are saved in one transaction and get generated ids
+func (s *HttpService) EvaluateExpressions(
+	ctx context.Context,
+	expressions []*models.Expression,
+	uid int,
+) (errs []error, err error) {
+	const op = "httpservice.EvaluateExpressions"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int("uid", uid),
+		slog.Int("count", len(expressions)),
+	)
+
+	errs = make([]error, len(expressions))
+	valid := make([]*models.Expression, 0, len(expressions))
+	hasTasks := false
+	for i, expression := range expressions {
+		if errs[i] = s.prepare(ctx, expression); errs[i] != nil {
+			continue
+		}
+		valid = append(valid, expression)
+		hasTasks = hasTasks || len(expression.Tasks) > 0
+	}
+
+	if len(valid) > 0 {
+		if err = s.storage.SaveExpressions(ctx, valid, uid); err != nil {
+			log.Error(err.Error())
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if hasTasks {
+		s.tasksReady.Notify()
+	}
+
+	log.Info("batch accepted for evaluation", slog.Int("saved", len(valid)))
+	return errs, nil
+}
+
+// prepare splits expression into tasks which agents evaluate independently.
+// Expression without operations or with cached result gets its result at once
+func (s *HttpService) prepare(ctx context.Context, expression *models.Expression) error {
+	tasks, value, err := taskgraph.Build(expression.PostfixExpression)
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		result := number.Float(value)
+		expression.Result = &result
+		if expression.Precision == models.PrecisionExact {
+			expression.ExactResult = &value
+		}
+		return nil
+	}
+	expression.Tasks = tasks
+
+	normalized, err := expressionparser.Normalize(expression.PostfixExpression, expression.Precision)
+	if err != nil {
+		// expression is still evaluated, just without cache
+		s.log.Warn("expression can't be normalized", slog.String("expression", expression.InfinixExpression))
+		return nil
+	}
+	hash := sha256.Sum256([]byte(string(expression.Precision) + ":" + normalized))
+	key := hex.EncodeToString(hash[:])
+	expression.CacheKey = &key
+
+	if cached, ok := s.cachedResult(ctx, key); ok {
+		expression.Tasks = nil
+		expression.Result = &cached.Result
+		expression.ExactResult = cached.ExactResult
+	}
+	return nil
+}
+
+// cachedResult looks for result in memory and then in storage. Errors of storage are only logged:
+// without cache expression is just evaluated again
+func (s *HttpService) cachedResult(ctx context.Context, key string) (models.CachedResult, bool) {
+	if cached, ok := s.results.Get(key); ok {
+		return cached, true
+	}
+
+	cached, err := s.storage.GetCachedResult(ctx, key)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			s.log.Error(err.Error())
+		}
+		return models.CachedResult{}, false
+	}
+	s.results.Add(key, *cached)
+	return *cached, true
+}
+
+// GetExpressionsByIds returns expressions of user by ids, those which aren't found are missing in map
+func (s *HttpService) GetExpressionsByIds(
+	ctx context.Context,
+	ids []string,
+	uid int,
+) (map[string]*models.Expression, error) {
+	const op = "httpservice.GetExpressionsByIds"
+
+	expressions, err := s.storage.GetExpressionsByIds(ctx, ids, uid)
+	if err != nil {
+		s.log.Error(err.Error(), slog.String("op", op))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	found := make(map[string]*models.Expression, len(expressions))
+	for i := range expressions {
+		found[expressions[i].IdExpression] = &expressions[i]
+	}
+	return found, nil
+}
+
+// GetWebhookSecret returns secret, which webhooks of user are signed with
+func (s *HttpService) GetWebhookSecret(
+	ctx context.Context,
+	uid int,
+) (string, error) {
+	const op = "httpservice.GetWebhookSecret"
+
+	secret, err := s.storage.GetWebhookSecret(ctx, uid)
+	if err != nil {
+		s.log.Error(err.Error(), slog.String("op", op))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return secret, nil
+}
+
+// RotateWebhookSecret replaces secret of user with new one
+func (s *HttpService) RotateWebhookSecret(
+	ctx context.Context,
+	uid int,
+) (string, error) {
+	const op = "httpservice.RotateWebhookSecret"
+
+	secret, err := s.storage.RotateWebhookSecret(ctx, uid)
+	if err != nil {
+		s.log.Error(err.Error(), slog.String("op", op))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.log.Info("webhook secret was rotated", slog.String("op", op), slog.Int("uid", uid))
+	return secret, nil
 }
 
 func (s *HttpService) Login(
@@ -168,10 +529,12 @@ func (s *HttpService) Register(
 	return id, nil
 }
 
+// GetExpressionsForUser returns page of expressions of user and cursor of the next page, nil for the last one
 func (s *HttpService) GetExpressionsForUser(
 	ctx context.Context,
 	uid int,
-) ([]models.Expression, error) {
+	filter models.ExpressionFilter,
+) ([]models.Expression, *models.Cursor, error) {
 	const op = "httpservice.GetExpressionsForUser"
 
 	log := s.log.With(
@@ -181,14 +544,14 @@ func (s *HttpService) GetExpressionsForUser(
 
 	log.Info("start getting expressions")
 
-	expressions, err := s.storage.GetExpressionsForUser(ctx, uid)
+	expressions, next, err := s.storage.GetExpressionsForUser(ctx, uid, filter)
 	if err != nil {
 		log.Error(err.Error())
-		return nil, err
+		return nil, nil, err
 	}
 
 	log.Info("getting expressions was succesful!")
-	return expressions, nil
+	return expressions, next, nil
 }
 
 func (s *HttpService) GetAgentStates(
@@ -211,3 +574,53 @@ func (s *HttpService) GetAgentStates(
 	log.Info("getting agents was succesful!")
 	return agent, nil
 }
+
+func (s *HttpService) GetOperations(
+	ctx context.Context,
+) ([]models.OperationDuration, error) {
+	const op = "httpservice.GetOperations"
+
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("start getting operations")
+
+	operations, err := s.storage.GetOperations(ctx)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("getting operations was succesful!")
+	return operations, nil
+}
+
+// SetOperationDuration changes duration of operation for all agents, they get it with every task
+func (s *HttpService) SetOperationDuration(
+	ctx context.Context,
+	operation string,
+	duration time.Duration,
+) error {
+	const op = "httpservice.SetOperationDuration"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("operation", operation),
+		slog.Duration("duration", duration),
+	)
+
+	log.Info("start setting duration of operation")
+
+	err := s.storage.SetOperationDuration(ctx, operation, duration)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		log.Error(err.Error())
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("setting duration of operation was succesful!")
+	return nil
+}
@@ -0,0 +1,130 @@
+package hub
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/logger/sl"
+)
+
+// how many updates subscriber may not have read yet, slower one is unsubscribed
+const bufferSize = 64
+
+// Hub delivers updates of expressions from storage to their watchers
+type Hub struct {
+	log         *slog.Logger
+	storage     UpdatesStorage
+	mu          sync.Mutex
+	subscribers map[string]map[*Subscription]struct{} // id of expression -> its watchers
+}
+
+type UpdatesStorage interface {
+	ListenExpressionUpdates(
+		ctx context.Context,
+		update func(models.ExpressionUpdate),
+	) error
+}
+
+// Subscription is watcher of one expression
+type Subscription struct {
+	// Updates are closed, when subscription is closed or when watcher is too slow to read them
+	Updates <-chan models.ExpressionUpdate
+
+	updates chan models.ExpressionUpdate
+	hub     *Hub
+	id      string
+}
+
+func New(log *slog.Logger, storage UpdatesStorage) *Hub {
+	return &Hub{
+		log:         log,
+		storage:     storage,
+		subscribers: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// Run listens for updates of expressions and publishes them. If connection is lost,
+// it's opened again after retryInterval. Blocks until ctx is done
+func (h *Hub) Run(ctx context.Context, retryInterval time.Duration) {
+	const op = "hub.Run"
+
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	for {
+		if err := h.storage.ListenExpressionUpdates(ctx, h.Publish); err != nil {
+			log.Error("failed to listen for updates of expressions", sl.Err(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// Subscribe starts watching expression with id. Subscription should be closed, when it isn't needed anymore
+func (h *Hub) Subscribe(id string) *Subscription {
+	updates := make(chan models.ExpressionUpdate, bufferSize)
+	s := &Subscription{
+		Updates: updates,
+		updates: updates,
+		hub:     h,
+		id:      id,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subscribers[id] == nil {
+		h.subscribers[id] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[id][s] = struct{}{}
+	return s
+}
+
+// Publish gives update to every watcher of its expression without waiting for them
+func (h *Hub) Publish(update models.ExpressionUpdate) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subscribers[update.IdExpression] {
+		select {
+		case s.updates <- update:
+		default:
+			// watcher can read missed transitions from history, when it's subscribed again
+			h.log.Warn("watcher of expression is too slow", slog.String("id_expression", update.IdExpression))
+			h.remove(s)
+		}
+	}
+}
+
+// Close stops watching. It can be called more than once
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.remove(s)
+}
+
+// remove unsubscribes s and closes its updates, hub should be locked
+func (h *Hub) remove(s *Subscription) {
+	subscribers, ok := h.subscribers[s.id]
+	if !ok {
+		return
+	}
+	if _, ok = subscribers[s]; !ok {
+		return
+	}
+
+	delete(subscribers, s)
+	if len(subscribers) == 0 {
+		delete(h.subscribers, s.id)
+	}
+	close(s.updates)
+}
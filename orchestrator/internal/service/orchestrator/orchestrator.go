@@ -2,16 +2,25 @@ package orch
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
-	shuntingYard "github.com/a-romash/go-shunting-yard"
 	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/notify"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/storage"
 )
 
 type Orchestrator struct {
-	log     *slog.Logger
-	storage ExpressionStorage
+	log          *slog.Logger
+	storage      ExpressionStorage
+	agentTimeout time.Duration
+	maxRequeues  int
+	maxRunning   int
+	scheduler    *scheduler
+	tasksReady   *notify.Notifier
+	cancelled    *notify.Notifier
 }
 
 type ExpressionStorage interface {
@@ -23,31 +32,107 @@ type ExpressionStorage interface {
 		ctx context.Context,
 		id_agent int,
 	) error
-	GetExpressionToEvaluate(
+	GetReadyQueues(
+		ctx context.Context,
+		maxRunning int,
+	) ([]models.Queue, error)
+	GetTask(
 		ctx context.Context,
 		id_agent int,
-	) (*models.Expression, error)
-	SaveResult(
+		queue models.Queue,
+		maxRunning int,
+	) (*models.Task, error)
+	SaveTaskResult(
 		ctx context.Context,
-		id_expression string,
-		result float32,
+		idTask int,
+		result string,
+		idAgent int,
+	) error
+	StartTask(
+		ctx context.Context,
+		idTask int,
+		idAgent int,
+	) error
+	SaveTaskError(
+		ctx context.Context,
+		idTask int,
+		taskErr models.ExpressionError,
 		idAgent int,
 	) error
 	RegisterNewAgent(
 		ctx context.Context,
 	) (int, error)
+	RequeueStaleTasks(
+		ctx context.Context,
+		timeout time.Duration,
+		maxRequeues int,
+	) (int, error)
+	RequeueAgentTasks(
+		ctx context.Context,
+		id_agent int,
+	) error
+	TakeCancelledTasks(
+		ctx context.Context,
+		idAgent int,
+	) ([]int, error)
+	TimeOutExpressions(
+		ctx context.Context,
+	) (int, error)
+	SaveProgress(
+		ctx context.Context,
+		progress models.ExpressionProgress,
+	) error
 }
 
+// New creates orchestrator service.
+// Agent without heartbeat for agentTimeout is considered dead, its tasks are given to other agents.
+// Expression which tasks were taken back more than maxRequeues times is failed.
+// User can have at most maxRunning scheduled or running expressions at once, 0 is no limit.
+// tasksReady is notified every time some task may become ready for evaluating,
+// cancelled - every time some expression is cancelled
 func New(
 	log *slog.Logger,
 	storage ExpressionStorage,
+	agentTimeout time.Duration,
+	maxRequeues int,
+	maxRunning int,
+	tasksReady *notify.Notifier,
+	cancelled *notify.Notifier,
 ) *Orchestrator {
 	return &Orchestrator{
-		log:     log,
-		storage: storage,
+		log:          log,
+		storage:      storage,
+		agentTimeout: agentTimeout,
+		maxRequeues:  maxRequeues,
+		maxRunning:   maxRunning,
+		scheduler:    newScheduler(),
+		tasksReady:   tasksReady,
+		cancelled:    cancelled,
 	}
 }
 
+// TasksReady returns channel which is closed when new tasks may be ready for evaluating
+func (o *Orchestrator) TasksReady() <-chan struct{} {
+	return o.tasksReady.Wait()
+}
+
+// Cancelled returns channel which is closed when some expression is cancelled
+func (o *Orchestrator) Cancelled() <-chan struct{} {
+	return o.cancelled.Wait()
+}
+
+// TakeCancelledTasks returns tasks of cancelled expressions, which agent should abort
+func (o *Orchestrator) TakeCancelledTasks(ctx context.Context, idAgent int) ([]int, error) {
+	const op = "Orch.TakeCancelledTasks"
+
+	cancelled, err := o.storage.TakeCancelledTasks(ctx, idAgent)
+	if err != nil {
+		o.log.Error(err.Error() + ". op: " + op)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return cancelled, nil
+}
+
 func (o *Orchestrator) Heartbeat(
 	ctx context.Context,
 	is_alive bool,
@@ -70,33 +155,132 @@ func (o *Orchestrator) Heartbeat(
 	return nil
 }
 
-func (o *Orchestrator) GetExpressionToEvaluate(
+// GetTask returns task ready for evaluating and assigns it to agent.
+// Tasks of higher priority are given first, users with the same priority take turns.
+// If there is no ready task, returns nil task and nil error
+func (o *Orchestrator) GetTask(
 	ctx context.Context,
 	id_agent int,
-) (string, []*shuntingYard.RPNToken, error) {
-	const op = "Orch.GetExpressionToEvaluate"
+) (*models.Task, error) {
+	const op = "Orch.GetTask"
 
-	expression, err := o.storage.GetExpressionToEvaluate(ctx, id_agent)
+	queues, err := o.storage.GetReadyQueues(ctx, o.maxRunning)
 	if err != nil {
 		o.log.Error(err.Error())
-		return "", []*shuntingYard.RPNToken{}, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, queue := range o.scheduler.order(queues) {
+		task, err := o.storage.GetTask(ctx, id_agent, queue, o.maxRunning)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				// other agents have taken tasks of queue meanwhile
+				continue
+			}
+			o.log.Error(err.Error())
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		o.scheduler.served(queue)
+		return task, nil
+	}
+
+	return nil, nil
+}
+
+// StartTask is called when agent acknowledges task: expression becomes running
+func (o *Orchestrator) StartTask(ctx context.Context, idTask int, idAgent int) error {
+	const op = "Orch.StartTask"
+
+	if err := o.storage.StartTask(ctx, idTask, idAgent); err != nil {
+		o.log.Error(err.Error() + ". op: " + op)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// SaveResultOfTask saves result of task: float or exact rational, see models.PrecisionExact
+func (o *Orchestrator) SaveResultOfTask(
+	ctx context.Context,
+	idTask int,
+	result string,
+	idAgent int,
+) error {
+	const op = "Orch.SaveResultOfTask"
+
+	if err := o.storage.SaveTaskResult(ctx, idTask, result, idAgent); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			// agent was considered dead and task was given to another one
+			o.log.Warn("stale result of task is ignored", slog.Int("id_task", idTask), slog.Int("id_agent", idAgent))
+			return nil
+		}
+		o.log.Error(err.Error() + ". op: " + op)
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	return expression.IdExpression, expression.PostfixExpression, nil
+	// task which waited for this result may be ready now
+	o.tasksReady.Notify()
+	return nil
+}
+
+// SaveProgressOfTask saves operation of task, which agent has evaluated, to trace of expression and tells its watchers.
+// Progress of tasks which aren't given to agent anymore is ignored
+func (o *Orchestrator) SaveProgressOfTask(
+	ctx context.Context,
+	progress models.ExpressionProgress,
+) error {
+	const op = "Orch.SaveProgressOfTask"
+
+	if err := o.storage.SaveProgress(ctx, progress); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			o.log.Warn("stale progress of task is ignored", slog.Int("id_task", progress.IdTask), slog.Int("id_agent", progress.IdAgent))
+			return nil
+		}
+		o.log.Error(err.Error() + ". op: " + op)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
 }
 
-func (o *Orchestrator) SaveResultOfExpression(
+// SaveErrorOfTask fails expression, which task agent couldn't evaluate, e.g. because of division by zero
+func (o *Orchestrator) SaveErrorOfTask(
 	ctx context.Context,
-	id_expression string,
-	result float32,
+	idTask int,
+	code string,
+	message string,
 	idAgent int,
 ) error {
-	const op = "Orch.SaveResult"
+	const op = "Orch.SaveErrorOfTask"
+
+	taskErr := models.ExpressionError{Code: code, Message: message}
+	if err := o.storage.SaveTaskError(ctx, idTask, taskErr, idAgent); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			o.log.Warn("stale error of task is ignored", slog.Int("id_task", idTask), slog.Int("id_agent", idAgent))
+			return nil
+		}
+		o.log.Error(err.Error() + ". op: " + op)
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
-	if err := o.storage.SaveResult(ctx, id_expression, result, idAgent); err != nil {
+	// agent has free calculator now
+	o.tasksReady.Notify()
+	if code == models.ErrorDeadlineExceeded {
+		// other tasks of timed out expression are aborted
+		o.cancelled.Notify()
+	}
+	return nil
+}
+
+// ReleaseAgentTasks returns unfinished tasks of agent back to queue
+func (o *Orchestrator) ReleaseAgentTasks(ctx context.Context, idAgent int) error {
+	const op = "Orch.ReleaseAgentTasks"
+
+	if err := o.storage.RequeueAgentTasks(ctx, idAgent); err != nil {
 		o.log.Error(err.Error() + ". op: " + op)
 		return fmt.Errorf("%s: %w", op, err)
 	}
+
+	o.tasksReady.Notify()
 	return nil
 }
 
@@ -132,6 +316,7 @@ func (o *Orchestrator) RemoveAgent(ctx context.Context, idAgent int) error {
 		log.Error("caused error: " + err.Error())
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	o.tasksReady.Notify()
 	log.Info("removing was succesful!")
 	return nil
 }
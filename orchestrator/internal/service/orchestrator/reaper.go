@@ -0,0 +1,73 @@
+package orch
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/logger/sl"
+)
+
+// RunReaper every interval looks for agents which stopped heartbeating and returns their tasks to queue.
+// Blocks until ctx is done
+func (o *Orchestrator) RunReaper(ctx context.Context, interval time.Duration) {
+	const op = "Orch.RunReaper"
+
+	log := o.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("reaper started", slog.Duration("interval", interval), slog.Duration("agent_timeout", o.agentTimeout))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("reaper stopped")
+			return
+		case <-ticker.C:
+			count, err := o.storage.RequeueStaleTasks(ctx, o.agentTimeout, o.maxRequeues)
+			if err != nil {
+				log.Error("failed to requeue stale tasks", sl.Err(err))
+				continue
+			}
+			if count > 0 {
+				log.Info("stale tasks requeued", slog.Int("count", count))
+				o.tasksReady.Notify()
+			}
+		}
+	}
+}
+
+// RunDeadlines every interval times out expressions, which weren't completed before their deadline.
+// Blocks until ctx is done
+func (o *Orchestrator) RunDeadlines(ctx context.Context, interval time.Duration) {
+	const op = "Orch.RunDeadlines"
+
+	log := o.log.With(
+		slog.String("op", op),
+	)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := o.storage.TimeOutExpressions(ctx)
+			if err != nil {
+				log.Error("failed to time out expressions", sl.Err(err))
+				continue
+			}
+			if count > 0 {
+				log.Info("expressions timed out", slog.Int("count", count))
+				// agents abort tasks of timed out expressions and take other ones
+				o.cancelled.Notify()
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package orch
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+)
+
+// scheduler chooses queue, which next task is taken from. Queues with higher priority go first,
+// users with the same priority take turns, so one user with lots of expressions doesn't starve others
+type scheduler struct {
+	mu   sync.Mutex
+	last map[int]int // priority -> user, who got task with this priority last
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{
+		last: make(map[int]int),
+	}
+}
+
+// order returns queues in order they should be tried: by priority,
+// and within one priority from the user next to the last served one
+func (s *scheduler) order(queues []models.Queue) []models.Queue {
+	ordered := make([]models.Queue, len(queues))
+	copy(ordered, queues)
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].Priority != ordered[j].Priority {
+			return ordered[i].Priority > ordered[j].Priority
+		}
+		return ordered[i].Uid < ordered[j].Uid
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for start := 0; start < len(ordered); {
+		end := start
+		for end < len(ordered) && ordered[end].Priority == ordered[start].Priority {
+			end++
+		}
+
+		level := ordered[start:end]
+		if last, ok := s.last[level[0].Priority]; ok {
+			// users after the last served one go first, then the rest from the beginning
+			next := sort.Search(len(level), func(i int) bool { return level[i].Uid > last })
+			rotated := append(append([]models.Queue{}, level[next:]...), level[:next]...)
+			copy(level, rotated)
+		}
+		start = end
+	}
+	return ordered
+}
+
+// served remembers that user got task from queue
+func (s *scheduler) served(queue models.Queue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.last[queue.Priority] = queue.Uid
+}
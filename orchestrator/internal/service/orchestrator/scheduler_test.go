@@ -0,0 +1,83 @@
+package orch
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+)
+
+func TestSchedulerOrder(t *testing.T) {
+	queues := []models.Queue{{Uid: 1, Priority: 0}, {Uid: 2, Priority: 5}, {Uid: 3, Priority: -1}, {Uid: 4, Priority: 5}}
+
+	var got []int
+	for _, queue := range newScheduler().order(queues) {
+		got = append(got, queue.Uid)
+	}
+	if want := []int{2, 4, 1, 3}; !slices.Equal(got, want) {
+		t.Errorf("order is %v; want %v", got, want)
+	}
+}
+
+// В каждом раунде агент берёт задачу из первой очереди, которую вернул order
+func TestSchedulerServes(t *testing.T) {
+	low := func(uids ...int) []models.Queue {
+		queues := make([]models.Queue, 0, len(uids))
+		for _, uid := range uids {
+			queues = append(queues, models.Queue{Uid: uid})
+		}
+		return queues
+	}
+	withHigh := func(uid int, queues []models.Queue) []models.Queue {
+		return append([]models.Queue{{Uid: uid, Priority: 5}}, queues...)
+	}
+
+	tests := []struct {
+		name   string
+		rounds [][]models.Queue
+		want   []int // кто получал задачу в каждом раунде
+	}{
+		{
+			name:   "users with the same priority take turns",
+			rounds: [][]models.Queue{low(1, 2, 3), low(1, 2, 3), low(1, 2, 3), low(1, 2, 3), low(1, 2, 3)},
+			want:   []int{1, 2, 3, 1, 2},
+		},
+		{
+			name:   "queues aren't sorted by storage",
+			rounds: [][]models.Queue{low(3, 1, 2), low(2, 3, 1), low(1, 3, 2)},
+			want:   []int{1, 2, 3},
+		},
+		{
+			name:   "priority wins over turns",
+			rounds: [][]models.Queue{withHigh(3, low(1, 2)), withHigh(3, low(1, 2)), withHigh(3, low(1, 2))},
+			want:   []int{3, 3, 3},
+		},
+		{
+			name:   "turns of lower priority go on after higher one is served",
+			rounds: [][]models.Queue{low(1, 2, 3), withHigh(4, low(1, 2, 3)), low(1, 2, 3), low(1, 2, 3)},
+			want:   []int{1, 4, 2, 3},
+		},
+		{
+			name:   "user, who was served last, has no more tasks",
+			rounds: [][]models.Queue{low(1, 2, 3), low(1, 2, 3), low(1, 3), low(1, 3)},
+			want:   []int{1, 2, 3, 1},
+		},
+		{
+			name:   "new user gets turn after the last served one",
+			rounds: [][]models.Queue{low(1, 3), low(1, 2, 3), low(1, 2, 3)},
+			want:   []int{1, 2, 3},
+		},
+	}
+	for _, tt := range tests {
+		s := newScheduler()
+		var got []int
+		for _, queues := range tt.rounds {
+			first := s.order(queues)[0]
+			s.served(first)
+			got = append(got, first.Uid)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: served %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/config"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/logger/sl"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/safeurl"
+)
+
+// Headers of webhook request
+const (
+	HeaderId        = "X-Webhook-Id"        // id of delivery, it's the same when webhook is retried
+	HeaderTimestamp = "X-Webhook-Timestamp" // unix seconds, when request was signed
+	HeaderSignature = "X-Webhook-Signature" // "sha256=" and hex of HMAC, see Sign
+)
+
+// how many webhooks are sent at once
+const batchSize = 16
+
+// Sender sends webhooks about finished expressions. Failed ones are retried with exponential backoff,
+// and after maxAttempts they are moved to dead letters
+type Sender struct {
+	log         *slog.Logger
+	storage     WebhookStorage
+	client      *http.Client
+	timeout     time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+}
+
+type WebhookStorage interface {
+	TakeWebhooks(
+		ctx context.Context,
+		limit int,
+		lease time.Duration,
+	) ([]models.Webhook, error)
+	DeleteWebhook(
+		ctx context.Context,
+		id int,
+	) error
+	RetryWebhook(
+		ctx context.Context,
+		id int,
+		lastError string,
+		delay time.Duration,
+	) error
+	FailWebhook(
+		ctx context.Context,
+		id int,
+		lastError string,
+	) error
+}
+
+// New creates sender, which sends webhooks only to addresses allowed by guard
+func New(log *slog.Logger, storage WebhookStorage, cfg config.WebhooksConfig, guard *safeurl.Guard) *Sender {
+	return &Sender{
+		log:         log,
+		storage:     storage,
+		client:      guard.Client(cfg.Timeout),
+		timeout:     cfg.Timeout,
+		maxAttempts: cfg.MaxAttempts,
+		backoff:     cfg.Backoff,
+		maxBackoff:  cfg.MaxBackoff,
+	}
+}
+
+// Run every interval sends webhooks which are due. Blocks until ctx is done
+func (s *Sender) Run(ctx context.Context, interval time.Duration) {
+	const op = "webhook.Run"
+
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.SendDue(ctx); err != nil {
+				log.Error("failed to send webhooks", sl.Err(err))
+			}
+		}
+	}
+}
+
+// SendDue sends webhooks which are due, until there are no more of them
+func (s *Sender) SendDue(ctx context.Context) error {
+	const op = "webhook.SendDue"
+
+	for {
+		// webhook isn't taken by others, while its request can still be in progress
+		webhooks, err := s.storage.TakeWebhooks(ctx, batchSize, 2*s.timeout)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		var wg sync.WaitGroup
+		for _, w := range webhooks {
+			wg.Add(1)
+			go func(w models.Webhook) {
+				defer wg.Done()
+				s.send(ctx, w)
+			}(w)
+		}
+		wg.Wait()
+
+		if len(webhooks) < batchSize {
+			return nil
+		}
+	}
+}
+
+// send makes one attempt to deliver webhook and saves its outcome
+func (s *Sender) send(ctx context.Context, w models.Webhook) {
+	log := s.log.With(
+		slog.Int("id_webhook", w.ID),
+		slog.String("id_expression", w.Payload.ID),
+		slog.Int("attempt", w.Attempts+1),
+	)
+
+	sendErr := s.post(ctx, w)
+	if sendErr == nil {
+		log.Info("webhook delivered")
+		if err := s.storage.DeleteWebhook(ctx, w.ID); err != nil {
+			log.Error("failed to delete webhook", sl.Err(err))
+		}
+		return
+	}
+
+	if w.Attempts+1 >= s.maxAttempts {
+		log.Warn("webhook isn't delivered, giving up", sl.Err(sendErr))
+		if err := s.storage.FailWebhook(ctx, w.ID, sendErr.Error()); err != nil {
+			log.Error("failed to move webhook to dead letters", sl.Err(err))
+		}
+		return
+	}
+
+	delay := s.delay(w.Attempts)
+	log.Info("webhook isn't delivered, will retry", sl.Err(sendErr), slog.Duration("delay", delay))
+	if err := s.storage.RetryWebhook(ctx, w.ID, sendErr.Error(), delay); err != nil {
+		log.Error("failed to retry webhook", sl.Err(err))
+	}
+}
+
+// delay returns how long to wait after attempt number attempts+1 failed
+func (s *Sender) delay(attempts int) time.Duration {
+	delay := s.backoff
+	for i := 0; i < attempts && delay < s.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, s.maxBackoff)
+}
+
+// post sends signed payload. Only 2xx response means that webhook is delivered
+func (s *Sender) post(ctx context.Context, w models.Webhook) error {
+	body, err := json.Marshal(w.Payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderId, strconv.Itoa(w.ID))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, "sha256="+Sign(w.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns hex of HMAC-SHA256 of "<timestamp>.<body>" with secret of user.
+// Timestamp is signed too, so receiver can reject old requests which are replayed
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
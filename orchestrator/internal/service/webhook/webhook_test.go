@@ -0,0 +1,224 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/config"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/safeurl"
+)
+
+// fakeStorage хранит вебхуки в памяти, как их хранила бы таблица webhooks
+type fakeStorage struct {
+	mu       sync.Mutex
+	pending  []models.Webhook
+	retried  map[int]time.Duration // id -> задержка до следующей попытки
+	dead     map[int]string        // id -> последняя ошибка
+	lastErrs map[int]string
+}
+
+func newFakeStorage(webhooks ...models.Webhook) *fakeStorage {
+	return &fakeStorage{
+		pending:  webhooks,
+		retried:  make(map[int]time.Duration),
+		dead:     make(map[int]string),
+		lastErrs: make(map[int]string),
+	}
+}
+
+func (f *fakeStorage) TakeWebhooks(ctx context.Context, limit int, lease time.Duration) ([]models.Webhook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := min(limit, len(f.pending))
+	taken := f.pending[:n]
+	f.pending = f.pending[n:]
+	return taken, nil
+}
+
+func (f *fakeStorage) DeleteWebhook(ctx context.Context, id int) error {
+	return nil
+}
+
+func (f *fakeStorage) RetryWebhook(ctx context.Context, id int, lastError string, delay time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.retried[id] = delay
+	f.lastErrs[id] = lastError
+	return nil
+}
+
+func (f *fakeStorage) FailWebhook(ctx context.Context, id int, lastError string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.dead[id] = lastError
+	return nil
+}
+
+// newTestSender создаёт отправителя, которому можно слать вебхуки в allowed. Получатели из httptest слушают loopback
+func newTestSender(t *testing.T, storage WebhookStorage, allowed ...string) *Sender {
+	t.Helper()
+
+	guard, err := safeurl.New(allowed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return New(log, storage, config.WebhooksConfig{Timeout: time.Second, MaxAttempts: 3, Backoff: time.Second, MaxBackoff: 3 * time.Second}, guard)
+}
+
+func testWebhook(id int, url string, attempts int) models.Webhook {
+	result := 3.0
+	solved := time.Now()
+	return models.Webhook{
+		ID:       id,
+		Url:      url,
+		Attempts: attempts,
+		Secret:   "secret",
+		Payload: models.WebhookPayload{
+			ID:         "expression-" + strconv.Itoa(id),
+			Status:     models.Completed,
+			Result:     &result,
+			CreatedAt:  solved.Add(-time.Second),
+			SolvedAt:   &solved,
+			FinishedAt: solved,
+		},
+	}
+}
+
+func TestSendSignedWebhook(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan received, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- received{r.Header, body}
+	}))
+	defer receiver.Close()
+
+	storage := newFakeStorage(testWebhook(1, receiver.URL, 0))
+	if err := newTestSender(t, storage, "127.0.0.0/8").SendDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-requests
+	if req.header.Get(HeaderId) != "1" {
+		t.Errorf("%s is %q; want 1", HeaderId, req.header.Get(HeaderId))
+	}
+	timestamp, err := strconv.ParseInt(req.header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("%s: %v", HeaderTimestamp, err)
+	}
+	// получатель проверяет подпись тем же секретом
+	if want := "sha256=" + Sign("secret", timestamp, req.body); req.header.Get(HeaderSignature) != want {
+		t.Errorf("signature is %q; want %q", req.header.Get(HeaderSignature), want)
+	}
+
+	var payload models.WebhookPayload
+	if err = json.Unmarshal(req.body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.ID != "expression-1" || payload.Status != models.Completed || payload.Result == nil || *payload.Result != 3 {
+		t.Errorf("payload is %+v", payload)
+	}
+
+	if len(storage.retried) != 0 || len(storage.dead) != 0 {
+		t.Errorf("delivered webhook is retried %v or dead %v", storage.retried, storage.dead)
+	}
+}
+
+func TestRetryWithBackoffAndDeadLetter(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	// попытки 1, 2 и 3 (последняя) из трёх
+	storage := newFakeStorage(
+		testWebhook(1, receiver.URL, 0),
+		testWebhook(2, receiver.URL, 1),
+		testWebhook(3, receiver.URL, 2),
+	)
+	if err := newTestSender(t, storage, "127.0.0.0/8").SendDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if storage.retried[1] != time.Second || storage.retried[2] != 2*time.Second {
+		t.Errorf("delays are %v; want 1s and 2s", storage.retried)
+	}
+	if _, ok := storage.dead[3]; !ok || len(storage.dead) != 1 {
+		t.Errorf("dead webhooks are %v; want only 3", storage.dead)
+	}
+	if storage.lastErrs[1] == "" {
+		t.Error("error of attempt isn't saved")
+	}
+}
+
+func TestPrivateAddressIsRefused(t *testing.T) {
+	requests := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer receiver.Close()
+
+	// loopback не в allowlist, поэтому до получателя запрос не доходит
+	storage := newFakeStorage(testWebhook(1, receiver.URL, 0))
+	if err := newTestSender(t, storage).SendDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if requests != 0 {
+		t.Errorf("receiver got %d requests", requests)
+	}
+	if _, ok := storage.retried[1]; !ok || !strings.Contains(storage.lastErrs[1], safeurl.ErrNotPublic.Error()) {
+		t.Errorf("webhook isn't retried because of private address: %v, %q", storage.retried, storage.lastErrs[1])
+	}
+}
+
+func TestRedirectIsNotFollowed(t *testing.T) {
+	redirected := 0
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected++
+	}))
+	defer target.Close()
+	receiver := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer receiver.Close()
+
+	storage := newFakeStorage(testWebhook(1, receiver.URL, 0))
+	if err := newTestSender(t, storage, "127.0.0.0/8").SendDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if redirected != 0 {
+		t.Errorf("redirect is followed %d times", redirected)
+	}
+	if _, ok := storage.retried[1]; !ok {
+		t.Errorf("redirect is considered delivery: retried %v", storage.retried)
+	}
+}
+
+func TestBackoffIsLimited(t *testing.T) {
+	s := newTestSender(t, newFakeStorage())
+	for attempts, want := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		if got := s.delay(attempts); got != want {
+			t.Errorf("delay after %d attempts is %v; want %v", attempts+1, got, want)
+		}
+	}
+	if got := s.delay(100); got != 3*time.Second {
+		t.Errorf("delay after 101 attempts is %v; want 3s", got)
+	}
+}
@@ -0,0 +1,415 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/storage"
+)
+
+// SaveExpression saves expression with its tasks and returns generated id of expression.
+// If expression has no tasks, its result is already known and expression is saved as solved.
+//
+// If key isn't nil and user already submitted expression with it, nothing is saved:
+// id of that expression is returned with replayed = true, or storage.ErrIdempotencyKeyUsed
+// if it was another expression
+func (s *Storage) SaveExpression(ctx context.Context, expression *models.Expression, uid int, key *models.IdempotencyKey) (id string, replayed bool, err error) {
+	s.lock()
+	defer s.unlock()
+
+	if key != nil {
+		k := idempotencyKey{uid: uid, key: key.Key}
+		record, ok := s.keys[k]
+		// expired key is forgotten, so it can be used again
+		if ok && record.created.Before(now().Add(-key.TTL)) {
+			delete(s.keys, k)
+			ok = false
+		}
+		if ok {
+			if record.requestHash != key.RequestHash {
+				return "", false, storage.ErrIdempotencyKeyUsed
+			}
+			expression.IdExpression = record.idExpression
+			return record.idExpression, true, nil
+		}
+	}
+
+	s.insertExpression(expression, uid)
+
+	if key != nil {
+		s.keys[idempotencyKey{uid: uid, key: key.Key}] = &idempotencyRecord{
+			requestHash:  key.RequestHash,
+			idExpression: expression.IdExpression,
+			created:      now(),
+		}
+	}
+	return expression.IdExpression, false, nil
+}
+
+// SaveExpressions saves valid expressions of batch with their tasks at once.
+// Generated ids are set to expressions
+func (s *Storage) SaveExpressions(ctx context.Context, expressions []*models.Expression, uid int) error {
+	s.lock()
+	defer s.unlock()
+
+	for _, expression := range expressions {
+		s.insertExpression(expression, uid)
+	}
+	return nil
+}
+
+// insertExpression inserts expression with its tasks and sets generated id, status and deadline of expression.
+// If identical expression is being evaluated, expression shares its evaluation instead of having own tasks
+func (s *Storage) insertExpression(expression *models.Expression, uid int) {
+	if expression.CallbackUrl != nil {
+		// webhook can't be sent without secret of user
+		if _, ok := s.secrets[uid]; !ok {
+			s.secrets[uid] = newSecret()
+		}
+	}
+
+	saved := &models.Expression{
+		IdExpression:      newId(),
+		InfinixExpression: expression.InfinixExpression,
+		Variables:         expression.Variables,
+		Precision:         expression.Precision,
+		Priority:          expression.Priority,
+		UserId:            uid,
+		CreatedAt:         now(),
+		CacheKey:          clone(expression.CacheKey),
+		CallbackUrl:       clone(expression.CallbackUrl),
+	}
+
+	if origin := s.findOrigin(expression); origin != nil {
+		saved.Status, saved.IdAgent, saved.IdOrigin = origin.Status, clone(origin.IdAgent), clone(&origin.IdExpression)
+		s.addExpression(saved)
+
+		expression.IdExpression = saved.IdExpression
+		expression.Status = saved.Status
+		expression.IdOrigin = clone(saved.IdOrigin)
+		expression.Tasks = nil
+		return
+	}
+
+	if expression.Result != nil {
+		solved := saved.CreatedAt
+		saved.Result, saved.ExactResult, saved.Status, saved.SolvedAt = clone(expression.Result), clone(expression.ExactResult), models.Completed, &solved
+	} else {
+		saved.Status = models.Pending
+		if expression.Timeout > 0 {
+			deadline := saved.CreatedAt.Add(expression.Timeout)
+			saved.Deadline = &deadline
+		}
+	}
+	s.addExpression(saved)
+	expression.IdExpression = saved.IdExpression
+	expression.Status = saved.Status
+	expression.Deadline = clone(saved.Deadline)
+
+	// tasks go in postfix order, so parent of task is always after it.
+	// Inserting them from the end we know id of parent for every task
+	for i := len(expression.Tasks) - 1; i >= 0; i-- {
+		t := expression.Tasks[i]
+		t.IdExpression = expression.IdExpression
+
+		s.lastTask++
+		t.ID = s.lastTask
+		inserted := &task{
+			id:           t.ID,
+			idExpression: t.IdExpression,
+			operation:    t.Operation,
+			args:         append([]*string(nil), t.Args...),
+			status:       models.TaskNew,
+		}
+		if t.Parent != nil {
+			parentId := t.Parent.ID
+			inserted.parentId, inserted.parentSlot = &parentId, t.ParentSlot
+		}
+		s.tasks[t.ID] = inserted
+	}
+}
+
+// findOrigin returns the oldest identical expression without deadline, which is being evaluated,
+// if expression can share its evaluation. Expression with deadline always has own tasks,
+// because origin can't be timed out for it
+func (s *Storage) findOrigin(expression *models.Expression) *models.Expression {
+	if expression.CacheKey == nil || expression.Result != nil || expression.Timeout > 0 {
+		return nil
+	}
+
+	var origin *models.Expression
+	for _, e := range s.expressions {
+		if e.CacheKey == nil || *e.CacheKey != *expression.CacheKey || e.IdOrigin != nil || e.Deadline != nil ||
+			e.Status.IsFinal() {
+			continue
+		}
+		if origin == nil || older(e, origin) {
+			origin = e
+		}
+	}
+	return origin
+}
+
+// addExpression saves new expression, like inserting into table it's recorded and enqueues webhook
+func (s *Storage) addExpression(e *models.Expression) {
+	s.expressions[e.IdExpression] = e
+	s.recordEvent(e, nil)
+	s.enqueueWebhook(e)
+}
+
+// GetCachedResult returns result of completed expression with the same cache key
+func (s *Storage) GetCachedResult(ctx context.Context, key string) (*models.CachedResult, error) {
+	s.lock()
+	defer s.unlock()
+
+	cached, ok := s.results[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return &cached, nil
+}
+
+// CancelExpression cancels expression of user with its unfinished tasks.
+// Tasks which agents are evaluating keep id of agent, so agents can be told to abort them, see TakeCancelledTasks.
+// If other expressions share evaluation of this one, its tasks are handed over to one of them instead.
+// Returns storage.ErrExpressionFinished, if expression can't be cancelled anymore
+func (s *Storage) CancelExpression(ctx context.Context, id string, uid int) error {
+	s.lock()
+	defer s.unlock()
+
+	e, ok := s.expressions[id]
+	if !ok || e.UserId != uid {
+		return storage.ErrNotFound
+	}
+	if !e.Status.CanBecome(models.Cancelled) {
+		return storage.ErrExpressionFinished
+	}
+	s.setStatus(e, models.Cancelled)
+
+	if !s.handOverTasks(id) {
+		s.setTasksStatus(id, models.TaskCancelled)
+	}
+	return nil
+}
+
+// handOverTasks gives tasks of expression to the oldest unfinished expression, which shares its evaluation.
+// That one becomes origin of the others. Returns false, if there is no such expression
+func (s *Storage) handOverTasks(id string) bool {
+	var heir *models.Expression
+	for _, e := range s.expressions {
+		if e.IdOrigin == nil || *e.IdOrigin != id || e.Status.IsFinal() {
+			continue
+		}
+		if heir == nil || older(e, heir) {
+			heir = e
+		}
+	}
+	if heir == nil {
+		return false
+	}
+
+	for _, e := range s.expressions {
+		if e.IdOrigin == nil || *e.IdOrigin != id {
+			continue
+		}
+		if e == heir {
+			e.IdOrigin = nil
+		} else {
+			idHeir := heir.IdExpression
+			e.IdOrigin = &idHeir
+		}
+	}
+	for _, t := range s.tasks {
+		if t.idExpression == id {
+			t.idExpression = heir.IdExpression
+		}
+	}
+	return true
+}
+
+// TimeOutExpressions times out expressions, which weren't completed before deadline, and returns their count.
+// Their unfinished tasks are cancelled, so agents evaluating them are told to abort, see TakeCancelledTasks
+func (s *Storage) TimeOutExpressions(ctx context.Context) (int, error) {
+	s.lock()
+	defer s.unlock()
+
+	timedOut := 0
+	current := now()
+	for _, e := range s.expressions {
+		if e.Deadline == nil || e.Deadline.After(current) || !e.Status.CanBecome(models.TimedOut) {
+			continue
+		}
+		e.Error = &models.ExpressionError{
+			Code:    models.ErrorDeadlineExceeded,
+			Message: "expression wasn't completed before deadline",
+		}
+		e.SolvedAt = &current
+		s.setStatus(e, models.TimedOut)
+		s.setTasksStatus(e.IdExpression, models.TaskCancelled)
+		timedOut++
+	}
+	return timedOut, nil
+}
+
+// GetExpressionsForUser returns page of expressions of user selected by filter and cursor
+// of the next page, which is nil for the last page
+func (s *Storage) GetExpressionsForUser(ctx context.Context, uid int, filter models.ExpressionFilter) ([]models.Expression, *models.Cursor, error) {
+	s.lock()
+	defer s.unlock()
+
+	sortTime := func(e *models.Expression) *time.Time {
+		if filter.SortBy == models.SortBySolved {
+			return e.SolvedAt
+		}
+		return &e.CreatedAt
+	}
+	// before reports whether a goes before b on page
+	before := func(a *models.Expression, b *models.Expression) bool {
+		if filter.Ascending {
+			return compare(*sortTime(a), a.IdExpression, *sortTime(b), b.IdExpression) < 0
+		}
+		return compare(*sortTime(a), a.IdExpression, *sortTime(b), b.IdExpression) > 0
+	}
+	contains := strings.ToLower(filter.Contains)
+
+	var expressions []*models.Expression
+	for _, e := range s.expressions {
+		switch {
+		case e.UserId != uid || sortTime(e) == nil:
+		case len(filter.Statuses) > 0 && !hasStatus(filter.Statuses, e.Status):
+		case filter.CreatedFrom != nil && e.CreatedAt.Before(*filter.CreatedFrom):
+		case filter.CreatedTo != nil && !e.CreatedAt.Before(*filter.CreatedTo):
+		case filter.SolvedFrom != nil && (e.SolvedAt == nil || e.SolvedAt.Before(*filter.SolvedFrom)):
+		case filter.SolvedTo != nil && (e.SolvedAt == nil || !e.SolvedAt.Before(*filter.SolvedTo)):
+		case !strings.Contains(strings.ToLower(e.InfinixExpression), contains):
+		case filter.Cursor != nil && !before(&models.Expression{CreatedAt: filter.Cursor.Time, SolvedAt: &filter.Cursor.Time, IdExpression: filter.Cursor.Id}, e):
+		default:
+			expressions = append(expressions, e)
+		}
+	}
+	sort.Slice(expressions, func(i, j int) bool {
+		return before(expressions[i], expressions[j])
+	})
+
+	var next *models.Cursor
+	if len(expressions) > filter.Limit {
+		expressions = expressions[:filter.Limit]
+		last := expressions[len(expressions)-1]
+		next = &models.Cursor{SortBy: filter.SortBy, Ascending: filter.Ascending, Time: *sortTime(last), Id: last.IdExpression}
+	}
+
+	page := make([]models.Expression, len(expressions))
+	for i, e := range expressions {
+		page[i] = *e
+	}
+	return page, next, nil
+}
+
+func (s *Storage) GetExpressionById(ctx context.Context, id string, uid int) (*models.Expression, error) {
+	s.lock()
+	defer s.unlock()
+
+	e, ok := s.expressions[id]
+	if !ok || e.UserId != uid {
+		return nil, storage.ErrNotFound
+	}
+	expression := *e
+	return &expression, nil
+}
+
+// GetExpressionsByIds returns expressions of user with given ids. Ids which aren't found are skipped
+func (s *Storage) GetExpressionsByIds(ctx context.Context, ids []string, uid int) ([]models.Expression, error) {
+	s.lock()
+	defer s.unlock()
+
+	var expressions []models.Expression
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		e, ok := s.expressions[id]
+		if !ok || e.UserId != uid || seen[id] {
+			continue
+		}
+		seen[id] = true
+		expressions = append(expressions, *e)
+	}
+	return expressions, nil
+}
+
+// GetExpressionEvents returns history of statuses of expression of user, from the oldest to the newest one
+func (s *Storage) GetExpressionEvents(ctx context.Context, id string, uid int) ([]models.ExpressionEvent, error) {
+	s.lock()
+	defer s.unlock()
+
+	e, ok := s.expressions[id]
+	if !ok || e.UserId != uid {
+		return nil, storage.ErrNotFound
+	}
+	return append([]models.ExpressionEvent(nil), s.events[id]...), nil
+}
+
+// GetExpressionSteps returns trace of expression of user: operations which agents performed to evaluate it.
+// Identical expression, which shares evaluation of another one, has trace of that one
+func (s *Storage) GetExpressionSteps(ctx context.Context, id string, uid int) ([]models.ExpressionStep, error) {
+	s.lock()
+	defer s.unlock()
+
+	e, ok := s.expressions[id]
+	if !ok || e.UserId != uid {
+		return nil, storage.ErrNotFound
+	}
+	evaluated := id
+	if e.IdOrigin != nil {
+		evaluated = *e.IdOrigin
+	}
+
+	steps := []models.ExpressionStep{}
+	for _, step := range s.steps {
+		if t, ok := s.tasks[step.IdTask]; ok && t.idExpression == evaluated {
+			steps = append(steps, *step)
+		}
+	}
+	sort.Slice(steps, func(i, j int) bool {
+		if !steps[i].FinishedAt.Equal(steps[j].FinishedAt) {
+			return steps[i].FinishedAt.Before(steps[j].FinishedAt)
+		}
+		return steps[i].ID < steps[j].ID
+	})
+	return steps, nil
+}
+
+// setTasksStatus sets status to unfinished tasks of expression
+func (s *Storage) setTasksStatus(id string, status models.TaskStatus) {
+	for _, t := range s.tasks {
+		if t.idExpression == id && (t.status == models.TaskNew || t.status == models.TaskSolving) {
+			t.status = status
+		}
+	}
+}
+
+// older reports whether a was created before b, like ORDER BY created, id
+func older(a *models.Expression, b *models.Expression) bool {
+	return compare(a.CreatedAt, a.IdExpression, b.CreatedAt, b.IdExpression) < 0
+}
+
+// compare compares (t1, id1) and (t2, id2) like rows are compared in postgres
+func compare(t1 time.Time, id1 string, t2 time.Time, id2 string) int {
+	switch {
+	case t1.Before(t2):
+		return -1
+	case t1.After(t2):
+		return 1
+	}
+	return strings.Compare(id1, id2)
+}
+
+func hasStatus(statuses []models.Status, status models.Status) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,298 @@
+package memory
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/storage"
+)
+
+// Storage keeps everything in memory, so it's lost on restart. It behaves like postgres.Postgresql,
+// including its triggers, and returns storage.ErrNotFound when something isn't found.
+// It's used for local development, demos without database and tests
+type Storage struct {
+	mu sync.Mutex
+
+	apps        map[string]models.App // by name
+	agents      map[int]*models.Agent
+	operations  map[string]time.Duration
+	expressions map[string]*models.Expression
+	events      map[string][]models.ExpressionEvent // id of expression -> its transitions
+	tasks       map[int]*task
+	steps       map[stepKey]*models.ExpressionStep
+	keys        map[idempotencyKey]*idempotencyRecord
+	results     map[string]models.CachedResult // results cache by cache key
+	secrets     map[int]string                 // uid -> secret of webhooks
+	webhooks    map[int]*webhook
+	dead        []deadWebhook
+
+	lastApp, lastAgent, lastTask, lastEvent, lastStep, lastWebhook int
+
+	// updates made while storage is locked, they are published when it's unlocked like postgres notifies at commit
+	updates      []models.ExpressionUpdate
+	listeners    map[int]func(models.ExpressionUpdate)
+	lastListener int
+}
+
+// task is row of tasks, see models.Task
+type task struct {
+	id           int
+	idExpression string
+	operation    string
+	args         []*string
+	parentId     *int
+	parentSlot   int
+	result       *string
+	idAgent      *int
+	status       models.TaskStatus
+}
+
+type stepKey struct {
+	idTask int
+	step   int
+}
+
+type idempotencyKey struct {
+	uid int
+	key string
+}
+
+type idempotencyRecord struct {
+	requestHash  string
+	idExpression string
+	created      time.Time
+}
+
+// operations which agents can evaluate, like they are inserted by migration
+var defaultOperations = []string{"+", "-", "*", "/", "^", "neg", "sqrt", "abs", "sin", "cos", "log", "min", "max"}
+
+func New() *Storage {
+	operations := make(map[string]time.Duration, len(defaultOperations))
+	for _, operation := range defaultOperations {
+		operations[operation] = time.Second
+	}
+
+	return &Storage{
+		apps:        make(map[string]models.App),
+		agents:      make(map[int]*models.Agent),
+		operations:  operations,
+		expressions: make(map[string]*models.Expression),
+		events:      make(map[string][]models.ExpressionEvent),
+		tasks:       make(map[int]*task),
+		steps:       make(map[stepKey]*models.ExpressionStep),
+		keys:        make(map[idempotencyKey]*idempotencyRecord),
+		results:     make(map[string]models.CachedResult),
+		secrets:     make(map[int]string),
+		webhooks:    make(map[int]*webhook),
+		listeners:   make(map[int]func(models.ExpressionUpdate)),
+	}
+}
+
+// lock locks storage, it should be unlocked with unlock
+func (s *Storage) lock() {
+	s.mu.Lock()
+}
+
+// unlock unlocks storage and then publishes updates, which were made while it was locked
+func (s *Storage) unlock() {
+	updates := s.updates
+	s.updates = nil
+	listeners := make([]func(models.ExpressionUpdate), 0, len(s.listeners))
+	for _, listener := range s.listeners {
+		listeners = append(listeners, listener)
+	}
+	s.mu.Unlock()
+
+	for _, update := range updates {
+		for _, listener := range listeners {
+			listener(update)
+		}
+	}
+}
+
+// RegisterApp registers new app and returns id. App with the same name is replaced
+func (s *Storage) RegisterApp(ctx context.Context, name, secret string) (int64, error) {
+	s.lock()
+	defer s.unlock()
+
+	s.lastApp++
+	s.apps[name] = models.App{ID: s.lastApp, Name: name, Secret: secret}
+	return int64(s.lastApp), nil
+}
+
+// RegisterNewAgent registers new agent and returns id
+func (s *Storage) RegisterNewAgent(ctx context.Context) (int, error) {
+	s.lock()
+	defer s.unlock()
+
+	s.lastAgent++
+	s.agents[s.lastAgent] = &models.Agent{ID: s.lastAgent, LastHeartbeat: now(), Status: "free"}
+	return s.lastAgent, nil
+}
+
+// Heartbeat updates last heartbeat of agent. Agent which was considered dead becomes free again
+func (s *Storage) Heartbeat(ctx context.Context, id_agent int) error {
+	s.lock()
+	defer s.unlock()
+
+	if agent, ok := s.agents[id_agent]; ok {
+		agent.LastHeartbeat = now()
+		if agent.Status == "dead" {
+			agent.Status = "free"
+		}
+	}
+	return nil
+}
+
+// RemoveAgent forgets agent, its tasks are given to other agents
+func (s *Storage) RemoveAgent(ctx context.Context, id_agent int) error {
+	s.lock()
+	defer s.unlock()
+
+	delete(s.agents, id_agent)
+	requeued := s.requeueTasks(func(t *task) bool {
+		return t.idAgent != nil && *t.idAgent == id_agent
+	})
+	s.releaseExpressions(requeued)
+	return nil
+}
+
+// GetAllAgent returns all agents
+func (s *Storage) GetAllAgent(ctx context.Context) ([]models.Agent, error) {
+	s.lock()
+	defer s.unlock()
+
+	agents := make([]models.Agent, 0, len(s.agents))
+	for _, agent := range s.agents {
+		agents = append(agents, *agent)
+	}
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].ID < agents[j].ID
+	})
+	return agents, nil
+}
+
+// GetOperations returns all operations with their durations
+func (s *Storage) GetOperations(ctx context.Context) ([]models.OperationDuration, error) {
+	s.lock()
+	defer s.unlock()
+
+	operations := make([]models.OperationDuration, 0, len(s.operations))
+	for operation, duration := range s.operations {
+		operations = append(operations, models.OperationDuration{Operation: operation, Duration: duration})
+	}
+	sort.Slice(operations, func(i, j int) bool {
+		return operations[i].Operation < operations[j].Operation
+	})
+	return operations, nil
+}
+
+// SetOperationDuration changes duration of operation. Agents use it for every task given after that
+func (s *Storage) SetOperationDuration(ctx context.Context, operation string, duration time.Duration) error {
+	s.lock()
+	defer s.unlock()
+
+	if _, ok := s.operations[operation]; !ok {
+		return storage.ErrNotFound
+	}
+	// milliseconds are kept like in postgres
+	s.operations[operation] = duration.Truncate(time.Millisecond)
+	return nil
+}
+
+// setStatus changes status of expression like triggers of postgres do: transition is recorded and published,
+// webhook is enqueued for final status and expressions, which share evaluation of this one, get it too
+func (s *Storage) setStatus(e *models.Expression, status models.Status) {
+	from := e.Status
+	if from == status {
+		return
+	}
+	e.Status = status
+	s.recordEvent(e, &from)
+	s.enqueueWebhook(e)
+
+	// cancelled or timed out origin hands its tasks over, so it isn't shared
+	if status == models.Cancelled || status == models.TimedOut {
+		return
+	}
+	for _, follower := range s.expressions {
+		if follower.IdOrigin == nil || *follower.IdOrigin != e.IdExpression || follower.Status.IsFinal() {
+			continue
+		}
+		follower.Result, follower.ExactResult, follower.Error = e.Result, e.ExactResult, e.Error
+		follower.SolvedAt, follower.IdAgent = e.SolvedAt, e.IdAgent
+		s.setStatus(follower, status)
+	}
+}
+
+// recordEvent records transition of expression to its current status, from is nil when expression is created
+func (s *Storage) recordEvent(e *models.Expression, from *models.Status) {
+	s.lastEvent++
+	event := models.ExpressionEvent{
+		ID:           s.lastEvent,
+		IdExpression: e.IdExpression,
+		FromStatus:   from,
+		Status:       e.Status,
+		IdAgent:      clone(e.IdAgent),
+		CreatedAt:    now(),
+	}
+	s.events[e.IdExpression] = append(s.events[e.IdExpression], event)
+	s.updates = append(s.updates, models.ExpressionUpdate{IdExpression: e.IdExpression, Event: &event})
+}
+
+// ListenExpressionUpdates calls update with every transition and progress of expressions.
+// Blocks until ctx is done
+func (s *Storage) ListenExpressionUpdates(ctx context.Context, update func(models.ExpressionUpdate)) error {
+	s.lock()
+	s.lastListener++
+	id := s.lastListener
+	s.listeners[id] = update
+	s.unlock()
+
+	<-ctx.Done()
+
+	s.lock()
+	delete(s.listeners, id)
+	s.unlock()
+	return nil
+}
+
+// now returns current time in UTC, like timestamps are read from postgres
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// clone copies value, so stored expressions don't share it with callers
+func clone[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+// newId generates random UUID like gen_random_uuid
+func newId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// newSecret generates secret of webhooks of user, it's as long as in postgres
+func newSecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,604 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+	expressionparser "github.com/a-romash/grpc-calculator/orchestrator/internal/lib/expressionParser"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/number"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/taskgraph"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/storage"
+)
+
+// saveTestExpression сохраняет выражение так же, как это делает сервис: с задачами или сразу посчитанным
+func saveTestExpression(t *testing.T, s *Storage, infix string, uid int, cacheKey *string) *models.Expression {
+	t.Helper()
+
+	tokens, err := expressionparser.ParseExpression(infix, nil, models.PrecisionFloat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, value, err := taskgraph.Build(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expression := models.Create(infix, tokens)
+	expression.Tasks = tasks
+	expression.CacheKey = cacheKey
+	if len(tasks) == 0 {
+		result := number.Float(value)
+		expression.Result = &result
+	}
+	if _, _, err = s.SaveExpression(context.Background(), &expression, uid, nil); err != nil {
+		t.Fatal(err)
+	}
+	return &expression
+}
+
+func TestGetTaskConcurrentClaimers(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	const uid = 1
+
+	// у каждого выражения 4 независимые задачи, которые готовы к решению сразу
+	for i := 0; i < 30; i++ {
+		saveTestExpression(t, s, "(1+2)*(3+4)-(5+6)/(7+8)", uid, nil)
+	}
+
+	var (
+		mu      sync.Mutex
+		claimed = make(map[int]int) // id задачи -> сколько раз её выдали
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < 5; i++ {
+		idAgent, err := s.RegisterNewAgent(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < 10; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					task, err := s.GetTask(ctx, idAgent, models.Queue{Uid: uid}, 0)
+					if errors.Is(err, storage.ErrNotFound) {
+						return
+					}
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					mu.Lock()
+					claimed[task.ID]++
+					mu.Unlock()
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	for id, count := range claimed {
+		if count != 1 {
+			t.Errorf("task %d was claimed %d times", id, count)
+		}
+	}
+	if len(claimed) != 30*4 {
+		t.Errorf("claimed %d tasks; want %d", len(claimed), 30*4)
+	}
+}
+
+func TestExpressionLifecycle(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	const uid = 1
+
+	expression := saveTestExpression(t, s, "1+2", uid, nil)
+	idAgent, err := s.RegisterNewAgent(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task, err := s.GetTask(ctx, idAgent, models.Queue{Uid: uid}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.StartTask(ctx, task.ID, idAgent); err != nil {
+		t.Fatal(err)
+	}
+	// агент, которому задача не отдана, не может её решить
+	if err = s.SaveTaskResult(ctx, task.ID, "3", idAgent+1); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("result of another agent: err %v, want %v", err, storage.ErrNotFound)
+	}
+	if err = s.SaveTaskResult(ctx, task.ID, "3", idAgent); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetExpressionById(ctx, expression.IdExpression, uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != models.Completed || got.Result == nil || *got.Result != 3 || got.SolvedAt == nil {
+		t.Errorf("expression: status %s, result %v, solved at %v; want completed with 3", got.Status, got.Result, got.SolvedAt)
+	}
+
+	events, err := s.GetExpressionEvents(ctx, expression.IdExpression, uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.Status{models.Pending, models.Scheduled, models.Running, models.Completed}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events; want %d", len(events), len(want))
+	}
+	for i, event := range events {
+		if event.Status != want[i] {
+			t.Errorf("event %d: status %s; want %s", i, event.Status, want[i])
+		}
+	}
+
+	// у чужого пользователя выражения нет
+	if _, err = s.GetExpressionById(ctx, expression.IdExpression, uid+1); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expression of another user: err %v, want %v", err, storage.ErrNotFound)
+	}
+}
+
+func TestCancelExpression(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	const uid = 1
+
+	expression := saveTestExpression(t, s, "(1+2)*(3+4)", uid, nil)
+	idAgent, err := s.RegisterNewAgent(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := s.GetTask(ctx, idAgent, models.Queue{Uid: uid}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.CancelExpression(ctx, expression.IdExpression, uid+1); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("cancel by another user: err %v, want %v", err, storage.ErrNotFound)
+	}
+	if err = s.CancelExpression(ctx, expression.IdExpression, uid); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.CancelExpression(ctx, expression.IdExpression, uid); !errors.Is(err, storage.ErrExpressionFinished) {
+		t.Fatalf("second cancel: err %v, want %v", err, storage.ErrExpressionFinished)
+	}
+
+	// агент должен узнать об отменённой задаче ровно один раз
+	aborted, err := s.TakeCancelledTasks(ctx, idAgent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aborted) != 1 || aborted[0] != task.ID {
+		t.Errorf("cancelled tasks %v; want [%d]", aborted, task.ID)
+	}
+	if aborted, err = s.TakeCancelledTasks(ctx, idAgent); err != nil || len(aborted) != 0 {
+		t.Errorf("cancelled tasks taken again: %v, err %v", aborted, err)
+	}
+}
+
+func TestSharedExpression(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	const first, second, third = 1, 2, 3
+
+	key := "key"
+	origin := saveTestExpression(t, s, "1+2", first, &key)
+	shared := saveTestExpression(t, s, "1+2", second, &key)
+	cancelled := saveTestExpression(t, s, "1+2", third, &key)
+	if shared.IdOrigin == nil || *shared.IdOrigin != origin.IdExpression || len(shared.Tasks) != 0 {
+		t.Fatalf("the second expression doesn't share the first one: origin %v, %d tasks", shared.IdOrigin, len(shared.Tasks))
+	}
+
+	// отменённое выражение, которое разделяет вычисление, не отменяет исходное
+	if err := s.CancelExpression(ctx, cancelled.IdExpression, third); err != nil {
+		t.Fatal(err)
+	}
+
+	idAgent, err := s.RegisterNewAgent(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.GetTask(ctx, idAgent, models.Queue{Uid: second}, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("task of shared expression: err %v, want %v", err, storage.ErrNotFound)
+	}
+	task, err := s.GetTask(ctx, idAgent, models.Queue{Uid: first}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.SaveTaskResult(ctx, task.ID, "3", idAgent); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetExpressionsByIds(ctx, []string{shared.IdExpression, cancelled.IdExpression}, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Status != models.Completed || got[0].Result == nil || *got[0].Result != 3 {
+		t.Errorf("expressions of the second user are %+v; want shared one completed with 3", got)
+	}
+	if e, _ := s.GetExpressionById(ctx, cancelled.IdExpression, third); e == nil || e.Status != models.Cancelled {
+		t.Errorf("cancelled expression became %+v", e)
+	}
+
+	cached, err := s.GetCachedResult(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached.Result != 3 {
+		t.Errorf("cached result %v; want 3", cached.Result)
+	}
+}
+
+// Выражения, задачи которых не забирали у отключённого агента, не возвращаются в pending
+func TestRequeueReleasesOnlyRequeuedExpressions(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	const first, second, third, fourth = 1, 2, 3, 4
+
+	healthy, err := s.RegisterNewAgent(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reaped, err := s.RegisterNewAgent(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// у выражения из двух задач первая решена, а вторая ещё не выдана
+	between := saveTestExpression(t, s, "(1+2)*3", first, nil)
+	task, err := s.GetTask(ctx, healthy, models.Queue{Uid: first}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.SaveTaskResult(ctx, task.ID, "3", healthy); err != nil {
+		t.Fatal(err)
+	}
+
+	// второе выражение разделяет вычисление первого, у него нет задач
+	key := "key"
+	origin := saveTestExpression(t, s, "4+5", second, &key)
+	follower := saveTestExpression(t, s, "4+5", third, &key)
+	if _, err = s.GetTask(ctx, healthy, models.Queue{Uid: second}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	requeued := saveTestExpression(t, s, "6+7", fourth, nil)
+	if _, err = s.GetTask(ctx, reaped, models.Queue{Uid: fourth}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	status := func(e *models.Expression, uid int) models.Status {
+		t.Helper()
+		got, err := s.GetExpressionById(ctx, e.IdExpression, uid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got.Status
+	}
+	before := []models.Status{status(between, first), status(origin, second), status(follower, third)}
+	if before[0] == models.Pending || before[2] == models.Pending {
+		t.Fatalf("expressions are %v before agent is reaped", before)
+	}
+
+	s.agents[reaped].LastHeartbeat = now().Add(-time.Hour)
+	if count, err := s.RequeueStaleTasks(ctx, time.Minute, 3); err != nil || count != 1 {
+		t.Fatalf("requeued %d tasks: %v; want 1", count, err)
+	}
+
+	after := []models.Status{status(between, first), status(origin, second), status(follower, third)}
+	for i := range before {
+		if after[i] != before[i] {
+			t.Errorf("expressions were %v, became %v", before, after)
+			break
+		}
+	}
+	if got := status(requeued, fourth); got != models.Pending {
+		t.Errorf("requeued expression is %s; want %s", got, models.Pending)
+	}
+}
+
+// Агент, у которого не истёк heartbeat, не теряет свои выражения, когда забирают задачи у другого
+func TestRequeueStaleTasksOfDeadAgentOnly(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	const first, second, third = 1, 2, 3
+
+	save := func(infix string, uid int) *models.Expression {
+		return saveTestExpression(t, s, infix, uid, nil)
+	}
+	healthy, err := s.RegisterNewAgent(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reaped, err := s.RegisterNewAgent(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// у здорового агента одно выражение назначено, другое уже вычисляется
+	scheduled := save("1+2", first)
+	if _, err := s.GetTask(ctx, healthy, models.Queue{Uid: first}, 0); err != nil {
+		t.Fatal(err)
+	}
+	running := save("3+4", second)
+	task, err := s.GetTask(ctx, healthy, models.Queue{Uid: second}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.StartTask(ctx, task.ID, healthy); err != nil {
+		t.Fatal(err)
+	}
+
+	dead := save("5+6", third)
+	if _, err = s.GetTask(ctx, reaped, models.Queue{Uid: third}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	status := func(e *models.Expression, uid int) models.Status {
+		t.Helper()
+		got, err := s.GetExpressionById(ctx, e.IdExpression, uid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got.Status
+	}
+
+	s.agents[reaped].LastHeartbeat = now().Add(-time.Hour)
+	if count, err := s.RequeueStaleTasks(ctx, time.Minute, 3); err != nil || count != 1 {
+		t.Fatalf("requeued %d tasks: %v; want 1", count, err)
+	}
+
+	if got := status(scheduled, first); got != models.Scheduled {
+		t.Errorf("scheduled expression of healthy agent is %s", got)
+	}
+	if got := status(running, second); got != models.Running {
+		t.Errorf("running expression of healthy agent is %s", got)
+	}
+	if got := status(dead, third); got != models.Pending {
+		t.Errorf("expression of reaped agent is %s; want %s", got, models.Pending)
+	}
+	// задача остаётся у здорового агента, и он может сохранить её результат
+	if err = s.SaveTaskResult(ctx, task.ID, "7", healthy); err != nil {
+		t.Errorf("result of healthy agent: %v", err)
+	}
+}
+
+func TestCancelSharedExpressionHandsOverTasks(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	const first, second = 1, 2
+
+	key := "key"
+	origin := saveTestExpression(t, s, "2*3", first, &key)
+	shared := saveTestExpression(t, s, "2*3", second, &key)
+
+	// отмена первого выражения не отменяет второе: задачи переходят к нему
+	if err := s.CancelExpression(ctx, origin.IdExpression, first); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetExpressionById(ctx, shared.IdExpression, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != models.Pending || got.IdOrigin != nil {
+		t.Fatalf("shared expression after cancel: status %s, origin %v", got.Status, got.IdOrigin)
+	}
+
+	idAgent, err := s.RegisterNewAgent(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := s.GetTask(ctx, idAgent, models.Queue{Uid: second}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.IdExpression != shared.IdExpression {
+		t.Errorf("task of %s; want %s", task.IdExpression, shared.IdExpression)
+	}
+}
+
+func TestWebhookOfFinishedExpression(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	const uid = 1
+
+	secret, err := s.GetWebhookSecret(ctx, uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := expressionparser.ParseExpression("1+2", nil, models.PrecisionFloat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, _, err := taskgraph.Build(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://localhost/callback"
+	expression := models.Create("1+2", tokens)
+	expression.Tasks = tasks
+	expression.CallbackUrl = &url
+	if _, _, err = s.SaveExpression(ctx, &expression, uid, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// пока выражение не закончено, вебхука нет
+	if webhooks, err := s.TakeWebhooks(ctx, 10, time.Minute); err != nil || len(webhooks) != 0 {
+		t.Fatalf("webhooks before expression is finished: %+v, err %v", webhooks, err)
+	}
+	if err = s.CancelExpression(ctx, expression.IdExpression, uid); err != nil {
+		t.Fatal(err)
+	}
+
+	webhooks, err := s.TakeWebhooks(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(webhooks) != 1 {
+		t.Fatalf("got %d webhooks; want 1", len(webhooks))
+	}
+	webhook := webhooks[0]
+	if webhook.Url != url || webhook.Secret != secret || webhook.Payload.Status != models.Cancelled {
+		t.Errorf("webhook is %+v", webhook)
+	}
+
+	// во время аренды вебхук не отдаётся снова
+	if again, err := s.TakeWebhooks(ctx, 10, time.Minute); err != nil || len(again) != 0 {
+		t.Fatalf("webhooks taken during lease: %+v, err %v", again, err)
+	}
+
+	if err = s.FailWebhook(ctx, webhook.ID, "receiver responded with 500"); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.webhooks) != 0 || len(s.dead) != 1 || s.dead[0].attempts != 1 {
+		t.Errorf("%d webhooks left, dead letters are %+v", len(s.webhooks), s.dead)
+	}
+}
+
+func TestListenExpressionUpdates(t *testing.T) {
+	s := New()
+	const uid = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := make(chan models.ExpressionUpdate, 16)
+	listening := make(chan error, 1)
+	go func() {
+		listening <- s.ListenExpressionUpdates(ctx, func(update models.ExpressionUpdate) {
+			updates <- update
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-listening; err != nil {
+			t.Error(err)
+		}
+	})
+	// ждём, пока слушатель зарегистрируется
+	for {
+		s.lock()
+		count := len(s.listeners)
+		s.unlock()
+		if count > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	expression := saveTestExpression(t, s, "1+2", uid, nil)
+	idAgent, err := s.RegisterNewAgent(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := s.GetTask(context.Background(), idAgent, models.Queue{Uid: uid}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SaveProgress(context.Background(), models.ExpressionProgress{IdTask: task.ID, Operation: "+", Operands: []string{"1", "2"}, Value: "3", IdAgent: idAgent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.SaveTaskResult(context.Background(), task.ID, "3", idAgent); err != nil {
+		t.Fatal(err)
+	}
+
+	// слушатель вызывается синхронно, поэтому все обновления уже в канале
+	var statuses []models.Status
+	progress := 0
+	for len(updates) > 0 {
+		update := <-updates
+		if update.IdExpression != expression.IdExpression {
+			continue
+		}
+		if update.Event != nil {
+			statuses = append(statuses, update.Event.Status)
+		} else if update.Progress != nil && update.Progress.Value == "3" {
+			progress++
+		}
+	}
+	want := []models.Status{models.Pending, models.Scheduled, models.Completed}
+	if len(statuses) != len(want) || progress != 1 {
+		t.Fatalf("got statuses %v and %d progress updates; want %v and 1", statuses, progress, want)
+	}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Fatalf("statuses are %v; want %v", statuses, want)
+		}
+	}
+
+	steps, err := s.GetExpressionSteps(context.Background(), expression.IdExpression, uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 1 || steps[0].Result != "3" {
+		t.Errorf("steps are %+v; want one step 1+2=3", steps)
+	}
+}
+
+func TestGetExpressionsForUserPages(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	const uid = 1
+
+	// 2 выражения ждут агентов, 3 посчитаны сразу
+	saved := make(map[string]bool)
+	for _, infix := range []string{"1+2", "3*4", "5", "6", "7"} {
+		saved[saveTestExpression(t, s, infix, uid, nil).IdExpression] = true
+	}
+	saveTestExpression(t, s, "8", uid+1, nil)
+
+	// все страницы по 2 выражения, от новых к старым
+	filter := models.ExpressionFilter{SortBy: models.SortByCreated, Limit: 2}
+	var listed []models.Expression
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("too many pages")
+		}
+		page, next, err := s.GetExpressionsForUser(ctx, uid, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		listed = append(listed, page...)
+		if next == nil {
+			break
+		}
+		filter.Cursor = next
+	}
+	if len(listed) != len(saved) {
+		t.Fatalf("listed %d expressions; want %d", len(listed), len(saved))
+	}
+	for i, e := range listed {
+		if !saved[e.IdExpression] {
+			t.Errorf("expression %s is listed twice or isn't of user", e.IdExpression)
+		}
+		delete(saved, e.IdExpression)
+		if i > 0 && e.CreatedAt.After(listed[i-1].CreatedAt) {
+			t.Errorf("expression %d is newer than previous one", i)
+		}
+	}
+
+	page, _, err := s.GetExpressionsForUser(ctx, uid, models.ExpressionFilter{SortBy: models.SortBySolved, Ascending: true, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 3 {
+		t.Errorf("sorted by solved time are %d expressions; want 3 solved", len(page))
+	}
+
+	page, _, err = s.GetExpressionsForUser(ctx, uid, models.ExpressionFilter{
+		SortBy: models.SortByCreated, Limit: 10, Contains: "*", Statuses: []models.Status{models.Pending},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 1 || page[0].InfinixExpression != "3*4" {
+		t.Errorf("filtered expressions are %+v; want only 3*4", page)
+	}
+}
@@ -0,0 +1,383 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/number"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/storage"
+)
+
+// GetReadyQueues returns queues which have tasks ready for evaluating, from the highest priority.
+// If maxRunning isn't 0, pending expressions of user, who already has maxRunning scheduled or running ones, aren't counted
+func (s *Storage) GetReadyQueues(ctx context.Context, maxRunning int) ([]models.Queue, error) {
+	s.lock()
+	defer s.unlock()
+
+	running := s.runningByUser()
+	seen := make(map[models.Queue]bool)
+	var queues []models.Queue
+	for _, t := range s.tasks {
+		e := s.expressions[t.idExpression]
+		queue := models.Queue{Priority: e.Priority, Uid: e.UserId}
+		if seen[queue] || !isReady(t, e, running, maxRunning) {
+			continue
+		}
+		seen[queue] = true
+		queues = append(queues, queue)
+	}
+	sort.Slice(queues, func(i, j int) bool {
+		if queues[i].Priority != queues[j].Priority {
+			return queues[i].Priority > queues[j].Priority
+		}
+		return queues[i].Uid < queues[j].Uid
+	})
+	return queues, nil
+}
+
+// GetTask returns 1 'new' task from queue, which operands are all known, and gives it to agent.
+// maxRunning limits scheduled and running expressions of user like in GetReadyQueues, 0 is no limit
+func (s *Storage) GetTask(ctx context.Context, id_agent int, queue models.Queue, maxRunning int) (*models.Task, error) {
+	s.lock()
+	defer s.unlock()
+
+	running := s.runningByUser()
+	var claimed *task
+	for _, t := range s.tasks {
+		e := s.expressions[t.idExpression]
+		if e.UserId != queue.Uid || e.Priority != queue.Priority || !isReady(t, e, running, maxRunning) {
+			continue
+		}
+		if claimed == nil || t.id < claimed.id {
+			claimed = t
+		}
+	}
+	if claimed == nil {
+		return nil, storage.ErrNotFound
+	}
+
+	claimed.status, claimed.idAgent = models.TaskSolving, clone(&id_agent)
+
+	// expression which is already running stays running
+	e := s.expressions[claimed.idExpression]
+	e.IdAgent = clone(&id_agent)
+	if e.Status.CanBecome(models.Scheduled) {
+		s.setStatus(e, models.Scheduled)
+	}
+
+	if agent, ok := s.agents[id_agent]; ok {
+		agent.Status = "busy"
+	}
+
+	return &models.Task{
+		ID:           claimed.id,
+		IdExpression: claimed.idExpression,
+		Operation:    claimed.operation,
+		Args:         append([]*string(nil), claimed.args...),
+		IdAgent:      id_agent,
+		Status:       models.TaskSolving,
+		Duration:     s.operations[claimed.operation],
+		Exact:        e.Precision == models.PrecisionExact,
+		Deadline:     clone(e.Deadline),
+	}, nil
+}
+
+// isReady reports whether task of expression e can be given to agent: all its operands are known,
+// deadline of expression hasn't passed and user hasn't reached maxRunning, see runningByUser
+func isReady(t *task, e *models.Expression, running map[int]int, maxRunning int) bool {
+	if t.status != models.TaskNew {
+		return false
+	}
+	for _, arg := range t.args {
+		if arg == nil {
+			return false
+		}
+	}
+	if e.Deadline != nil && !e.Deadline.After(now()) {
+		return false
+	}
+	return maxRunning == 0 || e.Status == models.Scheduled || e.Status == models.Running || running[e.UserId] < maxRunning
+}
+
+// runningByUser returns how many scheduled or running expressions every user has.
+// Expressions which share evaluation of others aren't counted
+func (s *Storage) runningByUser() map[int]int {
+	running := make(map[int]int)
+	for _, e := range s.expressions {
+		if (e.Status == models.Scheduled || e.Status == models.Running) && e.IdOrigin == nil {
+			running[e.UserId]++
+		}
+	}
+	return running
+}
+
+// StartTask marks expression of task as running, when agent acknowledges that it started evaluating the task
+func (s *Storage) StartTask(ctx context.Context, idTask int, idAgent int) error {
+	s.lock()
+	defer s.unlock()
+
+	t, ok := s.agentTask(idTask, idAgent)
+	if !ok {
+		return nil
+	}
+	if e := s.expressions[t.idExpression]; e.Status.CanBecome(models.Running) {
+		s.setStatus(e, models.Running)
+	}
+	return nil
+}
+
+// SaveTaskResult saves result of task and passes it to the task which waits for it.
+// Result of the last task of expression is result of expression
+func (s *Storage) SaveTaskResult(ctx context.Context, idTask int, result string, idAgent int) error {
+	s.lock()
+	defer s.unlock()
+
+	// task could be already given to another agent, then result is ignored
+	t, ok := s.agentTask(idTask, idAgent)
+	if !ok {
+		return storage.ErrNotFound
+	}
+	t.result, t.status = clone(&result), models.TaskSolved
+
+	e := s.expressions[t.idExpression]
+	if t.parentId != nil {
+		if parent, ok := s.tasks[*t.parentId]; ok {
+			parent.args[t.parentSlot] = clone(&result)
+		}
+
+		// agent could send result without acknowledging task
+		if e.Status == models.Scheduled {
+			s.setStatus(e, models.Running)
+		}
+	} else if e.Status.CanBecome(models.Completed) {
+		// result of exact expression is kept as is, and float result is its approximation
+		value, solved := number.Float(result), now()
+		e.Result, e.ExactResult, e.SolvedAt = &value, nil, &solved
+		if e.Precision == models.PrecisionExact {
+			e.ExactResult = clone(&result)
+		}
+		s.setStatus(e, models.Completed)
+
+		// identical expressions submitted later get this result at once
+		if e.CacheKey != nil {
+			if _, ok := s.results[*e.CacheKey]; !ok {
+				s.results[*e.CacheKey] = models.CachedResult{Result: value, ExactResult: clone(e.ExactResult)}
+			}
+		}
+	}
+
+	s.freeAgent(idAgent)
+	return nil
+}
+
+// SaveTaskError fails task which agent couldn't evaluate together with its expression.
+// Other tasks of expression are failed too, so they aren't given to agents anymore
+func (s *Storage) SaveTaskError(ctx context.Context, idTask int, taskErr models.ExpressionError, idAgent int) error {
+	s.lock()
+	defer s.unlock()
+
+	// as with result, task could be already given to another agent
+	t, ok := s.agentTask(idTask, idAgent)
+	if !ok {
+		return storage.ErrNotFound
+	}
+	t.status = models.TaskFailed
+
+	// agent which can't finish task before deadline times out the whole expression,
+	// other agents are told to abort its tasks like when expression is cancelled
+	status, tasksStatus := models.Failed, models.TaskFailed
+	if taskErr.Code == models.ErrorDeadlineExceeded {
+		status, tasksStatus = models.TimedOut, models.TaskCancelled
+	}
+
+	if e := s.expressions[t.idExpression]; e.Status.CanBecome(status) {
+		solved := now()
+		e.Error, e.SolvedAt = &taskErr, &solved
+		s.setStatus(e, status)
+	}
+	s.setTasksStatus(t.idExpression, tasksStatus)
+
+	s.freeAgent(idAgent)
+	return nil
+}
+
+// RequeueAgentTasks returns unfinished tasks of agent back to queue, e.g. when connection with agent is lost
+func (s *Storage) RequeueAgentTasks(ctx context.Context, id_agent int) error {
+	s.lock()
+	defer s.unlock()
+
+	requeued := s.requeueTasks(func(t *task) bool {
+		return t.idAgent != nil && *t.idAgent == id_agent
+	})
+	s.releaseExpressions(requeued)
+
+	if agent, ok := s.agents[id_agent]; ok && agent.Status == "busy" {
+		agent.Status = "free"
+	}
+	return nil
+}
+
+// RequeueStaleTasks marks agents without heartbeat for longer than timeout as dead and returns their tasks to queue.
+// Expressions which tasks were requeued more than maxRequeues times are failed.
+// Returns count of requeued tasks
+func (s *Storage) RequeueStaleTasks(ctx context.Context, timeout time.Duration, maxRequeues int) (int, error) {
+	s.lock()
+	defer s.unlock()
+
+	stale := now().Add(-timeout)
+	for _, agent := range s.agents {
+		if agent.Status != "dead" && agent.LastHeartbeat.Before(stale) {
+			agent.Status = "dead"
+		}
+	}
+
+	// tasks of agents which were removed are stale too
+	requeued := s.requeueTasks(func(t *task) bool {
+		if t.idAgent == nil {
+			return true
+		}
+		agent, ok := s.agents[*t.idAgent]
+		return !ok || agent.Status == "dead"
+	})
+	if len(requeued) == 0 {
+		return 0, nil
+	}
+
+	expressions := make(map[string]bool)
+	for _, id := range requeued {
+		expressions[id] = true
+	}
+	for id := range expressions {
+		e := s.expressions[id]
+		e.Requeues++
+		if e.Requeues <= maxRequeues || !e.Status.CanBecome(models.Failed) {
+			continue
+		}
+		e.Error = &models.ExpressionError{
+			Code:    models.ErrorRequeueLimit,
+			Message: fmt.Sprintf("tasks were taken back from dead agents more than %d times", maxRequeues),
+		}
+		s.setStatus(e, models.Failed)
+		for _, t := range s.tasks {
+			if t.idExpression == id && t.status != models.TaskSolved {
+				t.status = models.TaskFailed
+			}
+		}
+	}
+
+	s.releaseExpressions(requeued)
+	return len(requeued), nil
+}
+
+// TakeCancelledTasks returns ids of cancelled tasks, which agent was evaluating, and frees agent from them.
+// Every task is returned once, so agent is told to abort it once
+func (s *Storage) TakeCancelledTasks(ctx context.Context, idAgent int) ([]int, error) {
+	s.lock()
+	defer s.unlock()
+
+	var cancelled []int
+	for _, t := range s.tasks {
+		if t.idAgent != nil && *t.idAgent == idAgent && t.status == models.TaskCancelled {
+			t.idAgent = nil
+			cancelled = append(cancelled, t.id)
+		}
+	}
+	if len(cancelled) == 0 {
+		return nil, nil
+	}
+	sort.Ints(cancelled)
+
+	s.freeAgent(idAgent)
+	return cancelled, nil
+}
+
+// SaveProgress saves operation of task, which agent has evaluated, as step of trace of expression.
+// Watchers of expression and of identical expressions, which share its evaluation, are told about it.
+// Returns storage.ErrNotFound, if task isn't given to agent
+func (s *Storage) SaveProgress(ctx context.Context, progress models.ExpressionProgress) error {
+	s.lock()
+	defer s.unlock()
+
+	t, ok := s.tasks[progress.IdTask]
+	if !ok || t.idAgent == nil || *t.idAgent != progress.IdAgent {
+		return storage.ErrNotFound
+	}
+
+	operands := append([]string{}, progress.Operands...)
+	key := stepKey{idTask: t.id, step: progress.Step}
+	step, ok := s.steps[key]
+	if !ok {
+		s.lastStep++
+		step = &models.ExpressionStep{ID: s.lastStep, IdTask: t.id, Step: progress.Step}
+		s.steps[key] = step
+	}
+	// step is replaced, when task is evaluated again by another agent
+	step.Operation, step.Operands, step.Result = progress.Operation, operands, progress.Value
+	step.IdAgent, step.Calculator, step.DurationMs, step.FinishedAt = progress.IdAgent, progress.Calculator, progress.DurationMs, now()
+
+	for _, e := range s.expressions {
+		if e.IdExpression != t.idExpression && (e.IdOrigin == nil || *e.IdOrigin != t.idExpression) {
+			continue
+		}
+		published := progress
+		published.IdExpression = e.IdExpression
+		published.Operands = operands
+		s.updates = append(s.updates, models.ExpressionUpdate{IdExpression: e.IdExpression, Progress: &published})
+	}
+	return nil
+}
+
+// agentTask returns task, which agent is evaluating
+func (s *Storage) agentTask(idTask int, idAgent int) (*task, bool) {
+	t, ok := s.tasks[idTask]
+	if !ok || t.idAgent == nil || *t.idAgent != idAgent || t.status != models.TaskSolving {
+		return nil, false
+	}
+	return t, true
+}
+
+// requeueTasks returns tasks, which are being evaluated and match, back to queue.
+// Returns ids of their expressions, one for every task
+func (s *Storage) requeueTasks(match func(t *task) bool) []string {
+	var requeued []string
+	for _, t := range s.tasks {
+		if t.status == models.TaskSolving && match(t) {
+			t.status, t.idAgent = models.TaskNew, nil
+			requeued = append(requeued, t.idExpression)
+		}
+	}
+	return requeued
+}
+
+// releaseExpressions returns expressions, which tasks were just requeued and aren't evaluated by other agents, to pending.
+// Identical expressions follow their origins, so only origins are released
+func (s *Storage) releaseExpressions(requeued []string) {
+	solving := make(map[string]bool)
+	for _, t := range s.tasks {
+		if t.status == models.TaskSolving {
+			solving[t.idExpression] = true
+		}
+	}
+	for _, id := range requeued {
+		e, ok := s.expressions[id]
+		if !ok || e.IdOrigin != nil || solving[id] || !e.Status.CanBecome(models.Pending) {
+			continue
+		}
+		s.setStatus(e, models.Pending)
+	}
+}
+
+// freeAgent marks agent as free, if it isn't evaluating any task
+func (s *Storage) freeAgent(idAgent int) {
+	for _, t := range s.tasks {
+		if t.idAgent != nil && *t.idAgent == idAgent && t.status == models.TaskSolving {
+			return
+		}
+	}
+	if agent, ok := s.agents[idAgent]; ok {
+		agent.Status = "free"
+	}
+}
@@ -0,0 +1,155 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
+)
+
+// webhook is row of webhooks, which should be sent. Attempt is taken by moving nextAttempt to the end of its lease
+type webhook struct {
+	id           int
+	idExpression string
+	url          string
+	attempts     int
+	nextAttempt  time.Time
+	lastError    *string
+	created      time.Time
+}
+
+// deadWebhook is webhook, which wasn't delivered after all attempts
+type deadWebhook struct {
+	webhook
+	failedAt time.Time
+}
+
+// enqueueWebhook enqueues webhook, when expression with callback url gets final status
+func (s *Storage) enqueueWebhook(e *models.Expression) {
+	if e.CallbackUrl == nil || !e.Status.IsFinal() {
+		return
+	}
+
+	s.lastWebhook++
+	created := now()
+	s.webhooks[s.lastWebhook] = &webhook{
+		id:           s.lastWebhook,
+		idExpression: e.IdExpression,
+		url:          *e.CallbackUrl,
+		nextAttempt:  created,
+		created:      created,
+	}
+}
+
+// GetWebhookSecret returns secret, which webhooks of user are signed with. It's generated on the first call
+func (s *Storage) GetWebhookSecret(ctx context.Context, uid int) (string, error) {
+	s.lock()
+	defer s.unlock()
+
+	if _, ok := s.secrets[uid]; !ok {
+		s.secrets[uid] = newSecret()
+	}
+	return s.secrets[uid], nil
+}
+
+// RotateWebhookSecret generates new secret of user. Webhooks, which aren't sent yet, are signed with it too
+func (s *Storage) RotateWebhookSecret(ctx context.Context, uid int) (string, error) {
+	s.lock()
+	defer s.unlock()
+
+	s.secrets[uid] = newSecret()
+	return s.secrets[uid], nil
+}
+
+// TakeWebhooks returns up to limit webhooks, which should be sent now. They aren't returned again during lease,
+// so if sender dies, webhook is sent again after it
+func (s *Storage) TakeWebhooks(ctx context.Context, limit int, lease time.Duration) ([]models.Webhook, error) {
+	s.lock()
+	defer s.unlock()
+
+	current := now()
+	var due []*webhook
+	for _, w := range s.webhooks {
+		if !w.nextAttempt.After(current) {
+			due = append(due, w)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].nextAttempt.Equal(due[j].nextAttempt) {
+			return due[i].nextAttempt.Before(due[j].nextAttempt)
+		}
+		return due[i].id < due[j].id
+	})
+	due = due[:min(limit, len(due))]
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].id < due[j].id
+	})
+
+	var webhooks []models.Webhook
+	for _, w := range due {
+		w.nextAttempt = current.Add(lease)
+
+		e := s.expressions[w.idExpression]
+		secret, ok := s.secrets[e.UserId]
+		if !ok {
+			continue
+		}
+		webhooks = append(webhooks, models.Webhook{
+			ID:       w.id,
+			Url:      w.url,
+			Attempts: w.attempts,
+			Secret:   secret,
+			Payload: models.WebhookPayload{
+				ID:          e.IdExpression,
+				Status:      e.Status,
+				Result:      clone(e.Result),
+				ExactResult: clone(e.ExactResult),
+				Error:       clone(e.Error),
+				CreatedAt:   e.CreatedAt,
+				SolvedAt:    clone(e.SolvedAt),
+				FinishedAt:  w.created,
+			},
+		})
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook forgets delivered webhook
+func (s *Storage) DeleteWebhook(ctx context.Context, id int) error {
+	s.lock()
+	defer s.unlock()
+
+	delete(s.webhooks, id)
+	return nil
+}
+
+// RetryWebhook remembers failed attempt of webhook, the next one is made after delay
+func (s *Storage) RetryWebhook(ctx context.Context, id int, lastError string, delay time.Duration) error {
+	s.lock()
+	defer s.unlock()
+
+	if w, ok := s.webhooks[id]; ok {
+		w.attempts++
+		w.lastError = &lastError
+		w.nextAttempt = now().Add(delay)
+	}
+	return nil
+}
+
+// FailWebhook moves webhook, which failed its last attempt, to dead letters
+func (s *Storage) FailWebhook(ctx context.Context, id int, lastError string) error {
+	s.lock()
+	defer s.unlock()
+
+	w, ok := s.webhooks[id]
+	if !ok {
+		return nil
+	}
+	delete(s.webhooks, id)
+
+	w.attempts++
+	w.lastError = &lastError
+	s.dead = append(s.dead, deadWebhook{webhook: *w, failedAt: now()})
+	return nil
+}
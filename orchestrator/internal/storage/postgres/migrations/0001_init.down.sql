@@ -0,0 +1,5 @@
+-- users and apps are left, they belong to sso. Triggers are dropped with their tables
+DROP TABLE webhook_secrets, expression_steps, tasks, idempotency_keys, webhooks_dead, webhooks,
+	expression_events, results_cache, expressions, operations, agents;
+
+DROP FUNCTION share_expression_status(), record_expression_event(), enqueue_webhook();
@@ -0,0 +1,194 @@
+-- Baseline schema of orchestrator, later changes are added as next versions.
+-- users and apps belong to sso and are created by its migrations, see Migrator
+
+CREATE TABLE expressions(
+	id VARCHAR(255) PRIMARY KEY DEFAULT gen_random_uuid()::text,
+	expression VARCHAR(255) NOT NULL,
+	uid INT NOT NULL REFERENCES users(id),
+	result FLOAT,
+	created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	solved_at TIMESTAMP,
+	status VARCHAR(255) NOT NULL DEFAULT 'pending',
+	id_agent INT,
+	requeues INT NOT NULL DEFAULT 0,
+	variables JSONB,
+	precision VARCHAR(255) NOT NULL DEFAULT 'float',
+	priority INT NOT NULL DEFAULT 0,
+	deadline TIMESTAMPTZ,
+	exact_result TEXT,
+	error JSONB,
+	cache_key TEXT,
+	id_origin VARCHAR(255) REFERENCES expressions(id) ON DELETE SET NULL,
+	callback_url TEXT
+);
+
+-- pages of expressions of user, see GetExpressionsForUser
+CREATE INDEX expressions_uid_created ON expressions (uid, created, id);
+CREATE INDEX expressions_uid_solved ON expressions (uid, solved_at, id) WHERE solved_at IS NOT NULL;
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX expressions_expression_trgm ON expressions USING gin (expression gin_trgm_ops);
+
+CREATE INDEX expressions_cache_key ON expressions (cache_key) WHERE id_origin IS NULL;
+CREATE INDEX expressions_origin ON expressions (id_origin) WHERE id_origin IS NOT NULL;
+
+CREATE TABLE results_cache(
+	key TEXT PRIMARY KEY,
+	result FLOAT NOT NULL,
+	exact_result TEXT,
+	created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- identical expression shares evaluation of its origin: it gets every status and result of origin.
+-- Cancelled or timed out origin hands its tasks over, so it isn't shared
+CREATE FUNCTION share_expression_status() RETURNS TRIGGER AS $$
+BEGIN
+	IF NEW.status IN ('cancelled', 'timed_out') THEN
+		RETURN NEW;
+	END IF;
+	UPDATE expressions
+	SET status = NEW.status, result = NEW.result, exact_result = NEW.exact_result, error = NEW.error,
+		solved_at = NEW.solved_at, id_agent = NEW.id_agent
+	WHERE id_origin = NEW.id AND status IN ('pending', 'scheduled', 'running');
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER share_expression_status AFTER UPDATE OF status ON expressions
+FOR EACH ROW WHEN (OLD.status IS DISTINCT FROM NEW.status) EXECUTE FUNCTION share_expression_status();
+
+CREATE TABLE expression_events(
+	id SERIAL PRIMARY KEY,
+	id_expression VARCHAR(255) NOT NULL REFERENCES expressions(id) ON DELETE CASCADE,
+	from_status VARCHAR(255),
+	status VARCHAR(255) NOT NULL,
+	id_agent INT,
+	created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- every transition is recorded, whichever query has made it.
+-- Watchers of expression get it at commit, see ListenExpressionUpdates
+CREATE FUNCTION record_expression_event() RETURNS TRIGGER AS $$
+DECLARE
+	event expression_events%ROWTYPE;
+BEGIN
+	IF TG_OP = 'INSERT' THEN
+		INSERT INTO expression_events (id_expression, status, id_agent)
+		VALUES (NEW.id, NEW.status, NEW.id_agent)
+		RETURNING * INTO event;
+	ELSIF OLD.status IS DISTINCT FROM NEW.status THEN
+		INSERT INTO expression_events (id_expression, from_status, status, id_agent)
+		VALUES (NEW.id, OLD.status, NEW.status, NEW.id_agent)
+		RETURNING * INTO event;
+	ELSE
+		RETURN NEW;
+	END IF;
+
+	PERFORM pg_notify('expression_events', json_build_object(
+		'id', event.id, 'idExpression', event.id_expression, 'from', event.from_status, 'status', event.status,
+		'agentId', event.id_agent, 'createdAt', to_char(event.created, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')
+	)::text);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER expression_events AFTER INSERT OR UPDATE OF status ON expressions
+FOR EACH ROW EXECUTE FUNCTION record_expression_event();
+
+-- webhooks which should be sent, attempt is taken by setting next_attempt to the end of its lease
+CREATE TABLE webhooks(
+	id SERIAL PRIMARY KEY,
+	id_expression VARCHAR(255) NOT NULL REFERENCES expressions(id) ON DELETE CASCADE,
+	url TEXT NOT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	next_attempt TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	last_error TEXT,
+	created TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX webhooks_next_attempt ON webhooks (next_attempt);
+
+-- webhooks which weren't delivered after all attempts
+CREATE TABLE webhooks_dead(
+	id INT PRIMARY KEY,
+	id_expression VARCHAR(255) NOT NULL REFERENCES expressions(id) ON DELETE CASCADE,
+	url TEXT NOT NULL,
+	attempts INT NOT NULL,
+	last_error TEXT,
+	created TIMESTAMPTZ NOT NULL,
+	failed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- webhook is sent once, when expression gets final status, including expressions which are finished at once
+CREATE FUNCTION enqueue_webhook() RETURNS TRIGGER AS $$
+BEGIN
+	IF NEW.callback_url IS NOT NULL AND NEW.status IN ('completed', 'failed', 'cancelled', 'timed_out')
+		AND (TG_OP = 'INSERT' OR OLD.status IS DISTINCT FROM NEW.status) THEN
+		INSERT INTO webhooks (id_expression, url) VALUES (NEW.id, NEW.callback_url);
+	END IF;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER enqueue_webhook AFTER INSERT OR UPDATE OF status ON expressions
+FOR EACH ROW EXECUTE FUNCTION enqueue_webhook();
+
+CREATE TABLE idempotency_keys(
+	uid INT NOT NULL REFERENCES users(id),
+	key VARCHAR(255) NOT NULL,
+	request_hash VARCHAR(255) NOT NULL,
+	id_expression VARCHAR(255) NOT NULL REFERENCES expressions(id) ON DELETE CASCADE,
+	created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (uid, key)
+);
+
+-- operands and results are kept as text, so exact rationals aren't rounded
+CREATE TABLE tasks(
+	id SERIAL PRIMARY KEY,
+	id_expression VARCHAR(255) NOT NULL REFERENCES expressions(id) ON DELETE CASCADE,
+	operation VARCHAR(255) NOT NULL,
+	args TEXT[] NOT NULL,
+	parent_id INT REFERENCES tasks(id) ON DELETE CASCADE,
+	parent_slot INT,
+	result TEXT,
+	id_agent INT,
+	status VARCHAR(255) NOT NULL DEFAULT 'new'
+);
+
+-- scheduler looks for ready tasks on every push to agents
+CREATE INDEX tasks_new ON tasks (id_expression) WHERE status = 'new';
+
+-- operations which agents performed evaluating tasks. Step is replaced, when task is evaluated again by another agent
+CREATE TABLE expression_steps(
+	id SERIAL PRIMARY KEY,
+	id_task INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+	step INT NOT NULL,
+	operation VARCHAR(255) NOT NULL,
+	operands TEXT[] NOT NULL,
+	result TEXT NOT NULL,
+	id_agent INT NOT NULL,
+	calculator INT NOT NULL,
+	duration_ms BIGINT NOT NULL,
+	finished_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (id_task, step)
+);
+
+CREATE TABLE operations(
+	operation VARCHAR(255) PRIMARY KEY,
+	duration_ms BIGINT NOT NULL
+);
+
+INSERT INTO operations (operation, duration_ms)
+VALUES ('+', 1000), ('-', 1000), ('*', 1000), ('/', 1000), ('^', 1000), ('neg', 1000),
+	('sqrt', 1000), ('abs', 1000), ('sin', 1000), ('cos', 1000), ('log', 1000), ('min', 1000), ('max', 1000);
+
+CREATE TABLE agents(
+	id SERIAL PRIMARY KEY,
+	last_heartbeat TIMESTAMP NOT NULL,
+	status VARCHAR(255) NOT NULL DEFAULT 'free'
+);
+
+-- webhooks of user are signed with its secret, gen_random_uuid is cryptographically random
+CREATE TABLE webhook_secrets(
+	uid INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+	secret TEXT NOT NULL DEFAULT replace(gen_random_uuid()::text || gen_random_uuid()::text, '-', '')
+);
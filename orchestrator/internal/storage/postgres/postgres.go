@@ -2,15 +2,19 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"log/slog"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/a-romash/grpc-calculator/orchestrator/internal/domain/models"
-	expressionparser "github.com/a-romash/grpc-calculator/orchestrator/internal/lib/expressionParser"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/lib/number"
+	"github.com/a-romash/grpc-calculator/orchestrator/internal/storage"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pkg/errors"
 )
@@ -25,7 +29,41 @@ func Connect(databaseUrl string) (db *Postgresql, err error) {
 	return ConnectWithConfig(config)
 }
 
+// how long orchestrator waits for migrations of sso at start
+const migrateTimeout = 2 * time.Minute
+
+// ConnectWithConfig connects to database and applies pending migrations
 func ConnectWithConfig(config *pgxpool.Config) (db *Postgresql, err error) {
+	db, err = OpenWithConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, err := db.Migrator(slog.Default())
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	// sso can be started later, migrations of orchestrator wait for its ones
+	ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+	defer cancel()
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		slog.Error("error migrating database")
+		db.Close()
+		return nil, err
+	}
+	slog.Info("database was successfully migrated", slog.Int("applied", applied))
+	return db, nil
+}
+
+// Open connects to database without applying migrations
+func Open(databaseUrl string) (db *Postgresql, err error) {
+	config := Config(databaseUrl)
+	return OpenWithConfig(config)
+}
+
+func OpenWithConfig(config *pgxpool.Config) (db *Postgresql, err error) {
 	for i := 0; i < 5; i++ {
 		p, err := pgxpool.NewWithConfig(context.Background(), config)
 		if err != nil || p == nil {
@@ -36,12 +74,6 @@ func ConnectWithConfig(config *pgxpool.Config) (db *Postgresql, err error) {
 		db = &Postgresql{
 			pool: p,
 		}
-		err = Init(db.pool)
-		if err != nil {
-			slog.Error("error initing database")
-			return nil, err
-		}
-		slog.Info("database was successfully init")
 		return db, nil
 	}
 	err = errors.Wrap(err, "timed out waiting to connect postgres")
@@ -92,41 +124,6 @@ func Config(databaseUrl string) *pgxpool.Config {
 	return dbConfig
 }
 
-func Init(p *pgxpool.Pool) (err error) {
-	const sql string = `
-	CREATE TABLE IF NOT EXISTS expressions(
-		id VARCHAR(255) PRIMARY KEY UNIQUE,
-		expression VARCHAR(255) NOT NULL,
-		uid SERIAL NOT NULL REFERENCES users(id),
-		result FLOAT,
-		created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		solved_at TIMESTAMP,
-		status VARCHAR(255) NOT NULL DEFAULT 'new'
-	);
-
-	CREATE TABLE IF NOT EXISTS agents(
-		id SERIAL PRIMARY KEY,
-		last_heartbeat TIMESTAMP NOT NULL,
-		status VARCHAR(255) NOT NULL DEFAULT 'free'
-	);
-
-	CREATE TABLE IF NOT EXISTS apps(
-		id SERIAL PRIMARY KEY,
-		name VARCHAR(255) NOT NULL UNIQUE,
-		secret VARCHAR(255) NOT NULL
-	);
-
-	CREATE TABLE IF NOT EXISTS users(
-		id SERIAL PRIMARY KEY,
-		email VARCHAR(255) NOT NULL UNIQUE,
-		pass_hash BYTEA NOT NULL
-	);
-	`
-
-	_, err = p.Exec(context.Background(), sql)
-	return err
-}
-
 // RegisterApp registers new app and returns id
 func (db *Postgresql) RegisterApp(ctx context.Context, name, secret string) (int64, error) {
 	const op = "storage.postgres.RegisterApp"
@@ -157,13 +154,14 @@ func (db *Postgresql) RegisterApp(ctx context.Context, name, secret string) (int
 	return id, nil
 }
 
-// Heartbeat updates last_heartbet of agent
+// Heartbeat updates last_heartbet of agent. Agent which was considered dead becomes free again
 func (db *Postgresql) Heartbeat(ctx context.Context, id_agent int) error {
 	const op = "storage.postgres.Heartbeat"
 
 	const sql = `
 	UPDATE agents
-	SET last_heartbeat = CURRENT_TIMESTAMP
+	SET last_heartbeat = CURRENT_TIMESTAMP,
+		status = CASE WHEN status = 'dead' THEN 'free' ELSE status END
 	WHERE id=$1;
 	`
 
@@ -189,224 +187,1474 @@ func (db *Postgresql) RemoveAgent(ctx context.Context, id_agent int) error {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	// tasks of removed agent are given to other agents
 	const sql2 = `
-	UPDATE expressions
-	SET status = 'new'
-	WHERE status = $1;
+	UPDATE tasks
+	SET status = 'new', id_agent = NULL
+	WHERE id_agent = $1 AND status = 'solving'
+	RETURNING id_expression;
 	`
 
-	_, err = db.pool.Exec(ctx, sql2, fmt.Sprintf("solving-%d", id_agent))
+	rows, err := db.pool.Query(ctx, sql2, id_agent)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	requeued, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = releaseExpressions(ctx, db.pool, requeued); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
 	return nil
 }
 
-// SaveExpression saves expression to db and returns id of expression.
-func (db *Postgresql) SaveExpression(ctx context.Context, expression *models.Expression, uid int) (string, error) {
-	const op = "storage.postgres.SaveExpression"
+// RequeueAgentTasks returns unfinished tasks of agent back to queue, e.g. when connection with agent is lost
+func (db *Postgresql) RequeueAgentTasks(ctx context.Context, id_agent int) error {
+	const op = "storage.postgres.RequeueAgentTasks"
 
 	const sql = `
-	INSERT INTO expressions (id, expression, uid)
-	VALUES ($1, $2, $3);
+	UPDATE tasks
+	SET status = 'new', id_agent = NULL
+	WHERE id_agent = $1 AND status = 'solving'
+	RETURNING id_expression;
 	`
 
-	_, err := db.pool.Exec(ctx, sql, expression.IdExpression, expression.InfinixExpression, uid)
+	rows, err := db.pool.Query(ctx, sql, id_agent)
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
-	return expression.IdExpression, nil
-}
-
-// SaveResult saves result of expression
-func (db *Postgresql) SaveResult(ctx context.Context, idExpression string, result float32, idAgent int) error {
-	const op = "storage.postgres.SaveResult"
-
-	const sql = `
-	UPDATE expressions
-	SET result=$1, status='solved', solved_at=CURRENT_TIMESTAMP
-	WHERE id=$2;
-	`
-	_, err := db.pool.Exec(ctx, sql, result, idExpression)
+	requeued, err := pgx.CollectRows(rows, pgx.RowTo[string])
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err = releaseExpressions(ctx, db.pool, requeued); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	const sql2 = `
 	UPDATE agents
 	SET status = 'free'
-	WHERE id = $1;
+	WHERE id = $1 AND status = 'busy';
 	`
 
-	_, err = db.pool.Exec(ctx, sql2, idAgent)
+	_, err = db.pool.Exec(ctx, sql2, id_agent)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+
 	return nil
 }
 
-// GetExpressionToEvaluate returns 1 'new' expression
-func (db *Postgresql) GetExpressionToEvaluate(ctx context.Context, id_agent int) (*models.Expression, error) {
-	const op = "storage.postgres.GetExpressionToEvaluate"
+// RequeueStaleTasks marks agents without heartbeat for longer than timeout as dead and returns their tasks to queue.
+// Expressions which tasks were requeued more than maxRequeues times are failed.
+// Returns count of requeued tasks
+func (db *Postgresql) RequeueStaleTasks(ctx context.Context, timeout time.Duration, maxRequeues int) (int, error) {
+	const op = "storage.postgres.RequeueStaleTasks"
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
 
 	const sql = `
-	SELECT id, expression FROM expressions
-	WHERE status = 'new';
+	UPDATE agents
+	SET status = 'dead'
+	WHERE status <> 'dead' AND last_heartbeat < CURRENT_TIMESTAMP - make_interval(secs => $1);
 	`
 
-	row := db.pool.QueryRow(ctx, sql)
-	var id, expression string
+	_, err = tx.Exec(ctx, sql, timeout.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
 
-	err := row.Scan(&id, &expression)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, err
-	} else if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+	// tasks of agents which were removed from db are stale too
+	const sql2 = `
+	UPDATE tasks
+	SET status = 'new', id_agent = NULL
+	WHERE status = 'solving' AND (
+		id_agent IS NULL OR id_agent NOT IN (
+			SELECT id FROM agents
+			WHERE status <> 'dead'
+		)
+	)
+	RETURNING id_expression;
+	`
+
+	rows, err := tx.Query(ctx, sql2)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	requeued, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(requeued) == 0 {
+		return 0, nil
 	}
 
-	tokens, err := expressionparser.ParseExpression(expression)
+	const sql3 = `
+	UPDATE expressions
+	SET requeues = requeues + 1
+	WHERE id = ANY($1);
+	`
+
+	_, err = tx.Exec(ctx, sql3, requeued)
 	if err != nil {
-		const sql3 = `
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	const sql4 = `
+	WITH failed AS (
 		UPDATE expressions
-		SET status='invalid'
-		WHERE id=$2;
+		SET status = 'failed', error = $3
+		WHERE id = ANY($1) AND requeues > $2 AND status = ANY($4)
+		RETURNING id
+	)
+	UPDATE tasks
+	SET status = 'failed'
+	WHERE status <> 'solved' AND id_expression IN (SELECT id FROM failed);
+	`
+
+	requeueErr := models.ExpressionError{
+		Code:    models.ErrorRequeueLimit,
+		Message: fmt.Sprintf("tasks were taken back from dead agents more than %d times", maxRequeues),
+	}
+	_, err = tx.Exec(ctx, sql4, requeued, maxRequeues, requeueErr, statusesBefore(models.Failed))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = releaseExpressions(ctx, tx, requeued); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return len(requeued), nil
+}
+
+// SaveExpression saves expression with its tasks to db and returns generated id of expression.
+// If expression has no tasks, its result is already known and expression is saved as solved.
+//
+// If key isn't nil and user already submitted expression with it, nothing is saved:
+// id of that expression is returned with replayed = true, or storage.ErrIdempotencyKeyUsed
+// if it was another expression
+func (db *Postgresql) SaveExpression(ctx context.Context, expression *models.Expression, uid int, key *models.IdempotencyKey) (id string, replayed bool, err error) {
+	const op = "storage.postgres.SaveExpression"
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if key != nil {
+		// Expired key is forgotten, so it can be used again
+		const sql = `
+		DELETE FROM idempotency_keys
+		WHERE uid = $1 AND key = $2 AND created < CURRENT_TIMESTAMP - make_interval(secs => $3);
 		`
-		_, err = db.pool.Exec(ctx, sql3, fmt.Sprintf("solving-%d", id_agent), id)
-		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+		const sql2 = `
+		SELECT request_hash, id_expression FROM idempotency_keys
+		WHERE uid = $1 AND key = $2;
+		`
+
+		if _, err = tx.Exec(ctx, sql, uid, key.Key, key.TTL.Seconds()); err != nil {
+			return "", false, fmt.Errorf("%s: %w", op, err)
 		}
 
-		return nil, fmt.Errorf("%s: %w", op, errors.New("expression is invalid"))
+		var requestHash string
+		err = tx.QueryRow(ctx, sql2, uid, key.Key).Scan(&requestHash, &id)
+		if err == nil {
+			if requestHash != key.RequestHash {
+				return "", false, storage.ErrIdempotencyKeyUsed
+			}
+			expression.IdExpression = id
+			return id, true, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return "", false, fmt.Errorf("%s: %w", op, err)
+		}
 	}
 
-	expressionModel := models.Create(expression, tokens, id)
+	if err = insertExpression(ctx, tx, expression, uid); err != nil {
+		return "", false, fmt.Errorf("%s: %w", op, err)
+	}
 
-	const sql2 = `
-	UPDATE expressions
-	SET status=$1
-	WHERE id=$2;
-	`
+	if key != nil {
+		const sql3 = `
+		INSERT INTO idempotency_keys (uid, key, request_hash, id_expression)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING;
+		`
 
-	_, err = db.pool.Exec(ctx, sql2, fmt.Sprintf("solving-%d", id_agent), id)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		tag, err := tx.Exec(ctx, sql3, uid, key.Key, key.RequestHash, expression.IdExpression)
+		if err != nil {
+			return "", false, fmt.Errorf("%s: %w", op, err)
+		}
+		if tag.RowsAffected() == 0 {
+			// Concurrent request with the same key was saved first, so answer as its replay
+			tx.Rollback(ctx)
+			return db.SaveExpression(ctx, expression, uid, key)
+		}
 	}
 
-	const sql3 = `
-	UPDATE agents
-	SET status=$1
-	WHERE id=$2;
-	`
+	if err = tx.Commit(ctx); err != nil {
+		return "", false, fmt.Errorf("%s: %w", op, err)
+	}
+	return expression.IdExpression, false, nil
+}
+
+// SaveExpressions saves valid expressions of batch with their tasks in one transaction.
+// Generated ids are set to expressions, either all of them are saved or none
+func (db *Postgresql) SaveExpressions(ctx context.Context, expressions []*models.Expression, uid int) error {
+	const op = "storage.postgres.SaveExpressions"
 
-	_, err = db.pool.Exec(ctx, sql3, fmt.Sprintf("solving-%s", id), id_agent)
+	tx, err := db.pool.Begin(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, expression := range expressions {
+		if err = insertExpression(ctx, tx, expression, uid); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
 	}
 
-	return &expressionModel, nil
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
 }
 
-// RegisterNewAgent registers new agent and returns id
-func (db *Postgresql) RegisterNewAgent(ctx context.Context) (int, error) {
-	const op = "storage.postgres.RegisterNewAgent"
+// insertExpression inserts expression with its tasks and sets generated id, status and deadline of expression.
+// If identical expression is being evaluated, expression shares its evaluation instead of having own tasks
+func insertExpression(ctx context.Context, tx pgx.Tx, expression *models.Expression, uid int) error {
+	if expression.CallbackUrl != nil {
+		// webhook can't be sent without secret of user
+		const sql = `
+		INSERT INTO webhook_secrets (uid) VALUES ($1)
+		ON CONFLICT DO NOTHING;
+		`
 
-	const sql string = `
-	INSERT INTO agents (last_heartbeat)
-	VALUES (CURRENT_TIMESTAMP) 
+		if _, err := tx.Exec(ctx, sql, uid); err != nil {
+			return err
+		}
+	}
+
+	shared, err := insertSharedExpression(ctx, tx, expression, uid)
+	if err != nil || shared {
+		return err
+	}
+
+	if expression.Result != nil {
+		const sql = `
+		INSERT INTO expressions (expression, uid, variables, precision, priority, cache_key, callback_url, result, exact_result, status, solved_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 'completed', CURRENT_TIMESTAMP)
+		RETURNING id;
+		`
+
+		err = tx.QueryRow(ctx, sql, expression.InfinixExpression, uid, expression.Variables, expression.Precision, expression.Priority, expression.CacheKey, expression.CallbackUrl, *expression.Result, expression.ExactResult).Scan(&expression.IdExpression)
+		expression.Status = models.Completed
+	} else {
+		const sql = `
+		INSERT INTO expressions (expression, uid, variables, precision, priority, cache_key, callback_url, deadline)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, CASE WHEN $8::float8 > 0 THEN CURRENT_TIMESTAMP + make_interval(secs => $8) END)
+		RETURNING id, deadline;
+		`
+
+		err = tx.QueryRow(ctx, sql, expression.InfinixExpression, uid, expression.Variables, expression.Precision, expression.Priority, expression.CacheKey, expression.CallbackUrl, expression.Timeout.Seconds()).Scan(&expression.IdExpression, &expression.Deadline)
+		expression.Status = models.Pending
+	}
+	if err != nil {
+		return err
+	}
+
+	const sql2 = `
+	INSERT INTO tasks (id_expression, operation, args, parent_id, parent_slot)
+	VALUES ($1, $2, $3, $4, $5)
 	RETURNING id;
 	`
 
-	var id int
+	// Tasks go in postfix order, so parent of task is always after it.
+	// Inserting them from the end we know id of parent for every task
+	for i := len(expression.Tasks) - 1; i >= 0; i-- {
+		task := expression.Tasks[i]
+		task.IdExpression = expression.IdExpression
+
+		var (
+			parentId   *int
+			parentSlot *int
+		)
+		if task.Parent != nil {
+			parentId = &task.Parent.ID
+			parentSlot = &task.ParentSlot
+		}
 
-	row := db.pool.QueryRow(ctx, sql)
-	err := row.Scan(&id)
-	if err != nil {
-		return -1, fmt.Errorf("%s: %w", op, err)
+		row := tx.QueryRow(ctx, sql2, expression.IdExpression, task.Operation, task.Args, parentId, parentSlot)
+		if err = row.Scan(&task.ID); err != nil {
+			return err
+		}
 	}
-	return id, nil
+	return nil
 }
 
-// GetAllAgent returns all agents from database
-func (db *Postgresql) GetAllAgent(ctx context.Context) ([]models.Agent, error) {
-	const op = "storage.postgres.GetAllAgent"
+// insertSharedExpression inserts expression without tasks, if identical one without deadline is being evaluated.
+// Expression gets status of that origin, and then all its statuses and result, see share_expression_status.
+// Expression with deadline always has own tasks, because origin can't be timed out for it
+func insertSharedExpression(ctx context.Context, tx pgx.Tx, expression *models.Expression, uid int) (bool, error) {
+	if expression.CacheKey == nil || expression.Result != nil || expression.Timeout > 0 {
+		return false, nil
+	}
 
-	const sql string = `
-	SELECT * FROM agents;
+	// origin is locked, so it doesn't change status before expression is saved
+	const sql = `
+	SELECT id, status, id_agent FROM expressions
+	WHERE cache_key = $1 AND id_origin IS NULL AND deadline IS NULL AND status = ANY($2)
+	ORDER BY created, id
+	LIMIT 1
+	FOR SHARE;
 	`
 
-	rows, err := db.pool.Query(ctx, sql)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+	var (
+		idOrigin string
+		status   models.Status
+		idAgent  *int
+	)
+	err := tx.QueryRow(ctx, sql, *expression.CacheKey, unfinishedStatuses()).Scan(&idOrigin, &status, &idAgent)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		return false, err
 	}
 
-	agents, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Agent])
+	const sql2 = `
+	INSERT INTO expressions (expression, uid, variables, precision, priority, cache_key, callback_url, id_origin, status, id_agent)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	RETURNING id;
+	`
+
+	err = tx.QueryRow(ctx, sql2, expression.InfinixExpression, uid, expression.Variables, expression.Precision, expression.Priority, expression.CacheKey, expression.CallbackUrl, idOrigin, status, idAgent).Scan(&expression.IdExpression)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	return agents, nil
+	expression.Status = status
+	expression.IdOrigin = &idOrigin
+	expression.Tasks = nil
+	return true, nil
 }
 
-// GetExpressionsForUser returns all expressions (solved and not) for exactly user
-func (db *Postgresql) GetExpressionsForUser(ctx context.Context, uid int) ([]models.Expression, error) {
-	const op = "storage.postgres.GetExpressionsForUser"
+// GetCachedResult returns result of completed expression with the same cache key
+func (db *Postgresql) GetCachedResult(ctx context.Context, key string) (*models.CachedResult, error) {
+	const op = "storage.postgres.GetCachedResult"
 
 	const sql = `
-	SELECT * FROM expressions
-	WHERE uid = $1;
+	SELECT result, exact_result FROM results_cache
+	WHERE key = $1;
 	`
 
-	rows, err := db.pool.Query(ctx, sql, uid)
-	if err != nil {
+	var cached models.CachedResult
+	err := db.pool.QueryRow(ctx, sql, key).Scan(&cached.Result, &cached.ExactResult)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	} else if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	return &cached, nil
+}
 
-	expressions, err := pgx.CollectRows(rows, pgx.RowToStructByNameLax[models.Expression])
+// SaveTaskResult saves result of task and passes it to the task which waits for it.
+// Result of the last task of expression is result of expression
+func (db *Postgresql) SaveTaskResult(ctx context.Context, idTask int, result string, idAgent int) error {
+	const op = "storage.postgres.SaveTaskResult"
+
+	tx, err := db.pool.Begin(ctx)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("%s: %w", op, err)
 	}
-	return expressions, nil
-}
-
-func (db *Postgresql) GetExpressionById(ctx context.Context, id string, uid int) (*models.Expression, error) {
-	const op = "storage.postgres.GetExpressionById"
+	defer tx.Rollback(ctx)
 
+	// task could be already given to another agent, then result is ignored
 	const sql = `
-	SELECT * FROM expressions
-	WHERE id = $1 AND uid = $2;
+	UPDATE tasks
+	SET result = $1, status = 'solved'
+	WHERE id = $2 AND id_agent = $3 AND status = 'solving'
+	RETURNING id_expression, parent_id, parent_slot;
 	`
 
-	rows, err := db.pool.Query(ctx, sql, id, uid)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+	var (
+		idExpression string
+		parentId     *int
+		parentSlot   *int
+	)
+
+	row := tx.QueryRow(ctx, sql, result, idTask, idAgent)
+	err = row.Scan(&idExpression, &parentId, &parentSlot)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
-	expressions, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Expression])
+
+	if parentId != nil {
+		// arrays in postgres are indexed from 1
+		const sql2 = `
+		UPDATE tasks
+		SET args[$1] = $2
+		WHERE id = $3;
+		`
+
+		_, err = tx.Exec(ctx, sql2, *parentSlot+1, result, *parentId)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		// agent could send result without acknowledging task
+		const sql3 = `
+		UPDATE expressions
+		SET status = 'running'
+		WHERE id = $1 AND status = 'scheduled';
+		`
+
+		_, err = tx.Exec(ctx, sql3, idExpression)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	} else {
+		// result of exact expression is kept as is, and float result is its approximation
+		const sql2 = `
+		UPDATE expressions
+		SET result = $1, exact_result = CASE WHEN precision = 'exact' THEN $2 END,
+			status = 'completed', solved_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND status = ANY($4);
+		`
+
+		_, err = tx.Exec(ctx, sql2, number.Float(result), result, idExpression, statusesBefore(models.Completed))
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		// identical expressions submitted later get this result at once
+		const sql3 = `
+		INSERT INTO results_cache (key, result, exact_result)
+		SELECT cache_key, result, exact_result FROM expressions
+		WHERE id = $1 AND cache_key IS NOT NULL AND status = 'completed'
+		ON CONFLICT DO NOTHING;
+		`
+
+		_, err = tx.Exec(ctx, sql3, idExpression)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	const sql3 = `
+	UPDATE agents
+	SET status = 'free'
+	WHERE id = $1 AND NOT EXISTS (
+		SELECT 1 FROM tasks
+		WHERE id_agent = $1 AND status = 'solving'
+	);
+	`
+
+	_, err = tx.Exec(ctx, sql3, idAgent)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("%s: %w", op, err)
 	}
-	if len(expressions) == 0 {
-		return nil, pgx.ErrNoRows
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
-	expression := &expressions[0]
-	return expression, nil
+	return nil
 }
 
-// GetResultOfExpression returns result of exactly expression
-func (db *Postgresql) GetResultOfExpression(ctx context.Context, id string) (float32, error) {
-	const op = "storage.postgres.GetResultOfExpression"
+// CancelExpression cancels expression of user with its unfinished tasks.
+// Tasks which agents are evaluating keep id of agent, so agents can be told to abort them, see TakeCancelledTasks.
+// If other expressions share evaluation of this one, its tasks are handed over to one of them instead.
+// Returns storage.ErrExpressionFinished, if expression can't be cancelled anymore
+func (db *Postgresql) CancelExpression(ctx context.Context, id string, uid int) error {
+	const op = "storage.postgres.CancelExpression"
 
-	const sql = `
-	SELECT (result) FROM expressions
-	WHERE id = $1 AND status = 'solved';
-	`
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
 
-	row := db.pool.QueryRow(ctx, sql, id)
+	// tasks are locked before expression like when result is saved, otherwise they would wait for each other
+	const sql0 = `
+	SELECT id FROM tasks
+	WHERE id_expression = $1
+	FOR UPDATE;
+	`
 
-	var result float32
-	err := row.Scan(&result)
-	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, err)
+	if _, err = tx.Exec(ctx, sql0, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	return result, nil
+	const sql = `
+	UPDATE expressions
+	SET status = 'cancelled'
+	WHERE id = $1 AND uid = $2 AND status = ANY($3);
+	`
+
+	tag, err := tx.Exec(ctx, sql, id, uid, statusesBefore(models.Cancelled))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		// expression either doesn't exist or has finished meanwhile
+		const sql2 = `
+		SELECT 1 FROM expressions
+		WHERE id = $1 AND uid = $2;
+		`
+
+		var exists int
+		err = tx.QueryRow(ctx, sql2, id, uid).Scan(&exists)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.ErrNotFound
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return storage.ErrExpressionFinished
+	}
+
+	handedOver, err := handOverTasks(ctx, tx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !handedOver {
+		const sql3 = `
+		UPDATE tasks
+		SET status = 'cancelled'
+		WHERE id_expression = $1 AND status IN ('new', 'solving');
+		`
+
+		if _, err = tx.Exec(ctx, sql3, id); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// handOverTasks gives tasks of expression to the oldest unfinished expression, which shares its evaluation.
+// That one becomes origin of the others. Returns false, if there is no such expression
+func handOverTasks(ctx context.Context, tx pgx.Tx, id string) (bool, error) {
+	const sql = `
+	SELECT id FROM expressions
+	WHERE id_origin = $1 AND status = ANY($2)
+	ORDER BY created, id
+	LIMIT 1;
+	`
+
+	var heir string
+	err := tx.QueryRow(ctx, sql, id, unfinishedStatuses()).Scan(&heir)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	const sql2 = `
+	UPDATE expressions
+	SET id_origin = CASE WHEN id = $2 THEN NULL ELSE $2 END
+	WHERE id_origin = $1;
+	`
+
+	if _, err = tx.Exec(ctx, sql2, id, heir); err != nil {
+		return false, err
+	}
+
+	const sql3 = `
+	UPDATE tasks
+	SET id_expression = $2
+	WHERE id_expression = $1;
+	`
+
+	if _, err = tx.Exec(ctx, sql3, id, heir); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// TimeOutExpressions times out expressions, which weren't completed before deadline, and returns their count.
+// Their unfinished tasks are cancelled, so agents evaluating them are told to abort, see TakeCancelledTasks
+func (db *Postgresql) TimeOutExpressions(ctx context.Context) (int, error) {
+	const op = "storage.postgres.TimeOutExpressions"
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	const sql = `
+	UPDATE expressions
+	SET status = 'timed_out', error = $1, solved_at = CURRENT_TIMESTAMP
+	WHERE deadline <= CURRENT_TIMESTAMP AND status = ANY($2)
+	RETURNING id;
+	`
+
+	timeoutErr := models.ExpressionError{
+		Code:    models.ErrorDeadlineExceeded,
+		Message: "expression wasn't completed before deadline",
+	}
+	rows, err := tx.Query(ctx, sql, timeoutErr, statusesBefore(models.TimedOut))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	timedOut, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(timedOut) == 0 {
+		return 0, nil
+	}
+
+	const sql2 = `
+	UPDATE tasks
+	SET status = 'cancelled'
+	WHERE id_expression = ANY($1) AND status IN ('new', 'solving');
+	`
+
+	if _, err = tx.Exec(ctx, sql2, timedOut); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return len(timedOut), nil
+}
+
+// TakeCancelledTasks returns ids of cancelled tasks, which agent was evaluating, and frees agent from them.
+// Every task is returned once, so agent is told to abort it once
+func (db *Postgresql) TakeCancelledTasks(ctx context.Context, idAgent int) ([]int, error) {
+	const op = "storage.postgres.TakeCancelledTasks"
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	const sql = `
+	UPDATE tasks
+	SET id_agent = NULL
+	WHERE id_agent = $1 AND status = 'cancelled'
+	RETURNING id;
+	`
+
+	rows, err := tx.Query(ctx, sql, idAgent)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	cancelled, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(cancelled) == 0 {
+		return nil, nil
+	}
+
+	const sql2 = `
+	UPDATE agents
+	SET status = 'free'
+	WHERE id = $1 AND NOT EXISTS (
+		SELECT 1 FROM tasks
+		WHERE id_agent = $1 AND status = 'solving'
+	);
+	`
+
+	if _, err = tx.Exec(ctx, sql2, idAgent); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return cancelled, nil
+}
+
+// StartTask marks expression of task as running, when agent acknowledges that it started evaluating the task
+func (db *Postgresql) StartTask(ctx context.Context, idTask int, idAgent int) error {
+	const op = "storage.postgres.StartTask"
+
+	const sql = `
+	UPDATE expressions
+	SET status = 'running'
+	WHERE status = ANY($3) AND id = (
+		SELECT id_expression FROM tasks
+		WHERE id = $1 AND id_agent = $2 AND status = 'solving'
+	);
+	`
+
+	_, err := db.pool.Exec(ctx, sql, idTask, idAgent, statusesBefore(models.Running))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// SaveTaskError fails task which agent couldn't evaluate together with its expression.
+// Other tasks of expression are failed too, so they aren't given to agents anymore
+func (db *Postgresql) SaveTaskError(ctx context.Context, idTask int, taskErr models.ExpressionError, idAgent int) error {
+	const op = "storage.postgres.SaveTaskError"
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	// as with result, task could be already given to another agent
+	const sql = `
+	UPDATE tasks
+	SET status = 'failed'
+	WHERE id = $1 AND id_agent = $2 AND status = 'solving'
+	RETURNING id_expression;
+	`
+
+	var idExpression string
+	err = tx.QueryRow(ctx, sql, idTask, idAgent).Scan(&idExpression)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// agent which can't finish task before deadline times out the whole expression,
+	// other agents are told to abort its tasks like when expression is cancelled
+	status, tasksStatus := models.Failed, models.TaskFailed
+	if taskErr.Code == models.ErrorDeadlineExceeded {
+		status, tasksStatus = models.TimedOut, models.TaskCancelled
+	}
+
+	const sql2 = `
+	UPDATE expressions
+	SET status = $1, error = $2, solved_at = CURRENT_TIMESTAMP
+	WHERE id = $3 AND status = ANY($4);
+	`
+
+	_, err = tx.Exec(ctx, sql2, status, taskErr, idExpression, statusesBefore(status))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	const sql3 = `
+	UPDATE tasks
+	SET status = $2
+	WHERE id_expression = $1 AND status IN ('new', 'solving');
+	`
+
+	_, err = tx.Exec(ctx, sql3, idExpression, tasksStatus)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	const sql4 = `
+	UPDATE agents
+	SET status = 'free'
+	WHERE id = $1 AND NOT EXISTS (
+		SELECT 1 FROM tasks
+		WHERE id_agent = $1 AND status = 'solving'
+	);
+	`
+
+	_, err = tx.Exec(ctx, sql4, idAgent)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GetReadyQueues returns queues which have tasks ready for evaluating, from the highest priority.
+// If maxRunning isn't 0, pending expressions of user, who already has maxRunning scheduled or running ones, aren't counted
+func (db *Postgresql) GetReadyQueues(ctx context.Context, maxRunning int) ([]models.Queue, error) {
+	const op = "storage.postgres.GetReadyQueues"
+
+	const sql = `
+	SELECT DISTINCT e.priority, e.uid FROM tasks t
+	JOIN expressions e ON e.id = t.id_expression
+	WHERE t.status = 'new' AND array_position(t.args, NULL) IS NULL
+	AND (e.deadline IS NULL OR e.deadline > CURRENT_TIMESTAMP)
+	AND ($1 = 0 OR e.status IN ('scheduled', 'running') OR (
+		SELECT COUNT(*) FROM expressions r
+		WHERE r.uid = e.uid AND r.status IN ('scheduled', 'running') AND r.id_origin IS NULL
+	) < $1)
+	ORDER BY e.priority DESC, e.uid;
+	`
+
+	rows, err := db.pool.Query(ctx, sql, maxRunning)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	queues, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Queue])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return queues, nil
+}
+
+// GetTask returns 1 'new' task from queue, which operands are all known, and gives it to agent.
+// Task is claimed in one transaction: row is locked with FOR UPDATE SKIP LOCKED,
+// so concurrent agents never get the same task.
+// maxRunning limits scheduled and running expressions of user like in GetReadyQueues, 0 is no limit
+func (db *Postgresql) GetTask(ctx context.Context, id_agent int, queue models.Queue, maxRunning int) (*models.Task, error) {
+	const op = "storage.postgres.GetTask"
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if maxRunning > 0 {
+		// tasks of user are claimed one by one, otherwise concurrent agents could start more expressions than limit
+		if _, err = tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1);`, queue.Uid); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	const sql = `
+	SELECT t.id, t.id_expression, t.operation, t.args, COALESCE(o.duration_ms, 0), e.precision = 'exact', e.deadline FROM tasks t
+	JOIN expressions e ON e.id = t.id_expression
+	LEFT JOIN operations o ON o.operation = t.operation
+	WHERE t.status = 'new' AND array_position(t.args, NULL) IS NULL
+	AND (e.deadline IS NULL OR e.deadline > CURRENT_TIMESTAMP)
+	AND e.uid = $1 AND e.priority = $2
+	AND ($3 = 0 OR e.status IN ('scheduled', 'running') OR (
+		SELECT COUNT(*) FROM expressions r
+		WHERE r.uid = $1 AND r.status IN ('scheduled', 'running') AND r.id_origin IS NULL
+	) < $3)
+	ORDER BY t.id
+	LIMIT 1
+	FOR UPDATE OF t SKIP LOCKED;
+	`
+
+	var (
+		task       models.Task
+		durationMs int64
+	)
+
+	row := tx.QueryRow(ctx, sql, queue.Uid, queue.Priority, maxRunning)
+	err = row.Scan(&task.ID, &task.IdExpression, &task.Operation, &task.Args, &durationMs, &task.Exact, &task.Deadline)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	const sql2 = `
+	UPDATE tasks
+	SET status = 'solving', id_agent = $1
+	WHERE id = $2;
+	`
+
+	_, err = tx.Exec(ctx, sql2, id_agent, task.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// expression which is already running stays running
+	const sql3 = `
+	UPDATE expressions
+	SET status = CASE WHEN status = ANY($3) THEN 'scheduled' ELSE status END, id_agent = $2
+	WHERE id = $1;
+	`
+
+	_, err = tx.Exec(ctx, sql3, task.IdExpression, id_agent, statusesBefore(models.Scheduled))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	const sql4 = `
+	UPDATE agents
+	SET status = 'busy'
+	WHERE id = $1;
+	`
+
+	_, err = tx.Exec(ctx, sql4, id_agent)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	task.IdAgent = id_agent
+	task.Status = models.TaskSolving
+	task.Duration = time.Duration(durationMs) * time.Millisecond
+	return &task, nil
+}
+
+// RegisterNewAgent registers new agent and returns id
+func (db *Postgresql) RegisterNewAgent(ctx context.Context) (int, error) {
+	const op = "storage.postgres.RegisterNewAgent"
+
+	const sql string = `
+	INSERT INTO agents (last_heartbeat)
+	VALUES (CURRENT_TIMESTAMP) 
+	RETURNING id;
+	`
+
+	var id int
+
+	row := db.pool.QueryRow(ctx, sql)
+	err := row.Scan(&id)
+	if err != nil {
+		return -1, fmt.Errorf("%s: %w", op, err)
+	}
+	return id, nil
+}
+
+// GetAllAgent returns all agents from database
+func (db *Postgresql) GetAllAgent(ctx context.Context) ([]models.Agent, error) {
+	const op = "storage.postgres.GetAllAgent"
+
+	const sql string = `
+	SELECT * FROM agents;
+	`
+
+	rows, err := db.pool.Query(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	agents, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Agent])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return agents, nil
+}
+
+// GetOperations returns all operations with their durations
+func (db *Postgresql) GetOperations(ctx context.Context) ([]models.OperationDuration, error) {
+	const op = "storage.postgres.GetOperations"
+
+	const sql = `
+	SELECT operation, duration_ms FROM operations
+	ORDER BY operation;
+	`
+
+	rows, err := db.pool.Query(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	operations, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.OperationDuration, error) {
+		var (
+			operation  models.OperationDuration
+			durationMs int64
+		)
+		err := row.Scan(&operation.Operation, &durationMs)
+		operation.Duration = time.Duration(durationMs) * time.Millisecond
+		return operation, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return operations, nil
+}
+
+// SetOperationDuration changes duration of operation. Agents use it for every task given after that
+func (db *Postgresql) SetOperationDuration(ctx context.Context, operation string, duration time.Duration) error {
+	const op = "storage.postgres.SetOperationDuration"
+
+	const sql = `
+	UPDATE operations
+	SET duration_ms = $1
+	WHERE operation = $2;
+	`
+
+	tag, err := db.pool.Exec(ctx, sql, duration.Milliseconds(), operation)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// GetExpressionsForUser returns page of expressions of user selected by filter and cursor
+// of the next page, which is nil for the last page
+func (db *Postgresql) GetExpressionsForUser(ctx context.Context, uid int, filter models.ExpressionFilter) ([]models.Expression, *models.Cursor, error) {
+	const op = "storage.postgres.GetExpressionsForUser"
+
+	// column and direction can't be parameters, so they are chosen from constants
+	column := "created"
+	if filter.SortBy == models.SortBySolved {
+		column = "solved_at"
+	}
+	direction, compare := "DESC", "<"
+	if filter.Ascending {
+		direction, compare = "ASC", ">"
+	}
+
+	sql := fmt.Sprintf(`
+	SELECT * FROM expressions
+	WHERE uid = $1 AND %[1]s IS NOT NULL
+		AND (cardinality($2::text[]) = 0 OR status = ANY($2))
+		AND ($3::timestamp IS NULL OR created >= $3)
+		AND ($4::timestamp IS NULL OR created < $4)
+		AND ($5::timestamp IS NULL OR solved_at >= $5)
+		AND ($6::timestamp IS NULL OR solved_at < $6)
+		AND ($7 = '' OR expression ILIKE '%%' || $7 || '%%')
+		AND ($8::timestamp IS NULL OR (%[1]s, id) %[3]s ($8, $9))
+	ORDER BY %[1]s %[2]s, id %[2]s
+	LIMIT $10;
+	`, column, direction, compare)
+
+	statuses := make([]string, len(filter.Statuses))
+	for i, status := range filter.Statuses {
+		statuses[i] = string(status)
+	}
+	var (
+		after   *time.Time
+		afterId string
+	)
+	if filter.Cursor != nil {
+		after, afterId = &filter.Cursor.Time, filter.Cursor.Id
+	}
+
+	// one more expression tells whether there is the next page
+	rows, err := db.pool.Query(ctx, sql, uid, statuses,
+		utc(filter.CreatedFrom), utc(filter.CreatedTo), utc(filter.SolvedFrom), utc(filter.SolvedTo),
+		likePattern.Replace(filter.Contains), utc(after), afterId, filter.Limit+1)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	expressions, err := pgx.CollectRows(rows, pgx.RowToStructByNameLax[models.Expression])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(expressions) <= filter.Limit {
+		return expressions, nil, nil
+	}
+
+	expressions = expressions[:filter.Limit]
+	last := expressions[len(expressions)-1]
+	next := &models.Cursor{SortBy: filter.SortBy, Ascending: filter.Ascending, Time: last.CreatedAt, Id: last.IdExpression}
+	if filter.SortBy == models.SortBySolved {
+		next.Time = *last.SolvedAt
+	}
+	return expressions, next, nil
+}
+
+// likePattern escapes special characters of LIKE, so substring is searched as is
+var likePattern = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// utc returns time in UTC: timestamps without time zone are read as UTC, so they are compared in it
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+func (db *Postgresql) GetExpressionById(ctx context.Context, id string, uid int) (*models.Expression, error) {
+	const op = "storage.postgres.GetExpressionById"
+
+	const sql = `
+	SELECT * FROM expressions
+	WHERE id = $1 AND uid = $2;
+	`
+
+	rows, err := db.pool.Query(ctx, sql, id, uid)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	expressions, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Expression])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(expressions) == 0 {
+		return nil, storage.ErrNotFound
+	}
+	expression := &expressions[0]
+	return expression, nil
+}
+
+// GetExpressionsByIds returns expressions of user with given ids. Ids which aren't found are skipped
+func (db *Postgresql) GetExpressionsByIds(ctx context.Context, ids []string, uid int) ([]models.Expression, error) {
+	const op = "storage.postgres.GetExpressionsByIds"
+
+	const sql = `
+	SELECT * FROM expressions
+	WHERE id = ANY($1) AND uid = $2;
+	`
+
+	rows, err := db.pool.Query(ctx, sql, ids, uid)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	expressions, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Expression])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return expressions, nil
+}
+
+// GetExpressionEvents returns history of statuses of expression of user, from the oldest to the newest one
+func (db *Postgresql) GetExpressionEvents(ctx context.Context, id string, uid int) ([]models.ExpressionEvent, error) {
+	const op = "storage.postgres.GetExpressionEvents"
+
+	const sql = `
+	SELECT ev.* FROM expression_events ev
+	JOIN expressions e ON e.id = ev.id_expression
+	WHERE e.id = $1 AND e.uid = $2
+	ORDER BY ev.id;
+	`
+
+	rows, err := db.pool.Query(ctx, sql, id, uid)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	events, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.ExpressionEvent])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	// every expression has at least one event, when it's created
+	if len(events) == 0 {
+		return nil, storage.ErrNotFound
+	}
+	return events, nil
+}
+
+// GetExpressionSteps returns trace of expression of user: operations which agents performed to evaluate it.
+// Identical expression, which shares evaluation of another one, has trace of that one
+func (db *Postgresql) GetExpressionSteps(ctx context.Context, id string, uid int) ([]models.ExpressionStep, error) {
+	const op = "storage.postgres.GetExpressionSteps"
+
+	const sql = `
+	SELECT 1 FROM expressions
+	WHERE id = $1 AND uid = $2;
+	`
+	const sql2 = `
+	SELECT s.* FROM expression_steps s
+	JOIN tasks t ON t.id = s.id_task
+	JOIN expressions e ON t.id_expression = COALESCE(e.id_origin, e.id)
+	WHERE e.id = $1
+	ORDER BY s.finished_at, s.id;
+	`
+
+	var exists int
+	if err := db.pool.QueryRow(ctx, sql, id, uid).Scan(&exists); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := db.pool.Query(ctx, sql2, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	steps, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.ExpressionStep])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return steps, nil
+}
+
+// GetWebhookSecret returns secret, which webhooks of user are signed with. It's generated on the first call
+func (db *Postgresql) GetWebhookSecret(ctx context.Context, uid int) (string, error) {
+	const op = "storage.postgres.GetWebhookSecret"
+
+	// DO UPDATE instead of DO NOTHING, so existing secret is returned as well
+	const sql = `
+	INSERT INTO webhook_secrets (uid) VALUES ($1)
+	ON CONFLICT (uid) DO UPDATE SET secret = webhook_secrets.secret
+	RETURNING secret;
+	`
+
+	var secret string
+	if err := db.pool.QueryRow(ctx, sql, uid).Scan(&secret); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return secret, nil
+}
+
+// RotateWebhookSecret generates new secret of user. Webhooks, which aren't sent yet, are signed with it too
+func (db *Postgresql) RotateWebhookSecret(ctx context.Context, uid int) (string, error) {
+	const op = "storage.postgres.RotateWebhookSecret"
+
+	const sql = `
+	INSERT INTO webhook_secrets (uid) VALUES ($1)
+	ON CONFLICT (uid) DO UPDATE SET secret = EXCLUDED.secret
+	RETURNING secret;
+	`
+
+	var secret string
+	if err := db.pool.QueryRow(ctx, sql, uid).Scan(&secret); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return secret, nil
+}
+
+// TakeWebhooks returns up to limit webhooks, which should be sent now. They aren't returned again during lease,
+// so if sender dies, webhook is sent again after it
+func (db *Postgresql) TakeWebhooks(ctx context.Context, limit int, lease time.Duration) ([]models.Webhook, error) {
+	const op = "storage.postgres.TakeWebhooks"
+
+	const sql = `
+	WITH taken AS (
+		UPDATE webhooks SET next_attempt = CURRENT_TIMESTAMP + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id FROM webhooks
+			WHERE next_attempt <= CURRENT_TIMESTAMP
+			ORDER BY next_attempt
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, id_expression, url, attempts, created
+	)
+	SELECT t.id, t.url, t.attempts, s.secret,
+		e.id, e.status, e.result, e.exact_result, e.error, e.created, e.solved_at, t.created
+	FROM taken t
+	JOIN expressions e ON e.id = t.id_expression
+	JOIN webhook_secrets s ON s.uid = e.uid
+	ORDER BY t.id;
+	`
+
+	rows, err := db.pool.Query(ctx, sql, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		var w models.Webhook
+		p := &w.Payload
+		err = rows.Scan(&w.ID, &w.Url, &w.Attempts, &w.Secret,
+			&p.ID, &p.Status, &p.Result, &p.ExactResult, &p.Error, &p.CreatedAt, &p.SolvedAt, &p.FinishedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		webhooks = append(webhooks, w)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook forgets delivered webhook
+func (db *Postgresql) DeleteWebhook(ctx context.Context, id int) error {
+	const op = "storage.postgres.DeleteWebhook"
+
+	const sql = `
+	DELETE FROM webhooks
+	WHERE id = $1;
+	`
+
+	if _, err := db.pool.Exec(ctx, sql, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RetryWebhook remembers failed attempt of webhook, the next one is made after delay
+func (db *Postgresql) RetryWebhook(ctx context.Context, id int, lastError string, delay time.Duration) error {
+	const op = "storage.postgres.RetryWebhook"
+
+	const sql = `
+	UPDATE webhooks
+	SET attempts = attempts + 1, last_error = $2, next_attempt = CURRENT_TIMESTAMP + make_interval(secs => $3)
+	WHERE id = $1;
+	`
+
+	if _, err := db.pool.Exec(ctx, sql, id, lastError, delay.Seconds()); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// FailWebhook moves webhook, which failed its last attempt, to webhooks_dead
+func (db *Postgresql) FailWebhook(ctx context.Context, id int, lastError string) error {
+	const op = "storage.postgres.FailWebhook"
+
+	const sql = `
+	WITH dead AS (
+		DELETE FROM webhooks
+		WHERE id = $1
+		RETURNING id, id_expression, url, attempts + 1 AS attempts, created
+	)
+	INSERT INTO webhooks_dead (id, id_expression, url, attempts, last_error, created)
+	SELECT id, id_expression, url, attempts, $2, created FROM dead
+	ON CONFLICT DO NOTHING;
+	`
+
+	if _, err := db.pool.Exec(ctx, sql, id, lastError); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// SaveProgress saves operation of task, which agent has evaluated, as step of trace of expression.
+// Watchers of expression and of identical expressions, which share its evaluation, are told about it.
+// Returns storage.ErrNotFound, if task isn't given to agent
+func (db *Postgresql) SaveProgress(ctx context.Context, progress models.ExpressionProgress) error {
+	const op = "storage.postgres.SaveProgress"
+
+	payload, err := json.Marshal(progress)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// data-modifying WITH is executed, though the final SELECT doesn't read it
+	const sql = `
+	WITH task AS (
+		SELECT id, id_expression FROM tasks
+		WHERE id = $1 AND id_agent = $2
+	), saved AS (
+		INSERT INTO expression_steps (id_task, step, operation, operands, result, id_agent, calculator, duration_ms)
+		SELECT id, $4, $5, $6, $7, $2, $8, $9 FROM task
+		ON CONFLICT (id_task, step) DO UPDATE
+		SET operation = EXCLUDED.operation, operands = EXCLUDED.operands, result = EXCLUDED.result, id_agent = EXCLUDED.id_agent,
+			calculator = EXCLUDED.calculator, duration_ms = EXCLUDED.duration_ms, finished_at = EXCLUDED.finished_at
+	)
+	SELECT pg_notify('expression_progress', ($3::jsonb || jsonb_build_object('expressionId', e.id))::text)
+	FROM task t
+	JOIN expressions e ON e.id = t.id_expression OR e.id_origin = t.id_expression;
+	`
+
+	operands := progress.Operands
+	if operands == nil {
+		operands = []string{}
+	}
+	tag, err := db.pool.Exec(ctx, sql, progress.IdTask, progress.IdAgent, string(payload),
+		progress.Step, progress.Operation, operands, progress.Value, progress.Calculator, progress.DurationMs)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// eventNotification is payload of expression_events notification, see record_expression_event
+type eventNotification struct {
+	ID           int            `json:"id"`
+	IdExpression string         `json:"idExpression"`
+	FromStatus   *models.Status `json:"from"`
+	Status       models.Status  `json:"status"`
+	IdAgent      *int           `json:"agentId"`
+	CreatedAt    time.Time      `json:"createdAt"`
+}
+
+// ListenExpressionUpdates calls update with every transition and progress of expressions, which are committed
+// by any instance of orchestrator. Blocks until ctx is done or connection is lost
+func (db *Postgresql) ListenExpressionUpdates(ctx context.Context, update func(models.ExpressionUpdate)) error {
+	const op = "storage.postgres.ListenExpressionUpdates"
+
+	conn, err := db.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		// connection goes back to pool, so it shouldn't get notifications anymore
+		conn.Exec(context.Background(), "UNLISTEN *;")
+		conn.Release()
+	}()
+
+	if _, err = conn.Exec(ctx, "LISTEN expression_events; LISTEN expression_progress;"); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		switch notification.Channel {
+		case "expression_events":
+			var n eventNotification
+			if err = json.Unmarshal([]byte(notification.Payload), &n); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			update(models.ExpressionUpdate{
+				IdExpression: n.IdExpression,
+				Event: &models.ExpressionEvent{
+					ID:           n.ID,
+					IdExpression: n.IdExpression,
+					FromStatus:   n.FromStatus,
+					Status:       n.Status,
+					IdAgent:      n.IdAgent,
+					CreatedAt:    n.CreatedAt,
+				},
+			})
+		case "expression_progress":
+			var progress models.ExpressionProgress
+			if err = json.Unmarshal([]byte(notification.Payload), &progress); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			update(models.ExpressionUpdate{IdExpression: progress.IdExpression, Progress: &progress})
+		}
+	}
+}
+
+// releaseExpressions returns expressions, which tasks were just requeued and aren't evaluated by other agents, to pending.
+// Identical expressions follow their origins by trigger, so only origins are released
+func releaseExpressions(ctx context.Context, db executor, requeued []string) error {
+	if len(requeued) == 0 {
+		return nil
+	}
+
+	const sql = `
+	UPDATE expressions e
+	SET status = 'pending'
+	WHERE e.id = ANY($1) AND e.id_origin IS NULL AND e.status = ANY($2) AND NOT EXISTS (
+		SELECT 1 FROM tasks t
+		WHERE t.id_expression = e.id AND t.status = 'solving'
+	);
+	`
+
+	_, err := db.Exec(ctx, sql, requeued, statusesBefore(models.Pending))
+	return err
+}
+
+// executor is pool or transaction
+type executor interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// unfinishedStatuses returns statuses of expressions, which aren't finished yet. Any of them can be cancelled
+func unfinishedStatuses() []string {
+	return statusesBefore(models.Cancelled)
+}
+
+// statusesBefore returns statuses from which expression can get status, for queries like status = ANY($1)
+func statusesBefore(status models.Status) []string {
+	var statuses []string
+	for _, s := range models.StatusesBefore(status) {
+		statuses = append(statuses, string(s))
+	}
+	return statuses
 }
@@ -3,7 +3,10 @@ package storage
 import "errors"
 
 var (
-	ErrExpressionNotFound = errors.New("expression not found")
+	// ErrNotFound is returned by every storage, when expression, task or something else isn't found
+	ErrNotFound           = errors.New("not found")
 	ErrExpressionExists   = errors.New("expression already exists")
+	ErrIdempotencyKeyUsed = errors.New("idempotency key is already used for another expression")
+	ErrExpressionFinished = errors.New("expression is already finished")
 	ErrAppExists          = errors.New("app already exists") // lol, it's unrealistic error on first view imho
 )
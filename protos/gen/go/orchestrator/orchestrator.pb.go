@@ -0,0 +1,1036 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.33.0
+// 	protoc        v5.26.1
+// source: orchestrator/orchestrator.proto
+
+package orchestrator
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type IsAlive struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	IsAlive bool  `protobuf:"varint,1,opt,name=is_alive,json=isAlive,proto3" json:"is_alive,omitempty"` // Indicates whether agent is alive
+	IdAgent int32 `protobuf:"varint,2,opt,name=id_agent,json=idAgent,proto3" json:"id_agent,omitempty"` // Id of agent
+}
+
+func (x *IsAlive) Reset() {
+	*x = IsAlive{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_orchestrator_orchestrator_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *IsAlive) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IsAlive) ProtoMessage() {}
+
+func (x *IsAlive) ProtoReflect() protoreflect.Message {
+	mi := &file_orchestrator_orchestrator_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IsAlive.ProtoReflect.Descriptor instead.
+func (*IsAlive) Descriptor() ([]byte, []int) {
+	return file_orchestrator_orchestrator_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *IsAlive) GetIsAlive() bool {
+	if x != nil {
+		return x.IsAlive
+	}
+	return false
+}
+
+func (x *IsAlive) GetIdAgent() int32 {
+	if x != nil {
+		return x.IdAgent
+	}
+	return 0
+}
+
+type IdAgent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	IdAgent int32 `protobuf:"varint,1,opt,name=id_agent,json=idAgent,proto3" json:"id_agent,omitempty"` // Id of agent
+}
+
+func (x *IdAgent) Reset() {
+	*x = IdAgent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_orchestrator_orchestrator_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *IdAgent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IdAgent) ProtoMessage() {}
+
+func (x *IdAgent) ProtoReflect() protoreflect.Message {
+	mi := &file_orchestrator_orchestrator_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IdAgent.ProtoReflect.Descriptor instead.
+func (*IdAgent) Descriptor() ([]byte, []int) {
+	return file_orchestrator_orchestrator_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *IdAgent) GetIdAgent() int32 {
+	if x != nil {
+		return x.IdAgent
+	}
+	return 0
+}
+
+type Task struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	IdTask            int32       `protobuf:"varint,1,opt,name=id_task,json=idTask,proto3" json:"id_task,omitempty"`                                 // Id of task, 0 if there is no task ready for evaluating
+	IdExpression      string      `protobuf:"bytes,2,opt,name=id_expression,json=idExpression,proto3" json:"id_expression,omitempty"`                // Id of expression which task is part of
+	PostfixExpression []*RPNToken `protobuf:"bytes,3,rep,name=postfix_expression,json=postfixExpression,proto3" json:"postfix_expression,omitempty"` // Operation with its operands in postfix notation
+	Duration          int64       `protobuf:"varint,4,opt,name=duration,proto3" json:"duration,omitempty"`                                           // How long operation takes, in milliseconds
+	Exact             bool        `protobuf:"varint,5,opt,name=exact,proto3" json:"exact,omitempty"`                                                 // Operands are exact rationals like "1/3" and should be evaluated without rounding
+	Deadline          int64       `protobuf:"varint,6,opt,name=deadline,proto3" json:"deadline,omitempty"`                                           // Unix time in milliseconds, when expression times out. 0 if there is no deadline
+}
+
+func (x *Task) Reset() {
+	*x = Task{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_orchestrator_orchestrator_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Task) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Task) ProtoMessage() {}
+
+func (x *Task) ProtoReflect() protoreflect.Message {
+	mi := &file_orchestrator_orchestrator_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Task.ProtoReflect.Descriptor instead.
+func (*Task) Descriptor() ([]byte, []int) {
+	return file_orchestrator_orchestrator_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Task) GetIdTask() int32 {
+	if x != nil {
+		return x.IdTask
+	}
+	return 0
+}
+
+func (x *Task) GetIdExpression() string {
+	if x != nil {
+		return x.IdExpression
+	}
+	return ""
+}
+
+func (x *Task) GetPostfixExpression() []*RPNToken {
+	if x != nil {
+		return x.PostfixExpression
+	}
+	return nil
+}
+
+func (x *Task) GetDuration() int64 {
+	if x != nil {
+		return x.Duration
+	}
+	return 0
+}
+
+func (x *Task) GetExact() bool {
+	if x != nil {
+		return x.Exact
+	}
+	return false
+}
+
+func (x *Task) GetDeadline() int64 {
+	if x != nil {
+		return x.Deadline
+	}
+	return 0
+}
+
+type RPNToken struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type  int32  `protobuf:"varint,1,opt,name=type,proto3" json:"type,omitempty"`   // Type of token: 1 - operand, 2 - operator, 3 - function
+	Value string `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`  // Value of operand or name of operator or function
+	Arity int32  `protobuf:"varint,3,opt,name=arity,proto3" json:"arity,omitempty"` // How many operands operator or function takes, e.g. 1 for unary minus and 3 for max(1, 2, 3)
+}
+
+func (x *RPNToken) Reset() {
+	*x = RPNToken{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_orchestrator_orchestrator_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RPNToken) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RPNToken) ProtoMessage() {}
+
+func (x *RPNToken) ProtoReflect() protoreflect.Message {
+	mi := &file_orchestrator_orchestrator_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RPNToken.ProtoReflect.Descriptor instead.
+func (*RPNToken) Descriptor() ([]byte, []int) {
+	return file_orchestrator_orchestrator_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RPNToken) GetType() int32 {
+	if x != nil {
+		return x.Type
+	}
+	return 0
+}
+
+func (x *RPNToken) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *RPNToken) GetArity() int32 {
+	if x != nil {
+		return x.Arity
+	}
+	return 0
+}
+
+type ResultOfTask struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	IdTask  int32   `protobuf:"varint,1,opt,name=id_task,json=idTask,proto3" json:"id_task,omitempty"` // Id of task
+	Result  float64 `protobuf:"fixed64,2,opt,name=result,proto3" json:"result,omitempty"`              // Result as float, used only if value is empty
+	IdAgent int32   `protobuf:"varint,3,opt,name=id_agent,json=idAgent,proto3" json:"id_agent,omitempty"`
+	Value   string  `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"` // Result as string: float or exact rational for exact tasks
+}
+
+func (x *ResultOfTask) Reset() {
+	*x = ResultOfTask{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_orchestrator_orchestrator_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ResultOfTask) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResultOfTask) ProtoMessage() {}
+
+func (x *ResultOfTask) ProtoReflect() protoreflect.Message {
+	mi := &file_orchestrator_orchestrator_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResultOfTask.ProtoReflect.Descriptor instead.
+func (*ResultOfTask) Descriptor() ([]byte, []int) {
+	return file_orchestrator_orchestrator_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ResultOfTask) GetIdTask() int32 {
+	if x != nil {
+		return x.IdTask
+	}
+	return 0
+}
+
+func (x *ResultOfTask) GetResult() float64 {
+	if x != nil {
+		return x.Result
+	}
+	return 0
+}
+
+func (x *ResultOfTask) GetIdAgent() int32 {
+	if x != nil {
+		return x.IdAgent
+	}
+	return 0
+}
+
+func (x *ResultOfTask) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+type ErrorOfTask struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	IdTask  int32  `protobuf:"varint,1,opt,name=id_task,json=idTask,proto3" json:"id_task,omitempty"` // Id of task
+	IdAgent int32  `protobuf:"varint,2,opt,name=id_agent,json=idAgent,proto3" json:"id_agent,omitempty"`
+	Code    string `protobuf:"bytes,3,opt,name=code,proto3" json:"code,omitempty"`       // Kind of error, e.g. division_by_zero, domain_error or out_of_range
+	Message string `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"` // Description of error for user
+}
+
+func (x *ErrorOfTask) Reset() {
+	*x = ErrorOfTask{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_orchestrator_orchestrator_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ErrorOfTask) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ErrorOfTask) ProtoMessage() {}
+
+func (x *ErrorOfTask) ProtoReflect() protoreflect.Message {
+	mi := &file_orchestrator_orchestrator_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ErrorOfTask.ProtoReflect.Descriptor instead.
+func (*ErrorOfTask) Descriptor() ([]byte, []int) {
+	return file_orchestrator_orchestrator_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ErrorOfTask) GetIdTask() int32 {
+	if x != nil {
+		return x.IdTask
+	}
+	return 0
+}
+
+func (x *ErrorOfTask) GetIdAgent() int32 {
+	if x != nil {
+		return x.IdAgent
+	}
+	return 0
+}
+
+func (x *ErrorOfTask) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *ErrorOfTask) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type ProgressOfTask struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	IdTask     int32    `protobuf:"varint,1,opt,name=id_task,json=idTask,proto3" json:"id_task,omitempty"` // Id of task
+	Operation  string   `protobuf:"bytes,2,opt,name=operation,proto3" json:"operation,omitempty"`          // Operator or function which was evaluated
+	Operands   []string `protobuf:"bytes,3,rep,name=operands,proto3" json:"operands,omitempty"`
+	Value      string   `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`            // Intermediate result of operation
+	Calculator int32    `protobuf:"varint,5,opt,name=calculator,proto3" json:"calculator,omitempty"` // Which calculator of agent evaluated operation
+	Duration   int64    `protobuf:"varint,6,opt,name=duration,proto3" json:"duration,omitempty"`     // How long evaluating took, in milliseconds
+	Step       int32    `protobuf:"varint,7,opt,name=step,proto3" json:"step,omitempty"`             // Index of operation in task, repeated evaluation of task replaces its steps
+}
+
+func (x *ProgressOfTask) Reset() {
+	*x = ProgressOfTask{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_orchestrator_orchestrator_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ProgressOfTask) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProgressOfTask) ProtoMessage() {}
+
+func (x *ProgressOfTask) ProtoReflect() protoreflect.Message {
+	mi := &file_orchestrator_orchestrator_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProgressOfTask.ProtoReflect.Descriptor instead.
+func (*ProgressOfTask) Descriptor() ([]byte, []int) {
+	return file_orchestrator_orchestrator_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ProgressOfTask) GetIdTask() int32 {
+	if x != nil {
+		return x.IdTask
+	}
+	return 0
+}
+
+func (x *ProgressOfTask) GetOperation() string {
+	if x != nil {
+		return x.Operation
+	}
+	return ""
+}
+
+func (x *ProgressOfTask) GetOperands() []string {
+	if x != nil {
+		return x.Operands
+	}
+	return nil
+}
+
+func (x *ProgressOfTask) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *ProgressOfTask) GetCalculator() int32 {
+	if x != nil {
+		return x.Calculator
+	}
+	return 0
+}
+
+func (x *ProgressOfTask) GetDuration() int64 {
+	if x != nil {
+		return x.Duration
+	}
+	return 0
+}
+
+func (x *ProgressOfTask) GetStep() int32 {
+	if x != nil {
+		return x.Step
+	}
+	return 0
+}
+
+type AgentMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	IdAgent int32 `protobuf:"varint,1,opt,name=id_agent,json=idAgent,proto3" json:"id_agent,omitempty"` // Id of agent
+	// Types that are assignable to Message:
+	//	*AgentMessage_FreeSlots
+	//	*AgentMessage_Ack
+	//	*AgentMessage_Result
+	//	*AgentMessage_Error
+	//	*AgentMessage_Progress
+	Message isAgentMessage_Message `protobuf_oneof:"message"`
+}
+
+func (x *AgentMessage) Reset() {
+	*x = AgentMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_orchestrator_orchestrator_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AgentMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentMessage) ProtoMessage() {}
+
+func (x *AgentMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_orchestrator_orchestrator_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentMessage.ProtoReflect.Descriptor instead.
+func (*AgentMessage) Descriptor() ([]byte, []int) {
+	return file_orchestrator_orchestrator_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *AgentMessage) GetIdAgent() int32 {
+	if x != nil {
+		return x.IdAgent
+	}
+	return 0
+}
+
+func (m *AgentMessage) GetMessage() isAgentMessage_Message {
+	if m != nil {
+		return m.Message
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetFreeSlots() int32 {
+	if x, ok := x.GetMessage().(*AgentMessage_FreeSlots); ok {
+		return x.FreeSlots
+	}
+	return 0
+}
+
+func (x *AgentMessage) GetAck() int32 {
+	if x, ok := x.GetMessage().(*AgentMessage_Ack); ok {
+		return x.Ack
+	}
+	return 0
+}
+
+func (x *AgentMessage) GetResult() *ResultOfTask {
+	if x, ok := x.GetMessage().(*AgentMessage_Result); ok {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetError() *ErrorOfTask {
+	if x, ok := x.GetMessage().(*AgentMessage_Error); ok {
+		return x.Error
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetProgress() *ProgressOfTask {
+	if x, ok := x.GetMessage().(*AgentMessage_Progress); ok {
+		return x.Progress
+	}
+	return nil
+}
+
+type isAgentMessage_Message interface {
+	isAgentMessage_Message()
+}
+
+type AgentMessage_FreeSlots struct {
+	FreeSlots int32 `protobuf:"varint,2,opt,name=free_slots,json=freeSlots,proto3,oneof"` // Agent has that many more free calculators
+}
+
+type AgentMessage_Ack struct {
+	Ack int32 `protobuf:"varint,3,opt,name=ack,proto3,oneof"` // Agent received task with this id
+}
+
+type AgentMessage_Result struct {
+	Result *ResultOfTask `protobuf:"bytes,4,opt,name=result,proto3,oneof"` // Result of task, frees one calculator of agent
+}
+
+type AgentMessage_Error struct {
+	Error *ErrorOfTask `protobuf:"bytes,5,opt,name=error,proto3,oneof"` // Task can't be evaluated, frees one calculator of agent as well
+}
+
+type AgentMessage_Progress struct {
+	Progress *ProgressOfTask `protobuf:"bytes,6,opt,name=progress,proto3,oneof"` // One operation of task is evaluated, calculator isn't freed
+}
+
+func (*AgentMessage_FreeSlots) isAgentMessage_Message() {}
+
+func (*AgentMessage_Ack) isAgentMessage_Message() {}
+
+func (*AgentMessage_Result) isAgentMessage_Message() {}
+
+func (*AgentMessage_Error) isAgentMessage_Message() {}
+
+func (*AgentMessage_Progress) isAgentMessage_Message() {}
+
+type OrchestratorMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Message:
+	//	*OrchestratorMessage_Task
+	//	*OrchestratorMessage_Abort
+	Message isOrchestratorMessage_Message `protobuf_oneof:"message"`
+}
+
+func (x *OrchestratorMessage) Reset() {
+	*x = OrchestratorMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_orchestrator_orchestrator_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OrchestratorMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OrchestratorMessage) ProtoMessage() {}
+
+func (x *OrchestratorMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_orchestrator_orchestrator_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OrchestratorMessage.ProtoReflect.Descriptor instead.
+func (*OrchestratorMessage) Descriptor() ([]byte, []int) {
+	return file_orchestrator_orchestrator_proto_rawDescGZIP(), []int{8}
+}
+
+func (m *OrchestratorMessage) GetMessage() isOrchestratorMessage_Message {
+	if m != nil {
+		return m.Message
+	}
+	return nil
+}
+
+func (x *OrchestratorMessage) GetTask() *Task {
+	if x, ok := x.GetMessage().(*OrchestratorMessage_Task); ok {
+		return x.Task
+	}
+	return nil
+}
+
+func (x *OrchestratorMessage) GetAbort() int32 {
+	if x, ok := x.GetMessage().(*OrchestratorMessage_Abort); ok {
+		return x.Abort
+	}
+	return 0
+}
+
+type isOrchestratorMessage_Message interface {
+	isOrchestratorMessage_Message()
+}
+
+type OrchestratorMessage_Task struct {
+	Task *Task `protobuf:"bytes,1,opt,name=task,proto3,oneof"` // Task for evaluating
+}
+
+type OrchestratorMessage_Abort struct {
+	Abort int32 `protobuf:"varint,2,opt,name=abort,proto3,oneof"` // Id of task which expression was cancelled: agent stops evaluating it and doesn't send result
+}
+
+func (*OrchestratorMessage_Task) isOrchestratorMessage_Message() {}
+
+func (*OrchestratorMessage_Abort) isOrchestratorMessage_Message() {}
+
+var File_orchestrator_orchestrator_proto protoreflect.FileDescriptor
+
+var file_orchestrator_orchestrator_proto_rawDesc = []byte{
+	0x0a, 0x1f, 0x6f, 0x72, 0x63, 0x68, 0x65, 0x73, 0x74, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x2f, 0x6f,
+	0x72, 0x63, 0x68, 0x65, 0x73, 0x74, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x12, 0x0c, 0x6f, 0x72, 0x63, 0x68, 0x65, 0x73, 0x74, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x1a,
+	0x1b, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2f, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x3f, 0x0a, 0x07,
+	0x49, 0x73, 0x41, 0x6c, 0x69, 0x76, 0x65, 0x12, 0x19, 0x0a, 0x08, 0x69, 0x73, 0x5f, 0x61, 0x6c,
+	0x69, 0x76, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x69, 0x73, 0x41, 0x6c, 0x69,
+	0x76, 0x65, 0x12, 0x19, 0x0a, 0x08, 0x69, 0x64, 0x5f, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x69, 0x64, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x22, 0x24, 0x0a,
+	0x07, 0x49, 0x64, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x12, 0x19, 0x0a, 0x08, 0x69, 0x64, 0x5f, 0x61,
+	0x67, 0x65, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x69, 0x64, 0x41, 0x67,
+	0x65, 0x6e, 0x74, 0x22, 0xd9, 0x01, 0x0a, 0x04, 0x54, 0x61, 0x73, 0x6b, 0x12, 0x17, 0x0a, 0x07,
+	0x69, 0x64, 0x5f, 0x74, 0x61, 0x73, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x69,
+	0x64, 0x54, 0x61, 0x73, 0x6b, 0x12, 0x23, 0x0a, 0x0d, 0x69, 0x64, 0x5f, 0x65, 0x78, 0x70, 0x72,
+	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x69, 0x64,
+	0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x45, 0x0a, 0x12, 0x70, 0x6f,
+	0x73, 0x74, 0x66, 0x69, 0x78, 0x5f, 0x65, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e,
+	0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x6f, 0x72, 0x63, 0x68, 0x65, 0x73, 0x74,
+	0x72, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x52, 0x50, 0x4e, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x52, 0x11,
+	0x70, 0x6f, 0x73, 0x74, 0x66, 0x69, 0x78, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f,
+	0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x08, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x14, 0x0a,
+	0x05, 0x65, 0x78, 0x61, 0x63, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x65, 0x78,
+	0x61, 0x63, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x64, 0x65, 0x61, 0x64, 0x6c, 0x69, 0x6e, 0x65, 0x18,
+	0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x64, 0x65, 0x61, 0x64, 0x6c, 0x69, 0x6e, 0x65, 0x22,
+	0x4a, 0x0a, 0x08, 0x52, 0x50, 0x4e, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x74,
+	0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12,
+	0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x61, 0x72, 0x69, 0x74, 0x79, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x61, 0x72, 0x69, 0x74, 0x79, 0x22, 0x70, 0x0a, 0x0c, 0x52,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x4f, 0x66, 0x54, 0x61, 0x73, 0x6b, 0x12, 0x17, 0x0a, 0x07, 0x69,
+	0x64, 0x5f, 0x74, 0x61, 0x73, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x69, 0x64,
+	0x54, 0x61, 0x73, 0x6b, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x01, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x19, 0x0a, 0x08,
+	0x69, 0x64, 0x5f, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07,
+	0x69, 0x64, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x6f, 0x0a,
+	0x0b, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x4f, 0x66, 0x54, 0x61, 0x73, 0x6b, 0x12, 0x17, 0x0a, 0x07,
+	0x69, 0x64, 0x5f, 0x74, 0x61, 0x73, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x69,
+	0x64, 0x54, 0x61, 0x73, 0x6b, 0x12, 0x19, 0x0a, 0x08, 0x69, 0x64, 0x5f, 0x61, 0x67, 0x65, 0x6e,
+	0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x69, 0x64, 0x41, 0x67, 0x65, 0x6e, 0x74,
+	0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x63, 0x6f, 0x64, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0xc9,
+	0x01, 0x0a, 0x0e, 0x50, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x4f, 0x66, 0x54, 0x61, 0x73,
+	0x6b, 0x12, 0x17, 0x0a, 0x07, 0x69, 0x64, 0x5f, 0x74, 0x61, 0x73, 0x6b, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x06, 0x69, 0x64, 0x54, 0x61, 0x73, 0x6b, 0x12, 0x1c, 0x0a, 0x09, 0x6f, 0x70,
+	0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6f,
+	0x70, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x6f, 0x70, 0x65, 0x72,
+	0x61, 0x6e, 0x64, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08, 0x6f, 0x70, 0x65, 0x72,
+	0x61, 0x6e, 0x64, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x63, 0x61,
+	0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0a,
+	0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x12, 0x1a, 0x0a, 0x08, 0x64, 0x75,
+	0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x64, 0x75,
+	0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x74, 0x65, 0x70, 0x18, 0x07,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x73, 0x74, 0x65, 0x70, 0x22, 0x8e, 0x02, 0x0a, 0x0c, 0x41,
+	0x67, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x19, 0x0a, 0x08, 0x69,
+	0x64, 0x5f, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x69,
+	0x64, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x12, 0x1f, 0x0a, 0x0a, 0x66, 0x72, 0x65, 0x65, 0x5f, 0x73,
+	0x6c, 0x6f, 0x74, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x48, 0x00, 0x52, 0x09, 0x66, 0x72,
+	0x65, 0x65, 0x53, 0x6c, 0x6f, 0x74, 0x73, 0x12, 0x12, 0x0a, 0x03, 0x61, 0x63, 0x6b, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x05, 0x48, 0x00, 0x52, 0x03, 0x61, 0x63, 0x6b, 0x12, 0x34, 0x0a, 0x06, 0x72,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x6f, 0x72,
+	0x63, 0x68, 0x65, 0x73, 0x74, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x52, 0x65, 0x73, 0x75, 0x6c,
+	0x74, 0x4f, 0x66, 0x54, 0x61, 0x73, 0x6b, 0x48, 0x00, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c,
+	0x74, 0x12, 0x31, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x19, 0x2e, 0x6f, 0x72, 0x63, 0x68, 0x65, 0x73, 0x74, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x2e,
+	0x45, 0x72, 0x72, 0x6f, 0x72, 0x4f, 0x66, 0x54, 0x61, 0x73, 0x6b, 0x48, 0x00, 0x52, 0x05, 0x65,
+	0x72, 0x72, 0x6f, 0x72, 0x12, 0x3a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x6f, 0x72, 0x63, 0x68, 0x65, 0x73, 0x74,
+	0x72, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x50, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x4f, 0x66,
+	0x54, 0x61, 0x73, 0x6b, 0x48, 0x00, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73,
+	0x42, 0x09, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x62, 0x0a, 0x13, 0x4f,
+	0x72, 0x63, 0x68, 0x65, 0x73, 0x74, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x12, 0x28, 0x0a, 0x04, 0x74, 0x61, 0x73, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x12, 0x2e, 0x6f, 0x72, 0x63, 0x68, 0x65, 0x73, 0x74, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x2e,
+	0x54, 0x61, 0x73, 0x6b, 0x48, 0x00, 0x52, 0x04, 0x74, 0x61, 0x73, 0x6b, 0x12, 0x16, 0x0a, 0x05,
+	0x61, 0x62, 0x6f, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x48, 0x00, 0x52, 0x05, 0x61,
+	0x62, 0x6f, 0x72, 0x74, 0x42, 0x09, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x32,
+	0xdd, 0x03, 0x0a, 0x0c, 0x4f, 0x72, 0x63, 0x68, 0x65, 0x73, 0x74, 0x72, 0x61, 0x74, 0x6f, 0x72,
+	0x12, 0x3a, 0x0a, 0x09, 0x48, 0x65, 0x61, 0x72, 0x74, 0x62, 0x65, 0x61, 0x74, 0x12, 0x15, 0x2e,
+	0x6f, 0x72, 0x63, 0x68, 0x65, 0x73, 0x74, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x49, 0x73, 0x41,
+	0x6c, 0x69, 0x76, 0x65, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x12, 0x34, 0x0a, 0x07,
+	0x47, 0x65, 0x74, 0x54, 0x61, 0x73, 0x6b, 0x12, 0x15, 0x2e, 0x6f, 0x72, 0x63, 0x68, 0x65, 0x73,
+	0x74, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x49, 0x64, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x1a, 0x12,
+	0x2e, 0x6f, 0x72, 0x63, 0x68, 0x65, 0x73, 0x74, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x54, 0x61,
+	0x73, 0x6b, 0x12, 0x46, 0x0a, 0x10, 0x47, 0x69, 0x76, 0x65, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74,
+	0x4f, 0x66, 0x54, 0x61, 0x73, 0x6b, 0x12, 0x1a, 0x2e, 0x6f, 0x72, 0x63, 0x68, 0x65, 0x73, 0x74,
+	0x72, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x4f, 0x66, 0x54, 0x61,
+	0x73, 0x6b, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x12, 0x44, 0x0a, 0x0f, 0x47, 0x69,
+	0x76, 0x65, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x4f, 0x66, 0x54, 0x61, 0x73, 0x6b, 0x12, 0x19, 0x2e,
+	0x6f, 0x72, 0x63, 0x68, 0x65, 0x73, 0x74, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x45, 0x72, 0x72,
+	0x6f, 0x72, 0x4f, 0x66, 0x54, 0x61, 0x73, 0x6b, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
+	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79,
+	0x12, 0x41, 0x0a, 0x10, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x4e, 0x65, 0x77, 0x41,
+	0x67, 0x65, 0x6e, 0x74, 0x12, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x15, 0x2e, 0x6f,
+	0x72, 0x63, 0x68, 0x65, 0x73, 0x74, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x49, 0x64, 0x41, 0x67,
+	0x65, 0x6e, 0x74, 0x12, 0x3c, 0x0a, 0x0b, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x41, 0x67, 0x65,
+	0x6e, 0x74, 0x12, 0x15, 0x2e, 0x6f, 0x72, 0x63, 0x68, 0x65, 0x73, 0x74, 0x72, 0x61, 0x74, 0x6f,
+	0x72, 0x2e, 0x49, 0x64, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74,
+	0x79, 0x12, 0x4c, 0x0a, 0x07, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x12, 0x1a, 0x2e, 0x6f,
+	0x72, 0x63, 0x68, 0x65, 0x73, 0x74, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x41, 0x67, 0x65, 0x6e,
+	0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x1a, 0x21, 0x2e, 0x6f, 0x72, 0x63, 0x68, 0x65,
+	0x73, 0x74, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x4f, 0x72, 0x63, 0x68, 0x65, 0x73, 0x74, 0x72,
+	0x61, 0x74, 0x6f, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x28, 0x01, 0x30, 0x01, 0x42,
+	0x10, 0x5a, 0x0e, 0x2e, 0x2f, 0x6f, 0x72, 0x63, 0x68, 0x65, 0x73, 0x74, 0x72, 0x61, 0x74, 0x6f,
+	0x72, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_orchestrator_orchestrator_proto_rawDescOnce sync.Once
+	file_orchestrator_orchestrator_proto_rawDescData = file_orchestrator_orchestrator_proto_rawDesc
+)
+
+func file_orchestrator_orchestrator_proto_rawDescGZIP() []byte {
+	file_orchestrator_orchestrator_proto_rawDescOnce.Do(func() {
+		file_orchestrator_orchestrator_proto_rawDescData = protoimpl.X.CompressGZIP(file_orchestrator_orchestrator_proto_rawDescData)
+	})
+	return file_orchestrator_orchestrator_proto_rawDescData
+}
+
+var file_orchestrator_orchestrator_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_orchestrator_orchestrator_proto_goTypes = []interface{}{
+	(*IsAlive)(nil),             // 0: orchestrator.IsAlive
+	(*IdAgent)(nil),             // 1: orchestrator.IdAgent
+	(*Task)(nil),                // 2: orchestrator.Task
+	(*RPNToken)(nil),            // 3: orchestrator.RPNToken
+	(*ResultOfTask)(nil),        // 4: orchestrator.ResultOfTask
+	(*ErrorOfTask)(nil),         // 5: orchestrator.ErrorOfTask
+	(*ProgressOfTask)(nil),      // 6: orchestrator.ProgressOfTask
+	(*AgentMessage)(nil),        // 7: orchestrator.AgentMessage
+	(*OrchestratorMessage)(nil), // 8: orchestrator.OrchestratorMessage
+	(*emptypb.Empty)(nil),       // 9: google.protobuf.Empty
+}
+var file_orchestrator_orchestrator_proto_depIdxs = []int32{
+	3,  // 0: orchestrator.Task.postfix_expression:type_name -> orchestrator.RPNToken
+	4,  // 1: orchestrator.AgentMessage.result:type_name -> orchestrator.ResultOfTask
+	5,  // 2: orchestrator.AgentMessage.error:type_name -> orchestrator.ErrorOfTask
+	6,  // 3: orchestrator.AgentMessage.progress:type_name -> orchestrator.ProgressOfTask
+	2,  // 4: orchestrator.OrchestratorMessage.task:type_name -> orchestrator.Task
+	0,  // 5: orchestrator.Orchestrator.Heartbeat:input_type -> orchestrator.IsAlive
+	1,  // 6: orchestrator.Orchestrator.GetTask:input_type -> orchestrator.IdAgent
+	4,  // 7: orchestrator.Orchestrator.GiveResultOfTask:input_type -> orchestrator.ResultOfTask
+	5,  // 8: orchestrator.Orchestrator.GiveErrorOfTask:input_type -> orchestrator.ErrorOfTask
+	9,  // 9: orchestrator.Orchestrator.RegisterNewAgent:input_type -> google.protobuf.Empty
+	1,  // 10: orchestrator.Orchestrator.RemoveAgent:input_type -> orchestrator.IdAgent
+	7,  // 11: orchestrator.Orchestrator.Connect:input_type -> orchestrator.AgentMessage
+	9,  // 12: orchestrator.Orchestrator.Heartbeat:output_type -> google.protobuf.Empty
+	2,  // 13: orchestrator.Orchestrator.GetTask:output_type -> orchestrator.Task
+	9,  // 14: orchestrator.Orchestrator.GiveResultOfTask:output_type -> google.protobuf.Empty
+	9,  // 15: orchestrator.Orchestrator.GiveErrorOfTask:output_type -> google.protobuf.Empty
+	1,  // 16: orchestrator.Orchestrator.RegisterNewAgent:output_type -> orchestrator.IdAgent
+	9,  // 17: orchestrator.Orchestrator.RemoveAgent:output_type -> google.protobuf.Empty
+	8,  // 18: orchestrator.Orchestrator.Connect:output_type -> orchestrator.OrchestratorMessage
+	12, // [12:19] is the sub-list for method output_type
+	5,  // [5:12] is the sub-list for method input_type
+	5,  // [5:5] is the sub-list for extension type_name
+	5,  // [5:5] is the sub-list for extension extendee
+	0,  // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_orchestrator_orchestrator_proto_init() }
+func file_orchestrator_orchestrator_proto_init() {
+	if File_orchestrator_orchestrator_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_orchestrator_orchestrator_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*IsAlive); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_orchestrator_orchestrator_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*IdAgent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_orchestrator_orchestrator_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Task); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_orchestrator_orchestrator_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RPNToken); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_orchestrator_orchestrator_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ResultOfTask); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_orchestrator_orchestrator_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ErrorOfTask); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_orchestrator_orchestrator_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ProgressOfTask); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_orchestrator_orchestrator_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AgentMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_orchestrator_orchestrator_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*OrchestratorMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_orchestrator_orchestrator_proto_msgTypes[7].OneofWrappers = []interface{}{
+		(*AgentMessage_FreeSlots)(nil),
+		(*AgentMessage_Ack)(nil),
+		(*AgentMessage_Result)(nil),
+		(*AgentMessage_Error)(nil),
+		(*AgentMessage_Progress)(nil),
+	}
+	file_orchestrator_orchestrator_proto_msgTypes[8].OneofWrappers = []interface{}{
+		(*OrchestratorMessage_Task)(nil),
+		(*OrchestratorMessage_Abort)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_orchestrator_orchestrator_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_orchestrator_orchestrator_proto_goTypes,
+		DependencyIndexes: file_orchestrator_orchestrator_proto_depIdxs,
+		MessageInfos:      file_orchestrator_orchestrator_proto_msgTypes,
+	}.Build()
+	File_orchestrator_orchestrator_proto = out.File
+	file_orchestrator_orchestrator_proto_rawDesc = nil
+	file_orchestrator_orchestrator_proto_goTypes = nil
+	file_orchestrator_orchestrator_proto_depIdxs = nil
+}
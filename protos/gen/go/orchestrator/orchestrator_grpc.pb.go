@@ -0,0 +1,381 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v5.26.1
+// source: orchestrator/orchestrator.proto
+
+package orchestrator
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Orchestrator_Heartbeat_FullMethodName        = "/orchestrator.Orchestrator/Heartbeat"
+	Orchestrator_GetTask_FullMethodName          = "/orchestrator.Orchestrator/GetTask"
+	Orchestrator_GiveResultOfTask_FullMethodName = "/orchestrator.Orchestrator/GiveResultOfTask"
+	Orchestrator_GiveErrorOfTask_FullMethodName  = "/orchestrator.Orchestrator/GiveErrorOfTask"
+	Orchestrator_RegisterNewAgent_FullMethodName = "/orchestrator.Orchestrator/RegisterNewAgent"
+	Orchestrator_RemoveAgent_FullMethodName      = "/orchestrator.Orchestrator/RemoveAgent"
+	Orchestrator_Connect_FullMethodName          = "/orchestrator.Orchestrator/Connect"
+)
+
+// OrchestratorClient is the client API for Orchestrator service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type OrchestratorClient interface {
+	// Heardbeats whether agent is alive either not
+	Heartbeat(ctx context.Context, in *IsAlive, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Returns task (one operation of some expression) ready for evaluating by agent with id = IdAgent
+	GetTask(ctx context.Context, in *IdAgent, opts ...grpc.CallOption) (*Task, error)
+	// Agent gives result of solved task back
+	GiveResultOfTask(ctx context.Context, in *ResultOfTask, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Agent couldn't evaluate task, e.g. because of division by zero. Expression of task fails
+	GiveErrorOfTask(ctx context.Context, in *ErrorOfTask, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Registers new agent and returns of his id
+	RegisterNewAgent(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*IdAgent, error)
+	// Deletes agent from database
+	RemoveAgent(ctx context.Context, in *IdAgent, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Opens stream between agent and orchestrator: agent advertises free calculators,
+	// orchestrator pushes tasks as soon as they are ready, agent sends back acks and results
+	Connect(ctx context.Context, opts ...grpc.CallOption) (Orchestrator_ConnectClient, error)
+}
+
+type orchestratorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewOrchestratorClient(cc grpc.ClientConnInterface) OrchestratorClient {
+	return &orchestratorClient{cc}
+}
+
+func (c *orchestratorClient) Heartbeat(ctx context.Context, in *IsAlive, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Orchestrator_Heartbeat_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orchestratorClient) GetTask(ctx context.Context, in *IdAgent, opts ...grpc.CallOption) (*Task, error) {
+	out := new(Task)
+	err := c.cc.Invoke(ctx, Orchestrator_GetTask_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orchestratorClient) GiveResultOfTask(ctx context.Context, in *ResultOfTask, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Orchestrator_GiveResultOfTask_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orchestratorClient) GiveErrorOfTask(ctx context.Context, in *ErrorOfTask, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Orchestrator_GiveErrorOfTask_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orchestratorClient) RegisterNewAgent(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*IdAgent, error) {
+	out := new(IdAgent)
+	err := c.cc.Invoke(ctx, Orchestrator_RegisterNewAgent_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orchestratorClient) RemoveAgent(ctx context.Context, in *IdAgent, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Orchestrator_RemoveAgent_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orchestratorClient) Connect(ctx context.Context, opts ...grpc.CallOption) (Orchestrator_ConnectClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Orchestrator_ServiceDesc.Streams[0], Orchestrator_Connect_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &orchestratorConnectClient{stream}
+	return x, nil
+}
+
+type Orchestrator_ConnectClient interface {
+	Send(*AgentMessage) error
+	Recv() (*OrchestratorMessage, error)
+	grpc.ClientStream
+}
+
+type orchestratorConnectClient struct {
+	grpc.ClientStream
+}
+
+func (x *orchestratorConnectClient) Send(m *AgentMessage) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *orchestratorConnectClient) Recv() (*OrchestratorMessage, error) {
+	m := new(OrchestratorMessage)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// OrchestratorServer is the server API for Orchestrator service.
+// All implementations must embed UnimplementedOrchestratorServer
+// for forward compatibility
+type OrchestratorServer interface {
+	// Heardbeats whether agent is alive either not
+	Heartbeat(context.Context, *IsAlive) (*emptypb.Empty, error)
+	// Returns task (one operation of some expression) ready for evaluating by agent with id = IdAgent
+	GetTask(context.Context, *IdAgent) (*Task, error)
+	// Agent gives result of solved task back
+	GiveResultOfTask(context.Context, *ResultOfTask) (*emptypb.Empty, error)
+	// Agent couldn't evaluate task, e.g. because of division by zero. Expression of task fails
+	GiveErrorOfTask(context.Context, *ErrorOfTask) (*emptypb.Empty, error)
+	// Registers new agent and returns of his id
+	RegisterNewAgent(context.Context, *emptypb.Empty) (*IdAgent, error)
+	// Deletes agent from database
+	RemoveAgent(context.Context, *IdAgent) (*emptypb.Empty, error)
+	// Opens stream between agent and orchestrator: agent advertises free calculators,
+	// orchestrator pushes tasks as soon as they are ready, agent sends back acks and results
+	Connect(Orchestrator_ConnectServer) error
+	mustEmbedUnimplementedOrchestratorServer()
+}
+
+// UnimplementedOrchestratorServer must be embedded to have forward compatible implementations.
+type UnimplementedOrchestratorServer struct {
+}
+
+func (UnimplementedOrchestratorServer) Heartbeat(context.Context, *IsAlive) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Heartbeat not implemented")
+}
+func (UnimplementedOrchestratorServer) GetTask(context.Context, *IdAgent) (*Task, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTask not implemented")
+}
+func (UnimplementedOrchestratorServer) GiveResultOfTask(context.Context, *ResultOfTask) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GiveResultOfTask not implemented")
+}
+func (UnimplementedOrchestratorServer) GiveErrorOfTask(context.Context, *ErrorOfTask) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GiveErrorOfTask not implemented")
+}
+func (UnimplementedOrchestratorServer) RegisterNewAgent(context.Context, *emptypb.Empty) (*IdAgent, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterNewAgent not implemented")
+}
+func (UnimplementedOrchestratorServer) RemoveAgent(context.Context, *IdAgent) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveAgent not implemented")
+}
+func (UnimplementedOrchestratorServer) Connect(Orchestrator_ConnectServer) error {
+	return status.Errorf(codes.Unimplemented, "method Connect not implemented")
+}
+func (UnimplementedOrchestratorServer) mustEmbedUnimplementedOrchestratorServer() {}
+
+// UnsafeOrchestratorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to OrchestratorServer will
+// result in compilation errors.
+type UnsafeOrchestratorServer interface {
+	mustEmbedUnimplementedOrchestratorServer()
+}
+
+func RegisterOrchestratorServer(s grpc.ServiceRegistrar, srv OrchestratorServer) {
+	s.RegisterService(&Orchestrator_ServiceDesc, srv)
+}
+
+func _Orchestrator_Heartbeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IsAlive)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrchestratorServer).Heartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orchestrator_Heartbeat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrchestratorServer).Heartbeat(ctx, req.(*IsAlive))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orchestrator_GetTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IdAgent)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrchestratorServer).GetTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orchestrator_GetTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrchestratorServer).GetTask(ctx, req.(*IdAgent))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orchestrator_GiveResultOfTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResultOfTask)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrchestratorServer).GiveResultOfTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orchestrator_GiveResultOfTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrchestratorServer).GiveResultOfTask(ctx, req.(*ResultOfTask))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orchestrator_GiveErrorOfTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ErrorOfTask)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrchestratorServer).GiveErrorOfTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orchestrator_GiveErrorOfTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrchestratorServer).GiveErrorOfTask(ctx, req.(*ErrorOfTask))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orchestrator_RegisterNewAgent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrchestratorServer).RegisterNewAgent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orchestrator_RegisterNewAgent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrchestratorServer).RegisterNewAgent(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orchestrator_RemoveAgent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IdAgent)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrchestratorServer).RemoveAgent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orchestrator_RemoveAgent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrchestratorServer).RemoveAgent(ctx, req.(*IdAgent))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orchestrator_Connect_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(OrchestratorServer).Connect(&orchestratorConnectServer{stream})
+}
+
+type Orchestrator_ConnectServer interface {
+	Send(*OrchestratorMessage) error
+	Recv() (*AgentMessage, error)
+	grpc.ServerStream
+}
+
+type orchestratorConnectServer struct {
+	grpc.ServerStream
+}
+
+func (x *orchestratorConnectServer) Send(m *OrchestratorMessage) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *orchestratorConnectServer) Recv() (*AgentMessage, error) {
+	m := new(AgentMessage)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Orchestrator_ServiceDesc is the grpc.ServiceDesc for Orchestrator service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Orchestrator_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "orchestrator.Orchestrator",
+	HandlerType: (*OrchestratorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Heartbeat",
+			Handler:    _Orchestrator_Heartbeat_Handler,
+		},
+		{
+			MethodName: "GetTask",
+			Handler:    _Orchestrator_GetTask_Handler,
+		},
+		{
+			MethodName: "GiveResultOfTask",
+			Handler:    _Orchestrator_GiveResultOfTask_Handler,
+		},
+		{
+			MethodName: "GiveErrorOfTask",
+			Handler:    _Orchestrator_GiveErrorOfTask_Handler,
+		},
+		{
+			MethodName: "RegisterNewAgent",
+			Handler:    _Orchestrator_RegisterNewAgent_Handler,
+		},
+		{
+			MethodName: "RemoveAgent",
+			Handler:    _Orchestrator_RemoveAgent_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Connect",
+			Handler:       _Orchestrator_Connect_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "orchestrator/orchestrator.proto",
+}
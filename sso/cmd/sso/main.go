@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -8,7 +9,12 @@ import (
 
 	"github.com/a-romash/grpc-calculator/sso/internal/app"
 	"github.com/a-romash/grpc-calculator/sso/internal/config"
+	"github.com/a-romash/grpc-calculator/sso/internal/domain/models"
 	"github.com/a-romash/grpc-calculator/sso/internal/lib/logger/handlers/slogpretty"
+	"github.com/a-romash/grpc-calculator/sso/internal/lib/logger/sl"
+	"github.com/a-romash/grpc-calculator/sso/internal/service/auth"
+	"github.com/a-romash/grpc-calculator/sso/internal/storage/memory"
+	"github.com/a-romash/grpc-calculator/sso/internal/storage/postgres"
 )
 
 const (
@@ -22,7 +28,18 @@ func main() {
 
 	log := setupLogger(cfg.Env)
 
-	application := app.New(log, cfg.GRPC.Port, cfg.DatabaseUrl, cfg.TokenTTL)
+	// ./sso --config ./config/config.yaml migrate [up | down [n] | status]
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(log, cfg.DatabaseUrl, flag.Args()[1:]); err != nil {
+			log.Error("failed to migrate", sl.Err(err))
+			os.Exit(1)
+		}
+		return
+	}
+
+	storage := mustSetupStorage(log, cfg.Storage, cfg.DatabaseUrl)
+
+	application := app.New(log, cfg.GRPC.Port, storage, cfg.TokenTTL)
 
 	go func() {
 		application.GRPCServer.MustRun()
@@ -38,6 +55,26 @@ func main() {
 	application.GRPCServer.Stop()
 }
 
+// mustSetupStorage connects to postgres or creates memory storage with apps from config
+func mustSetupStorage(log *slog.Logger, cfg config.StorageConfig, databaseUrl string) auth.UserStorage {
+	switch cfg.Type {
+	case config.StoragePostgres:
+		storage, err := postgres.Connect(databaseUrl)
+		if err != nil {
+			panic(err)
+		}
+		return storage
+	case config.StorageMemory:
+		log.Warn("storage is in memory, users are lost on restart")
+		apps := make([]models.App, 0, len(cfg.Apps))
+		for _, app := range cfg.Apps {
+			apps = append(apps, models.App{ID: app.ID, Name: app.Name, Secret: app.Secret})
+		}
+		return memory.New(apps)
+	}
+	panic("unknown type of storage: " + cfg.Type)
+}
+
 func setupLogger(env string) *slog.Logger {
 	var log *slog.Logger
 
@@ -3,6 +3,7 @@ module github.com/a-romash/grpc-calculator/sso
 go 1.22.1
 
 require (
+	github.com/a-romash/grpc-calculator/lib v0.0.0-00010101000000-000000000000
 	github.com/jackc/pgx/v5 v5.5.5
 	github.com/pkg/errors v0.9.1
 	google.golang.org/grpc v1.63.2
@@ -38,3 +39,5 @@ require (
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240227224415-6ceb2ff114de // indirect
 	google.golang.org/protobuf v1.33.0 // indirect
 )
+
+replace github.com/a-romash/grpc-calculator/lib => ../lib
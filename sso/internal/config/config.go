@@ -11,10 +11,30 @@ import (
 type Config struct {
 	Env         string        `yaml:"env"`
 	DatabaseUrl string        `yaml:"database_url"`
+	Storage     StorageConfig `yaml:"storage"`
 	GRPC        GRPCConfig    `yaml:"grpc"`
 	TokenTTL    time.Duration `yaml:"token_ttl"`
 }
 
+// Types of storage
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory" // users are lost on restart, database isn't needed
+)
+
+// StorageConfig chooses where users are kept
+type StorageConfig struct {
+	Type string `yaml:"type" env-default:"postgres"`
+	// Apps are known to memory storage, postgres keeps them in table apps
+	Apps []AppConfig `yaml:"apps"`
+}
+
+type AppConfig struct {
+	ID     int    `yaml:"id"`
+	Name   string `yaml:"name"`
+	Secret string `yaml:"secret"`
+}
+
 type GRPCConfig struct {
 	Port    int           `yaml:"port"`
 	Timeout time.Duration `yaml:"timeout"`
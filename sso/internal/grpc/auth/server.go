@@ -4,8 +4,9 @@ import (
 	"context"
 	"errors"
 
+	"github.com/a-romash/grpc-calculator/sso/internal/service/auth"
+	"github.com/a-romash/grpc-calculator/sso/internal/storage"
 	"github.com/a-romash/protos/gen/go/sso"
-	"github.com/jackc/pgx/v5"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -51,8 +52,8 @@ func (s *serverAPI) Login(
 	}
 
 	token, err := s.auth.Login(ctx, in.Email, in.Password, int(in.GetAppId()))
-	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, status.Error(codes.NotFound, "user not found")
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		return nil, status.Error(codes.Unauthenticated, "invalid email or password")
 	}
 	if err != nil {
 		return nil, status.Error(codes.Internal, "failed to login user")
@@ -73,7 +74,7 @@ func (s *serverAPI) Register(
 	}
 
 	userID, err := s.auth.RegisterNewUser(ctx, in.Email, in.Password)
-	if errors.Is(err, errors.New("user exists")) {
+	if errors.Is(err, storage.ErrUserExists) {
 		return nil, status.Error(codes.AlreadyExists, "user already exists")
 	}
 	if err != nil {
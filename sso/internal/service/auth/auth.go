@@ -5,16 +5,18 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"strings"
 	"time"
 
 	"github.com/a-romash/grpc-calculator/sso/internal/domain/models"
 	"github.com/a-romash/grpc-calculator/sso/internal/lib/jwt"
-	"github.com/jackc/pgx/v5"
+	"github.com/a-romash/grpc-calculator/sso/internal/storage"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrInvalidCredentials is returned when user doesn't exist or password doesn't match
+var ErrInvalidCredentials = errors.New("invalid email or password")
+
 type Auth struct {
 	log      *slog.Logger
 	storage  UserStorage
@@ -63,9 +65,8 @@ func (a *Auth) Login(
 
 	user, err := a.storage.User(ctx, email)
 	if err != nil {
-		// TODO: handle non-existent user
-		if errors.Is(err, pgx.ErrNoRows) {
-			return "", err
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return "", ErrInvalidCredentials
 		}
 		log.Error(err.Error() + ". Getting user")
 		return "", fmt.Errorf("%s: %w", op, err)
@@ -74,7 +75,7 @@ func (a *Auth) Login(
 	if err := bcrypt.CompareHashAndPassword(user.Password, []byte(password)); err != nil {
 		// executes when passwords doesnt matches
 		log.Error(err.Error() + ". Comparing password")
-		return "", fmt.Errorf("%s: %w", op, err)
+		return "", ErrInvalidCredentials
 	}
 
 	app, err := a.storage.App(ctx, appID)
@@ -113,9 +114,8 @@ func (a *Auth) RegisterNewUser(ctx context.Context, email string, password strin
 
 	id, err := a.storage.SaveUser(ctx, email, passHash)
 	if err != nil {
-		// TODO: handle if user with given email already exists
-		if strings.Contains(err.Error(), "duplicate key value") {
-			return 0, errors.New("user exists")
+		if errors.Is(err, storage.ErrUserExists) {
+			return 0, storage.ErrUserExists
 		}
 		log.Error(err.Error())
 		return 0, fmt.Errorf("%s: %w", op, err)
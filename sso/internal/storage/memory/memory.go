@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/a-romash/grpc-calculator/sso/internal/domain/models"
+	"github.com/a-romash/grpc-calculator/sso/internal/storage"
+)
+
+// Storage keeps users in memory, so they are lost on restart. Apps can't be registered in it,
+// they are given to New from config. Like postgres.Postgresql it returns storage.ErrUserNotFound
+// and storage.ErrAppNotFound when something isn't found
+type Storage struct {
+	mu       sync.Mutex
+	users    map[string]models.User // by email
+	apps     map[int]models.App
+	lastUser int
+}
+
+func New(apps []models.App) *Storage {
+	s := &Storage{
+		users: make(map[string]models.User),
+		apps:  make(map[int]models.App, len(apps)),
+	}
+	for _, app := range apps {
+		s.apps[app.ID] = app
+	}
+	return s
+}
+
+func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte) (int64, error) {
+	const op = "storage.memory.SaveUser"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[email]; ok {
+		return -1, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+	}
+
+	s.lastUser++
+	s.users[email] = models.User{ID: s.lastUser, Email: email, Password: passHash}
+	return int64(s.lastUser), nil
+}
+
+func (s *Storage) User(ctx context.Context, email string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[email]
+	if !ok {
+		return nil, storage.ErrUserNotFound
+	}
+	return &user, nil
+}
+
+func (s *Storage) App(ctx context.Context, id int) (*models.App, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, ok := s.apps[id]
+	if !ok {
+		return nil, storage.ErrAppNotFound
+	}
+	return &app, nil
+}
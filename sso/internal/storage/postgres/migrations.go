@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+
+	"github.com/a-romash/grpc-calculator/lib/migrate"
+)
+
+// migrations of schema, new ones are added as files with the next version
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Service is name of sso in schema_migrations
+const Service = "sso"
+
+// Migrator returns migrator of schema of sso
+func (db *Postgresql) Migrator(log *slog.Logger) (*migrate.Migrator, error) {
+	const op = "storage.postgres.Migrator"
+
+	scripts, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	migrator, err := migrate.New(log, db.pool, Service, scripts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return migrator, nil
+}
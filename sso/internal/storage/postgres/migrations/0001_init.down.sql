@@ -0,0 +1,2 @@
+-- fails, while tables of orchestrator refer to users, its migrations should be reverted first
+DROP TABLE apps, users;
@@ -0,0 +1,12 @@
+-- Baseline schema of sso, later changes are added as next versions
+CREATE TABLE users(
+	id SERIAL PRIMARY KEY,
+	email VARCHAR(255) NOT NULL UNIQUE,
+	pass_hash BYTEA NOT NULL
+);
+
+CREATE TABLE apps(
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL UNIQUE,
+	secret VARCHAR(255) NOT NULL
+);
@@ -9,11 +9,16 @@ import (
 	"time"
 
 	"github.com/a-romash/grpc-calculator/sso/internal/domain/models"
+	"github.com/a-romash/grpc-calculator/sso/internal/storage"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pkg/errors"
 )
 
+// uniqueViolation is code of postgres error, when unique constraint is violated
+const uniqueViolation = "23505"
+
 type Postgresql struct {
 	pool      *pgxpool.Pool
 	closeOnce sync.Once
@@ -24,7 +29,35 @@ func Connect(databaseUrl string) (db *Postgresql, err error) {
 	return ConnectWithConfig(config)
 }
 
+// ConnectWithConfig connects to database and applies pending migrations
 func ConnectWithConfig(config *pgxpool.Config) (db *Postgresql, err error) {
+	db, err = OpenWithConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, err := db.Migrator(slog.Default())
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		slog.Error("error migrating database")
+		db.Close()
+		return nil, err
+	}
+	slog.Info("database was successfully migrated", slog.Int("applied", applied))
+	return db, nil
+}
+
+// Open connects to database without applying migrations
+func Open(databaseUrl string) (db *Postgresql, err error) {
+	config := Config(databaseUrl)
+	return OpenWithConfig(config)
+}
+
+func OpenWithConfig(config *pgxpool.Config) (db *Postgresql, err error) {
 	for i := 0; i < 5; i++ {
 		p, err := pgxpool.NewWithConfig(context.Background(), config)
 		if err != nil || p == nil {
@@ -35,12 +68,6 @@ func ConnectWithConfig(config *pgxpool.Config) (db *Postgresql, err error) {
 		db = &Postgresql{
 			pool: p,
 		}
-		err = Init(db.pool)
-		if err != nil {
-			slog.Error("error initing database")
-			return nil, err
-		}
-		slog.Info("database was successfully init")
 		return db, nil
 	}
 	err = errors.Wrap(err, "timed out waiting to connect postgres")
@@ -91,25 +118,6 @@ func Config(databaseUrl string) *pgxpool.Config {
 	return dbConfig
 }
 
-func Init(p *pgxpool.Pool) (err error) {
-	const sql string = `
-	CREATE TABLE IF NOT EXISTS users(
-		id SERIAL PRIMARY KEY,
-		email VARCHAR(255) NOT NULL UNIQUE,
-		pass_hash BYTEA NOT NULL
-	);
-
-	CREATE TABLE IF NOT EXISTS apps(
-		id SERIAL PRIMARY KEY,
-		name VARCHAR(255) NOT NULL,
-		secret VARCHAR(255) NOT NULL 
-	);
-	`
-
-	_, err = p.Exec(context.Background(), sql)
-	return err
-}
-
 func (db *Postgresql) SaveUser(ctx context.Context, email string, passHash []byte) (int64, error) {
 	const op = "storage.postgres.SaveUser"
 
@@ -123,7 +131,10 @@ func (db *Postgresql) SaveUser(ctx context.Context, email string, passHash []byt
 
 	row := db.pool.QueryRow(ctx, sql, email, passHash)
 	err := row.Scan(&id)
-	if err != nil {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return -1, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+	} else if err != nil {
 		return -1, fmt.Errorf("%s: %w", op, err)
 	}
 	return int64(id), nil
@@ -142,7 +153,7 @@ func (db *Postgresql) User(ctx context.Context, email string) (*models.User, err
 	row := db.pool.QueryRow(ctx, sql, email)
 	err := row.Scan(&user.ID, &user.Email, &user.Password)
 	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, err
+		return nil, storage.ErrUserNotFound
 	} else if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -160,7 +171,7 @@ func (db *Postgresql) App(ctx context.Context, id int) (*models.App, error) {
 	rows, _ := db.pool.Query(ctx, sql, id)
 	app, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.App])
 	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, err
+		return nil, storage.ErrAppNotFound
 	} else if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}